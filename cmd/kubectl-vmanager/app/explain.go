@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+func newExplainCommand(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "explain pod/NAME",
+		Short:   "Reconstruct how the webhook decided the capacity type of a Pod.",
+		Example: "  kubectl vmanager explain -n default pod/web-7d4b9c-x2x5q",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExplain(cmd, opts, args[0])
+		},
+	}
+
+	return cmd
+}
+
+func runExplain(cmd *cobra.Command, opts *Options, arg string) error {
+	resource, podName, found := strings.Cut(arg, "/")
+	if !found || podName == "" {
+		return fmt.Errorf("the pod %q must be in the format of pod/NAME", arg)
+	}
+	if resource = strings.ToLower(resource); resource != "pod" && resource != "pods" && resource != "po" {
+		return fmt.Errorf("only the Pods can be explained, got %q", resource)
+	}
+
+	restConfig, namespace, err := opts.restConfig()
+	if err != nil {
+		return err
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	ownerResolver, err := owner.NewResolver(restConfig)
+	if err != nil {
+		return err
+	}
+	c, err := opts.loadConfig(cmd.Context(), kubeClient, cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+
+	state, err := loadClusterState(cmd.Context(), kubeClient, namespace, c)
+	if err != nil {
+		return err
+	}
+	state.ownerResolver = ownerResolver
+
+	var pod *corev1.Pod
+	for _, p := range state.pods {
+		if p.Name == podName {
+			pod = p
+			break
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("pod %s/%s not found", namespace, podName)
+	}
+
+	explainPod(cmd.Context(), cmd.OutOrStdout(), state, pod)
+	return nil
+}
+
+// explainPod replays the decision of the webhook for the Pod: the Pods of the same source workload which were
+// created before it are counted in the way of the webhook cache, then the decision is made with the same function.
+func explainPod(ctx context.Context, out io.Writer, state *clusterState, pod *corev1.Pod) {
+	actualAffinity := utils.GetPodAffinitySetting(pod)
+	fmt.Fprintf(out, "Pod %s/%s\n", pod.Namespace, pod.Name)
+	fmt.Fprintf(out, "  Affinity label:  %s\n", actualAffinity)
+	// The Pods in the dry-run mode are only annotated with the decision, compare it instead.
+	dryRunAffinity := utils.GetPodDryRunAffinitySetting(pod)
+	dryRun := dryRunAffinity != podaffinity.PodAffinityUnset
+	if dryRun {
+		actualAffinity = dryRunAffinity
+		fmt.Fprintf(out, "  Dry-run affinity: %s\n", dryRunAffinity)
+	}
+	fmt.Fprintf(out, "  Node:            %s (%s)\n", valueOrUnknown(pod.Spec.NodeName), state.getActualCapacityType(pod))
+
+	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+	if podSourceWorkloadKey == nil {
+		fmt.Fprintln(out, "\nThe Pod has no supported owner, the webhook doesn't decide its capacity type.")
+		return
+	}
+	fmt.Fprintf(out, "  Source workload: %s %s\n", podSourceWorkloadType, podSourceWorkloadKey)
+
+	var setting *apis.OptimizeSchedulingSetting
+	var labels, annotations map[string]string
+	workloadType, workloadKey := state.getPodWorkload(pod)
+	if genericOwnerRef := state.getPodGenericOwner(pod); genericOwnerRef != nil {
+		// The setting of a generic workload is resolved from its top-level owner, the same way as the webhook.
+		scalableOwner, err := state.ownerResolver.Resolve(ctx, pod.Namespace, *genericOwnerRef)
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(out, "\nThe owner %s %s/%s is not found, it may have been deleted.\n", genericOwnerRef.Kind,
+				pod.Namespace, genericOwnerRef.Name)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(out, "\nThe owner %s %s/%s can't be resolved: %v\n", genericOwnerRef.Kind, pod.Namespace,
+				genericOwnerRef.Name, err)
+			return
+		}
+		workloadType, workloadKey = scalableOwner.GroupVersionKind.GroupKind().String(), &scalableOwner.Key
+		labels, annotations = scalableOwner.Labels, scalableOwner.Annotations
+		setting = apis.NewOptimizeSchedulingSetting(labels, annotations, scalableOwner.Replicas,
+			scalableOwner.GroupVersionKind.Kind, &state.config.DefaultStrategies)
+	} else {
+		if workloadKey == nil {
+			fmt.Fprintf(out, "\nThe %s %s is not found or has no supported controller, the webhook leaves its Pods unset.\n",
+				podSourceWorkloadType, podSourceWorkloadKey)
+			return
+		}
+		if setting = state.getSetting(workloadType, *workloadKey); setting == nil {
+			fmt.Fprintf(out, "\nThe %s %s is not found, it may have been deleted.\n", workloadType, workloadKey)
+			return
+		}
+		labels, annotations = state.getWorkloadMetadata(workloadType, *workloadKey)
+	}
+	if workloadType != podSourceWorkloadType || *workloadKey != *podSourceWorkloadKey {
+		fmt.Fprintf(out, "  Owner workload:  %s %s\n", workloadType, workloadKey)
+	}
+
+	fmt.Fprintln(out, "\nConfiguration:")
+	for _, key := range optimizescheduling.OptimizeSchedulingKeys.List() {
+		if value, ok := annotations[key]; ok {
+			fmt.Fprintf(out, "  %s=%s (annotation)\n", key, value)
+		} else if value, ok := labels[key]; ok {
+			fmt.Fprintf(out, "  %s=%s (label)\n", key, value)
+		}
+	}
+	if !setting.Enable {
+		fmt.Fprintln(out, "  The optimize scheduling is not enabled, the webhook leaves the Pod unset.")
+	} else {
+		strategySource := "explicit"
+		configuration := optimizescheduling.GetOptimizeSchedulingConfiguration(labels, annotations)
+		if configuration[optimizescheduling.OptimizeSchedulingStrategyKey] == "" {
+			strategySource = "default"
+		}
+		fmt.Fprintf(out, "  Strategy:        %s (%s)\n", setting.Strategy, strategySource)
+		fmt.Fprintf(out, "  Target:          on-demand %d, spot %d\n", setting.TargetOnDemandNum, setting.TargetOnSpotNum)
+	}
+
+	// Replay the cache of the webhook at the time the Pod was created.
+	wsi := apis.NewWorkloadSchedulingInfo()
+	for _, p := range state.pods {
+		if p.UID == pod.UID || !p.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			continue
+		}
+		if sourceType, sourceKey := utils.GetPodSourceWorkloadTypeAndKey(p); sourceType != podSourceWorkloadType ||
+			sourceKey == nil || *sourceKey != *podSourceWorkloadKey {
+			continue
+		}
+		wsi.UpdatePod(types.NamespacedName{Namespace: p.Namespace, Name: p.Name}, utils.GetPodAffinitySetting(p),
+			utils.GetPodDryRunAffinitySetting(p), utils.IsPodLive(p))
+	}
+
+	expectedAffinity := podaffinity.PodAffinityUnset
+	if setting.Enable {
+		expectedAffinity = apis.DetermineNewPodAffinity(setting, wsi, dryRun)
+	}
+
+	fmt.Fprintln(out, "\nDecision:")
+	fmt.Fprintf(out, "  Live Pods of the %s created before: on-demand %d, spot %d\n", podSourceWorkloadType,
+		wsi.OnDemandReplicaCount, wsi.SpotReplicaCount)
+	if dryRun {
+		fmt.Fprintf(out, "  Recorded in the dry-run mode: on-demand %d, spot %d\n", wsi.DryRunOnDemandReplicaCount,
+			wsi.DryRunSpotReplicaCount)
+	}
+	fmt.Fprintf(out, "  Expected:        %s\n", expectedAffinity)
+	fmt.Fprintf(out, "  Actual:          %s\n", actualAffinity)
+
+	if expectedAffinity != actualAffinity {
+		fmt.Fprintln(out, "\nThe actual affinity differs from the replayed decision, the possible reasons are:")
+		fmt.Fprintln(out, "  - The workload was biased to on-demand after its spot nodes were interrupted or its spot Pods stayed unschedulable.")
+		fmt.Fprintln(out, "  - The Pods created or deleted concurrently were not in the cache of the webhook yet.")
+		fmt.Fprintln(out, "  - The strategy or the replicas of the workload have been changed since the Pod was created.")
+	}
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
+)
+
+var (
+	cloneSetGVK = schema.GroupVersionKind{Group: "apps.kruise.io", Version: "v1alpha1", Kind: "CloneSet"}
+	rolloutGVK  = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+)
+
+// newTestOwnerResolver returns the resolver of the CloneSets and the Rollouts, whose scale is always 2.
+func newTestOwnerResolver(objs ...runtime.Object) owner.Resolver {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(cloneSetGVK, meta.RESTScopeNamespace)
+	restMapper.Add(rolloutGVK, meta.RESTScopeNamespace)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("get", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"spec":       map[string]interface{}{"replicas": int64(2)},
+		}}, nil
+	})
+
+	scheme := metadatafake.NewTestScheme()
+	scheme.AddKnownTypeWithName(cloneSetGVK, &metav1.PartialObjectMetadata{})
+	scheme.AddKnownTypeWithName(rolloutGVK, &metav1.PartialObjectMetadata{})
+	return owner.NewResolverForClients(metadatafake.NewSimpleMetadataClient(scheme, objs...), dynamicClient, restMapper)
+}
+
+func newTestGenericOwner(gvk schema.GroupVersionKind, name string, labels map[string]string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name), Labels: labels},
+	}
+}
+
+func newTestOwnerRef(apiVersion, kind, name string) metav1.OwnerReference {
+	return metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID("uid-" + name),
+		Controller: ptr.To(true)}
+}
+
+// newTestPod returns the i-th Pod of the owner, which was created i minutes ago and marked with the affinity.
+func newTestPod(ownerRef metav1.OwnerReference, i int, affinity podaffinity.PodAffinitySettingName) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              fmt.Sprintf("%s-%d", ownerRef.Name, i),
+			UID:               types.UID(fmt.Sprintf("uid-%s-%d", ownerRef.Name, i)),
+			CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC)),
+			Labels:            map[string]string{},
+			OwnerReferences:   []metav1.OwnerReference{ownerRef},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if affinity != podaffinity.PodAffinityUnset {
+		pod.Labels[podaffinity.PodAffinityLabelKey] = string(affinity)
+	}
+	return pod
+}
+
+func enabledLabels(strategy string) map[string]string {
+	return map[string]string{
+		optimizescheduling.OptimizeSchedulingKey:         "true",
+		optimizescheduling.OptimizeSchedulingStrategyKey: strategy,
+	}
+}
+
+func TestExplainPod(t *testing.T) {
+	jobRef := newTestOwnerRef("batch/v1", "Job", "train")
+	cloneSetRef := newTestOwnerRef(cloneSetGVK.GroupVersion().String(), cloneSetGVK.Kind, "cache")
+	rolloutReplicaSetRef := newTestOwnerRef("apps/v1", "ReplicaSet", "api-5d8f")
+	orphanReplicaSetRef := newTestOwnerRef("apps/v1", "ReplicaSet", "orphan-6c9d")
+
+	objects := []runtime.Object{
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "train", UID: jobRef.UID,
+				Labels: enabledLabels(optimizescheduling.OptimizeSchedulingStrategyAllInSpot)},
+			Spec: batchv1.JobSpec{Parallelism: ptr.To[int32](2)},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-5d8f", UID: rolloutReplicaSetRef.UID,
+				OwnerReferences: []metav1.OwnerReference{newTestOwnerRef(rolloutGVK.GroupVersion().String(), rolloutGVK.Kind, "api")}},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "orphan-6c9d", UID: orphanReplicaSetRef.UID},
+		},
+		newTestPod(jobRef, 0, podaffinity.PodAffinitySpot),
+		newTestPod(jobRef, 1, podaffinity.PodAffinitySpot),
+		newTestPod(cloneSetRef, 0, podaffinity.PodAffinityOnDemand),
+		newTestPod(cloneSetRef, 1, podaffinity.PodAffinitySpot),
+		newTestPod(rolloutReplicaSetRef, 0, podaffinity.PodAffinityOnDemand),
+		newTestPod(orphanReplicaSetRef, 0, podaffinity.PodAffinityUnset),
+	}
+
+	state, err := loadClusterState(context.Background(), fake.NewSimpleClientset(objects...), "default",
+		config.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("Failed to load the cluster state: %v", err)
+	}
+	state.ownerResolver = newTestOwnerResolver(
+		newTestGenericOwner(cloneSetGVK, "cache", enabledLabels(optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand)),
+		newTestGenericOwner(rolloutGVK, "api", enabledLabels(optimizescheduling.OptimizeSchedulingStrategyAllInSpot)),
+	)
+
+	testCases := []struct {
+		name   string
+		pod    string
+		expect []string
+	}{
+		{
+			name: "job",
+			pod:  "train-1",
+			expect: []string{
+				"Source workload: Job default/train",
+				"Strategy:        all-in-spot (explicit)",
+				"Target:          on-demand 0, spot 2",
+				"Live Pods of the Job created before: on-demand 0, spot 1",
+				"Expected:        spot",
+			},
+		},
+		{
+			name: "generic owner of the pod",
+			pod:  "cache-1",
+			expect: []string{
+				"Source workload: CloneSet.apps.kruise.io default/cache",
+				"Strategy:        all-in-on-demand (explicit)",
+				"Target:          on-demand 2, spot 0",
+				"Expected:        on-demand",
+				"Actual:          spot",
+				"The actual affinity differs from the replayed decision",
+			},
+		},
+		{
+			name: "generic owner of the replicaset",
+			pod:  "api-5d8f-0",
+			expect: []string{
+				"Source workload: ReplicaSet default/api-5d8f",
+				"Owner workload:  Rollout.argoproj.io default/api",
+				"Strategy:        all-in-spot (explicit)",
+				"Expected:        spot",
+				"Actual:          on-demand",
+			},
+		},
+		{
+			name:   "replicaset without controller",
+			pod:    "orphan-6c9d-0",
+			expect: []string{"The ReplicaSet default/orphan-6c9d is not found or has no supported controller"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pod *corev1.Pod
+			for _, p := range state.pods {
+				if p.Name == tc.pod {
+					pod = p
+				}
+			}
+			if pod == nil {
+				t.Fatalf("Pod %s not found", tc.pod)
+			}
+
+			out := &bytes.Buffer{}
+			explainPod(context.Background(), out, state, pod)
+			for _, expect := range tc.expect {
+				if !strings.Contains(out.String(), expect) {
+					t.Errorf("Expect the explanation to contain %q, got:\n%s", expect, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "vmanager", Name: "vmanager-webhook-manager-config"},
+		Data: map[string]string{
+			configMapKey: "apiVersion: vmanager.vacant.sh/v1alpha1\n" +
+				"kind: WebhookManagerConfiguration\n" +
+				"nodeType:\n" +
+				"  labelKey: example.com/capacity\n",
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		configMap      string
+		objects        []runtime.Object
+		expectErr      bool
+		expectLabelKey string
+		expectWarning  bool
+	}{
+		{
+			name:           "configmap",
+			configMap:      defaultConfigMap,
+			objects:        []runtime.Object{configMap},
+			expectLabelKey: "example.com/capacity",
+		},
+		{
+			name:           "missing configmap",
+			configMap:      defaultConfigMap,
+			expectLabelKey: config.NewDefaultConfiguration().NodeType.LabelKey,
+			expectWarning:  true,
+		},
+		{
+			name:      "invalid configmap flag",
+			configMap: "vmanager-webhook-manager-config",
+			objects:   []runtime.Object{configMap},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{ConfigMap: tc.configMap}
+			errOut := &bytes.Buffer{}
+			c, err := opts.loadConfig(context.Background(), fake.NewSimpleClientset(tc.objects...), errOut)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expect error %v, got %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+
+			if c.NodeType.LabelKey != tc.expectLabelKey {
+				t.Errorf("Expect the node label key %s, got %s", tc.expectLabelKey, c.NodeType.LabelKey)
+			}
+			if hasWarning := strings.Contains(errOut.String(), "Warning:"); hasWarning != tc.expectWarning {
+				t.Errorf("Expect the warning %v, got %q", tc.expectWarning, errOut.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"vacant.sh/vmanager/pkg/config"
+)
+
+const (
+	ComponentName = "kubectl-vmanager"
+
+	// defaultConfigMap is the ConfigMap which deploy/webhook-manager.yaml mounts as the configuration file.
+	defaultConfigMap = "vmanager/vmanager-webhook-manager-config"
+	// configMapKey is the key of the configuration file in the ConfigMap.
+	configMapKey = "config.yaml"
+)
+
+// Options holds the flags shared by all the subcommands.
+type Options struct {
+	Kubeconfig    string
+	Context       string
+	Namespace     string
+	AllNamespaces bool
+	// ConfigFile is the configuration file of the webhook-manager, which provides the node labels and
+	// the default strategies. It's read from the ConfigMap if it's not specified.
+	ConfigFile string
+	// ConfigMap is the NAMESPACE/NAME of the ConfigMap which the webhook-manager loads its configuration from.
+	ConfigMap string
+}
+
+func NewKubectlVManagerCommand() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   ComponentName,
+		Short: "Inspect how vmanager places the Pods on the on-demand and spot nodes.",
+		Long: fmt.Sprintf("The %s is a kubectl plugin, run it as `kubectl vmanager` to inspect the placement "+
+			"decided by the vmanager webhook.", ComponentName),
+		SilenceUsage: true,
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file.")
+	flags.StringVar(&opts.Context, "context", "", "The name of the kubeconfig context to use.")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", "", "The namespace scope, default to the namespace of the context.")
+	flags.StringVar(&opts.ConfigFile, "config", "", "Path to the configuration file of the webhook-manager, "+
+		"which overrides the ConfigMap.")
+	flags.StringVar(&opts.ConfigMap, "config-map", defaultConfigMap, "The NAMESPACE/NAME of the ConfigMap "+
+		"which holds the configuration of the webhook-manager.")
+
+	cmd.AddCommand(newStatusCommand(opts))
+	cmd.AddCommand(newExplainCommand(opts))
+
+	return cmd
+}
+
+// restConfig loads the kubeconfig and resolves the namespace from it.
+func (o *Options) restConfig() (*rest.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.Kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.Context})
+
+	namespace := o.Namespace
+	if namespace == "" {
+		var err error
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	return restConfig, namespace, nil
+}
+
+// kubeClient builds the client and resolves the namespace from the kubeconfig.
+func (o *Options) kubeClient() (kubernetes.Interface, string, error) {
+	restConfig, namespace, err := o.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return kubeClient, namespace, nil
+}
+
+// loadConfig loads the configuration of the webhook-manager, so that the placement is computed the same way.
+// It's read from the file of --config, or from the ConfigMap which the webhook-manager mounts. If the ConfigMap
+// can't be read, the defaults are used with a warning, since the node labels may differ from the cluster.
+func (o *Options) loadConfig(ctx context.Context, kubeClient kubernetes.Interface, errOut io.Writer) (*config.WebhookManagerConfiguration, error) {
+	var c *config.WebhookManagerConfiguration
+	if o.ConfigFile != "" {
+		var err error
+		if c, err = config.LoadFromFile(o.ConfigFile); err != nil {
+			return nil, err
+		}
+		config.SetDefaults(c)
+	} else {
+		namespace, name, found := strings.Cut(o.ConfigMap, "/")
+		if !found || namespace == "" || name == "" {
+			return nil, fmt.Errorf("the ConfigMap %q must be in the format of NAMESPACE/NAME", o.ConfigMap)
+		}
+		var err error
+		if c, err = loadConfigMap(ctx, kubeClient, namespace, name); err != nil {
+			fmt.Fprintf(errOut, "Warning: %v, the defaults of the webhook-manager are used, specify --config "+
+				"if the node labels or the default strategies are customized.\n", err)
+			c = config.NewDefaultConfiguration()
+		}
+	}
+
+	if errs := c.Validate(); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return c, nil
+}
+
+// loadConfigMap reads the configuration from the ConfigMap which the webhook-manager mounts.
+func loadConfigMap(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (*config.WebhookManagerConfiguration, error) {
+	configMap, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ConfigMap %s/%s: %v", namespace, name, err)
+	}
+	data, ok := configMap.Data[configMapKey]
+	if !ok {
+		return nil, fmt.Errorf("the ConfigMap %s/%s has no %s", namespace, name, configMapKey)
+	}
+
+	c, err := config.Load([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the configuration from the ConfigMap %s/%s: %v", namespace, name, err)
+	}
+	config.SetDefaults(c)
+	return c, nil
+}
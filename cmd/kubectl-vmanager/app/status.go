@@ -0,0 +1,111 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+func newStatusCommand(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [TYPE/NAME]",
+		Short: "Show the target and observed placement of the workloads which enable the optimize scheduling.",
+		Example: "  kubectl vmanager status -n default\n" +
+			"  kubectl vmanager status -n default deploy/web",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd, opts, args)
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.AllNamespaces, "all-namespaces", "A", false, "Show the workloads in all the namespaces.")
+
+	return cmd
+}
+
+func runStatus(cmd *cobra.Command, opts *Options, args []string) error {
+	var workloadType, workloadName string
+	if len(args) == 1 {
+		var err error
+		if workloadType, workloadName, err = parseWorkloadArg(args[0]); err != nil {
+			return err
+		}
+	}
+
+	kubeClient, namespace, err := opts.kubeClient()
+	if err != nil {
+		return err
+	}
+	c, err := opts.loadConfig(cmd.Context(), kubeClient, cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	if opts.AllNamespaces {
+		if workloadName != "" {
+			return fmt.Errorf("a workload cannot be specified with --all-namespaces")
+		}
+		namespace = metav1.NamespaceAll
+	}
+
+	state, err := loadClusterState(cmd.Context(), kubeClient, namespace, c)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, placement := range state.getPlacements() {
+		if workloadName != "" && (placement.Type != workloadType || placement.Key.Name != workloadName) {
+			continue
+		}
+		if found {
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+		found = true
+		printPlacement(cmd.OutOrStdout(), state, placement)
+	}
+
+	if !found {
+		if workloadName != "" {
+			return fmt.Errorf("%s %s/%s not found or doesn't enable the optimize scheduling", workloadType, namespace, workloadName)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "No workloads enable the optimize scheduling.")
+	}
+	return nil
+}
+
+// printPlacement prints the summary of the workload, and the intended and actual capacity type of every Pod.
+func printPlacement(out io.Writer, state *clusterState, placement *workloadPlacement) {
+	deviation := placement.Deviation()
+	fmt.Fprintf(out, "%s %s\n", placement.Type, placement.Key)
+	fmt.Fprintf(out, "  Strategy:   %s\n", placement.Setting.Strategy)
+	fmt.Fprintf(out, "  Target:     on-demand %d, spot %d\n", deviation.TargetOnDemandNum, deviation.TargetOnSpotNum)
+	fmt.Fprintf(out, "  Observed:   on-demand %d, spot %d\n", deviation.ObservedOnDemandNum, deviation.ObservedOnSpotNum)
+	fmt.Fprintf(out, "  Condition:  %s\n", deviation.Condition())
+
+	if len(placement.Pods) == 0 {
+		fmt.Fprintln(out, "  No Pods.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  POD\tPHASE\tINTENDED\tACTUAL\tNODE")
+	for _, pod := range placement.Pods {
+		intended := utils.GetPodAffinitySetting(pod)
+		actual := state.getActualCapacityType(pod)
+		// The Pods which are not marked by the webhook can land on any node.
+		if intended != podaffinity.PodAffinityUnset && actual != capacityTypeUnknown && string(intended) != actual {
+			actual += " (mismatch)"
+		}
+		phase := string(pod.Status.Phase)
+		if !utils.IsPodLive(pod) {
+			phase += " (not counted)"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", pod.Name, phase, intended, actual, valueOrUnknown(pod.Spec.NodeName))
+	}
+	w.Flush()
+}
@@ -0,0 +1,297 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"vacant.sh/vmanager/pkg/config"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+// capacityTypeUnknown is shown for the Pods which are not scheduled, or on the nodes without the node type label.
+const capacityTypeUnknown = "-"
+
+// workloadPlacement is a Deployment or StatefulSet with its Pods, the setting and the counts are computed
+// the same way as the webhook cache.
+type workloadPlacement struct {
+	Type string
+	Key  types.NamespacedName
+
+	Setting *apis.OptimizeSchedulingSetting
+	// Pods are the Pods of all the revisions of the workload, sorted by their creation.
+	Pods []*corev1.Pod
+	// Observed counts the live Pods which have been marked by the webhook.
+	Observed *apis.WorkloadSchedulingInfo
+}
+
+func (w *workloadPlacement) Deviation() *apis.WorkloadDeviation {
+	return &apis.WorkloadDeviation{
+		WorkloadType:        w.Type,
+		WorkloadKey:         w.Key,
+		TargetOnDemandNum:   w.Setting.TargetOnDemandNum,
+		TargetOnSpotNum:     w.Setting.TargetOnSpotNum,
+		ObservedOnDemandNum: w.Observed.OnDemandReplicaCount,
+		ObservedOnSpotNum:   w.Observed.SpotReplicaCount,
+	}
+}
+
+// clusterState is a snapshot of the objects which the webhook cache watches.
+type clusterState struct {
+	config *config.WebhookManagerConfiguration
+
+	deployments  map[types.NamespacedName]*appsv1.Deployment
+	statefulSets map[types.NamespacedName]*appsv1.StatefulSet
+	replicaSets  map[types.NamespacedName]*appsv1.ReplicaSet
+	jobs         map[types.NamespacedName]*batchv1.Job
+	pods         []*corev1.Pod
+
+	// nodeCapacityTypes maps the node names to their capacity type, on-demand or spot.
+	nodeCapacityTypes map[string]string
+
+	// ownerResolver resolves the generic owners like the webhook, it's only set for the commands which
+	// look into the generic workloads.
+	ownerResolver owner.Resolver
+}
+
+// loadClusterState lists the objects in the namespace, or in all the namespaces if it's empty.
+func loadClusterState(ctx context.Context, kubeClient kubernetes.Interface, namespace string,
+	c *config.WebhookManagerConfiguration) (*clusterState, error) {
+
+	state := &clusterState{
+		config:            c,
+		deployments:       map[types.NamespacedName]*appsv1.Deployment{},
+		statefulSets:      map[types.NamespacedName]*appsv1.StatefulSet{},
+		replicaSets:       map[types.NamespacedName]*appsv1.ReplicaSet{},
+		jobs:              map[types.NamespacedName]*batchv1.Job{},
+		nodeCapacityTypes: map[string]string{},
+	}
+
+	deploymentList, err := kubeClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		state.deployments[types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}] = deployment
+	}
+
+	statefulSetList, err := kubeClient.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %v", err)
+	}
+	for i := range statefulSetList.Items {
+		statefulSet := &statefulSetList.Items[i]
+		state.statefulSets[types.NamespacedName{Namespace: statefulSet.Namespace, Name: statefulSet.Name}] = statefulSet
+	}
+
+	replicaSetList, err := kubeClient.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %v", err)
+	}
+	for i := range replicaSetList.Items {
+		replicaSet := &replicaSetList.Items[i]
+		state.replicaSets[types.NamespacedName{Namespace: replicaSet.Namespace, Name: replicaSet.Name}] = replicaSet
+	}
+
+	jobList, err := kubeClient.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		state.jobs[types.NamespacedName{Namespace: job.Namespace, Name: job.Name}] = job
+	}
+
+	podList, err := kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for i := range podList.Items {
+		state.pods = append(state.pods, &podList.Items[i])
+	}
+	sort.Slice(state.pods, func(i, j int) bool {
+		return state.pods[i].CreationTimestamp.Before(&state.pods[j].CreationTimestamp)
+	})
+
+	nodeList, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	for _, node := range nodeList.Items {
+		switch node.Labels[c.NodeType.LabelKey] {
+		case c.NodeType.OnDemandValue:
+			state.nodeCapacityTypes[node.Name] = string(podaffinity.PodAffinityOnDemand)
+		case c.NodeType.SpotValue:
+			state.nodeCapacityTypes[node.Name] = string(podaffinity.PodAffinitySpot)
+		}
+	}
+
+	return state, nil
+}
+
+// getPodWorkload returns the Deployment, StatefulSet or Job which the Pod belongs to.
+func (s *clusterState) getPodWorkload(pod *corev1.Pod) (string, *types.NamespacedName) {
+	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+	if podSourceWorkloadKey == nil {
+		return "", nil
+	}
+
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		deploymentKey := utils.GetReplicaSetSourceDeploymentKey(s.replicaSets[*podSourceWorkloadKey])
+		if deploymentKey == nil {
+			return "", nil
+		}
+		return "Deployment", deploymentKey
+	case "StatefulSet", "Job":
+		return podSourceWorkloadType, podSourceWorkloadKey
+	}
+	return "", nil
+}
+
+// getPodGenericOwner returns the controller of the Pod or of its ReplicaSet, whose setting is resolved from
+// its top-level owner by the webhook, such as an Argo Rollout or an OpenKruise CloneSet.
+func (s *clusterState) getPodGenericOwner(pod *corev1.Pod) *metav1.OwnerReference {
+	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+	if podSourceWorkloadKey == nil {
+		return nil
+	}
+
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		return utils.GetReplicaSetSourceGenericOwner(s.replicaSets[*podSourceWorkloadKey])
+	case "StatefulSet", "Job":
+		return nil
+	}
+	return metav1.GetControllerOf(pod)
+}
+
+// getSetting builds the OptimizeSchedulingSetting of the workload like the webhook cache does.
+func (s *clusterState) getSetting(workloadType string, workloadKey types.NamespacedName) *apis.OptimizeSchedulingSetting {
+	switch workloadType {
+	case "Deployment":
+		if deployment, ok := s.deployments[workloadKey]; ok {
+			return apis.NewDeploymentInfo(deployment, &s.config.DefaultStrategies).OptimizeSchedulingSetting
+		}
+	case "StatefulSet":
+		if statefulSet, ok := s.statefulSets[workloadKey]; ok {
+			return apis.NewStatefulSetInfo(statefulSet, &s.config.DefaultStrategies).OptimizeSchedulingSetting
+		}
+	case "Job":
+		if job, ok := s.jobs[workloadKey]; ok {
+			return apis.NewJobInfo(job, &s.config.DefaultStrategies).OptimizeSchedulingSetting
+		}
+	}
+	return nil
+}
+
+// getWorkloadMetadata returns the labels and the annotations of the workload.
+func (s *clusterState) getWorkloadMetadata(workloadType string, workloadKey types.NamespacedName) (map[string]string, map[string]string) {
+	switch workloadType {
+	case "Deployment":
+		if deployment, ok := s.deployments[workloadKey]; ok {
+			return deployment.Labels, deployment.Annotations
+		}
+	case "StatefulSet":
+		if statefulSet, ok := s.statefulSets[workloadKey]; ok {
+			return statefulSet.Labels, statefulSet.Annotations
+		}
+	case "Job":
+		if job, ok := s.jobs[workloadKey]; ok {
+			return job.Labels, job.Annotations
+		}
+	}
+	return nil, nil
+}
+
+// getPlacements returns the workloads which enable the optimize scheduling, sorted by their namespaced names.
+func (s *clusterState) getPlacements() []*workloadPlacement {
+	placements := map[string]*workloadPlacement{}
+	newPlacement := func(workloadType string, workloadKey types.NamespacedName) {
+		setting := s.getSetting(workloadType, workloadKey)
+		if setting == nil || !setting.Enable {
+			return
+		}
+		placements[workloadType+"/"+workloadKey.String()] = &workloadPlacement{
+			Type:     workloadType,
+			Key:      workloadKey,
+			Setting:  setting,
+			Observed: apis.NewWorkloadSchedulingInfo(),
+		}
+	}
+	for deploymentKey := range s.deployments {
+		newPlacement("Deployment", deploymentKey)
+	}
+	for statefulSetKey := range s.statefulSets {
+		newPlacement("StatefulSet", statefulSetKey)
+	}
+
+	for _, pod := range s.pods {
+		workloadType, workloadKey := s.getPodWorkload(pod)
+		if workloadKey == nil {
+			continue
+		}
+		placement, ok := placements[workloadType+"/"+workloadKey.String()]
+		if !ok {
+			continue
+		}
+		placement.Pods = append(placement.Pods, pod)
+		placement.Observed.UpdatePod(types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name},
+			utils.GetPodAffinitySetting(pod), utils.GetPodDryRunAffinitySetting(pod), utils.IsPodLive(pod))
+	}
+
+	sorted := make([]*workloadPlacement, 0, len(placements))
+	for _, placement := range placements {
+		sorted = append(sorted, placement)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Key != sorted[j].Key {
+			return sorted[i].Key.String() < sorted[j].Key.String()
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+	return sorted
+}
+
+// getActualCapacityType returns the capacity type of the node which the Pod is running on.
+func (s *clusterState) getActualCapacityType(pod *corev1.Pod) string {
+	if capacityType, ok := s.nodeCapacityTypes[pod.Spec.NodeName]; ok {
+		return capacityType
+	}
+	return capacityTypeUnknown
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return capacityTypeUnknown
+	}
+	return value
+}
+
+// parseWorkloadArg parses the argument such as deploy/web or statefulset/db into the workload type and name.
+func parseWorkloadArg(arg string) (string, string, error) {
+	resource, name, found := strings.Cut(arg, "/")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("the workload %q must be in the format of TYPE/NAME, such as deploy/web", arg)
+	}
+
+	switch strings.ToLower(resource) {
+	case "deploy", "deployment", "deployments":
+		return "Deployment", name, nil
+	case "sts", "statefulset", "statefulsets":
+		return "StatefulSet", name, nil
+	}
+	return "", "", fmt.Errorf("the workload type %q is not supported, must be a Deployment or StatefulSet", resource)
+}
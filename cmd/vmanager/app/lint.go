@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"vacant.sh/vmanager/pkg/lint"
+)
+
+type lintOptions struct {
+	Output        string
+	FailOnWarning bool
+}
+
+func newLintCommand() *cobra.Command {
+	opts := &lintOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "lint [PATH...]",
+		Short: "Check the optimize scheduling labels and annotations of the manifests like the admission webhook.",
+		Long: "The lint command reads the YAML or JSON manifests from the files, the directories recursively, " +
+			"or the stdin if the path is - or not given, and reports the configurations denied by the validating webhooks " +
+			"as errors, and the ones they warn about as warnings. It fails if there is any error.",
+		Example: "  vmanager lint deploy/workloads\n" +
+			"  kustomize build overlays/prod | vmanager lint -o sarif > vmanager.sarif",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLint(cmd, opts, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", lint.OutputText, fmt.Sprintf("The output format, must be in %v.",
+		lint.Outputs.List()))
+	flags.BoolVar(&opts.FailOnWarning, "fail-on-warning", false, "Fail if there is any warning.")
+
+	return cmd
+}
+
+func runLint(cmd *cobra.Command, opts *lintOptions, paths []string) error {
+	if !lint.Outputs.Has(opts.Output) {
+		return fmt.Errorf("unknown output %s, must be in %v", opts.Output, lint.Outputs.List())
+	}
+	if len(paths) == 0 {
+		paths = []string{lint.StdinPath}
+	}
+
+	documents, err := lint.ReadDocuments(paths, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	findings := lint.Lint(documents)
+	if err := lint.Write(cmd.OutOrStdout(), opts.Output, findings); err != nil {
+		return err
+	}
+
+	errors, warnings := 0, 0
+	for _, finding := range findings {
+		if finding.Severity == lint.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	if errors > 0 || (opts.FailOnWarning && warnings > 0) {
+		// The findings have been reported, only fail the command.
+		cmd.SilenceErrors = true
+		return fmt.Errorf("found %d errors and %d warnings", errors, warnings)
+	}
+	return nil
+}
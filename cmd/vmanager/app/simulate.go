@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"vacant.sh/vmanager/pkg/simulator"
+)
+
+type simulateOptions struct {
+	Manifests  []string
+	Scenario   string
+	ConfigFile string
+}
+
+func newSimulateCommand() *cobra.Command {
+	opts := &simulateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Replay a scenario against the placement logic of the webhook and print the timeline.",
+		Long: "The simulate command creates the Deployments and StatefulSets of the manifests with a fake clientset, " +
+			"then replays the steps of the scenario, such as scale, rollout, interrupt-spot and burst-create. " +
+			"Every new Pod is placed by the same cache as the webhook. It fails if any target is not met at the end.",
+		Example: "  vmanager simulate -f deploy/workloads/deployment1.yaml --scenario scenario.yaml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSimulate(cmd, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&opts.Manifests, "filename", "f", nil, "The manifests of the Deployments and StatefulSets.")
+	flags.StringVar(&opts.Scenario, "scenario", "", "The scenario file of the steps to replay.")
+	flags.StringVar(&opts.ConfigFile, "config", "", "Path to the configuration file of the webhook-manager.")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+func runSimulate(cmd *cobra.Command, opts *simulateOptions) error {
+	c, err := loadConfig(opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	workloads, err := simulator.LoadWorkloadsFromFiles(opts.Manifests)
+	if err != nil {
+		return err
+	}
+
+	scenario := &simulator.Scenario{}
+	if opts.Scenario != "" {
+		if scenario, err = simulator.LoadScenarioFromFile(opts.Scenario); err != nil {
+			return err
+		}
+		if errs := scenario.Validate(); len(errs) > 0 {
+			return errs.ToAggregate()
+		}
+	}
+
+	s, err := simulator.NewSimulator(c, cmd.OutOrStdout())
+	if err != nil {
+		return err
+	}
+	statuses, err := s.Run(cmd.Context(), workloads, scenario)
+	if err != nil {
+		return err
+	}
+
+	unmet := 0
+	for _, status := range statuses {
+		if !status.Satisfied() {
+			unmet++
+		}
+	}
+	if unmet > 0 {
+		return fmt.Errorf("%d of %d workloads don't meet their targets", unmet, len(statuses))
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\nAll %d workloads meet their targets.\n", len(statuses))
+	return nil
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"vacant.sh/vmanager/pkg/config"
+)
+
+const ComponentName = "vmanager"
+
+func NewVManagerCommand() *cobra.Command {
+	// Init the flags to global flag config, the logs of the webhook cache are written to stderr.
+	klog.InitFlags(flag.CommandLine)
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+
+	cmd := &cobra.Command{
+		Use:          ComponentName,
+		Long:         fmt.Sprintf("The %s runs the vmanager logic offline, such as simulating the placement, linting the manifests and generating the webhook configurations.", ComponentName),
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(newSimulateCommand())
+	cmd.AddCommand(newLintCommand())
+	cmd.AddCommand(newWebhookConfigCommand())
+
+	return cmd
+}
+
+// loadConfig loads the configuration of the webhook-manager, or the defaults if the path is empty.
+func loadConfig(path string) (*config.WebhookManagerConfiguration, error) {
+	c := config.NewDefaultConfiguration()
+	if path != "" {
+		var err error
+		if c, err = config.LoadFromFile(path); err != nil {
+			return nil, err
+		}
+		config.SetDefaults(c)
+	}
+
+	if errs := c.Validate(); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return c, nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"vacant.sh/vmanager/pkg/webhook/configuration"
+)
+
+type webhookConfigOptions struct {
+	ConfigFile       string
+	CABundleFile     string
+	ServiceNamespace string
+	ServiceName      string
+	ServicePort      int32
+}
+
+func newWebhookConfigCommand() *cobra.Command {
+	opts := &webhookConfigOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "webhook-config",
+		Short: "Print the webhook configurations of the webhook-manager.",
+		Long: "The webhook-config command prints the MutatingWebhookConfigurations and ValidatingWebhookConfigurations " +
+			"of the webhook-manager, whose namespaceSelector selects the same namespaces as the excludedNamespaces, " +
+			"includedNamespaces and namespaceSelector of the configuration, so that the API server doesn't call the " +
+			"webhooks for the objects which are skipped anyway.",
+		Example: "  vmanager webhook-config --config config.yaml --ca-bundle-file ca.crt | kubectl apply -f -",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWebhookConfig(cmd, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.ConfigFile, "config", "", "Path to the configuration file of the webhook-manager.")
+	flags.StringVar(&opts.CABundleFile, "ca-bundle-file", "", "Path to the PEM encoded CA bundle of the webhook server certificate.")
+	flags.StringVar(&opts.ServiceNamespace, "service-namespace", configuration.DefaultService.Namespace, "The namespace of the webhook Service.")
+	flags.StringVar(&opts.ServiceName, "service-name", configuration.DefaultService.Name, "The name of the webhook Service.")
+	flags.Int32Var(&opts.ServicePort, "service-port", configuration.DefaultService.Port, "The port of the webhook Service.")
+
+	return cmd
+}
+
+func runWebhookConfig(cmd *cobra.Command, opts *webhookConfigOptions) error {
+	c, err := loadConfig(opts.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	var caBundle []byte
+	if opts.CABundleFile != "" {
+		if caBundle, err = os.ReadFile(opts.CABundleFile); err != nil {
+			return fmt.Errorf("failed to read the CA bundle: %v", err)
+		}
+	}
+
+	service := configuration.ServiceReference{Namespace: opts.ServiceNamespace, Name: opts.ServiceName, Port: opts.ServicePort}
+	mutatingConfigurations, validatingConfigurations := configuration.BuildWebhookConfigurations(c, service, caBundle)
+
+	var objects []interface{}
+	for _, mutatingConfiguration := range mutatingConfigurations {
+		objects = append(objects, mutatingConfiguration)
+	}
+	for _, validatingConfiguration := range validatingConfigurations {
+		objects = append(objects, validatingConfiguration)
+	}
+
+	out := cmd.OutOrStdout()
+	for i, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(out, "---")
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+
+	"k8s.io/component-base/cli"
+
+	"vacant.sh/vmanager/cmd/vmanager/app"
+)
+
+func main() {
+	cmd := app.NewVManagerCommand()
+
+	os.Exit(cli.Run(cmd))
+}
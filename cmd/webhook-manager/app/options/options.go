@@ -1,13 +1,22 @@
 package options
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"vacant.sh/vmanager/pkg/config"
 )
 
 const (
 	defaultBindAddress = "0.0.0.0"
 	defaultPort        = 8443
+
+	defaultConfigReloadInterval = 10 * time.Second
+
+	defaultMetricsBindAddress = ":8080"
 )
 
 type Options struct {
@@ -20,6 +29,22 @@ type Options struct {
 
 	BindAddress string
 	SecurePort  int
+
+	// MetricsBindAddress is the address to serve the metrics on, "0" disables the metrics server.
+	MetricsBindAddress string
+
+	// ConfigFile is the path of the WebhookManagerConfiguration file.
+	ConfigFile string
+	// ConfigReloadInterval is the interval of checking whether the ConfigFile changed, 0 disables the reloading.
+	ConfigReloadInterval time.Duration
+
+	// The flags below override the values of the ConfigFile when they are set.
+	NodeTypeLabelKey           string
+	DefaultDeploymentStrategy  string
+	DefaultStatefulSetStrategy string
+	ExcludedNamespaces         []string
+	IncludedNamespaces         []string
+	DryRun                     bool
 }
 
 // NewOptions return a new webhook-manager options.
@@ -39,6 +64,27 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 		"The IP address on which to listen for the --secure-port port.")
 	fs.IntVar(&o.SecurePort, "secure-port", defaultPort,
 		"The secure port on which to serve HTTPS.")
+
+	fs.StringVar(&o.MetricsBindAddress, "metrics-bind-address", defaultMetricsBindAddress,
+		"The address on which to serve the metrics over HTTP, set to 0 to disable the metrics server.")
+
+	fs.StringVar(&o.ConfigFile, "config", "", "The path to the WebhookManagerConfiguration file, "+
+		"the default configuration is used if not specified.")
+	fs.DurationVar(&o.ConfigReloadInterval, "config-reload-interval", defaultConfigReloadInterval,
+		"The interval of checking whether the --config file changed, the changed configuration is validated "+
+			"and applied without restart. Set to 0 to disable the reloading.")
+	fs.StringVar(&o.NodeTypeLabelKey, "node-type-label-key", "",
+		"The node label key which indicates the capacity type of the node (overrides nodeType.labelKey in --config).")
+	fs.StringVar(&o.DefaultDeploymentStrategy, "default-deployment-strategy", "",
+		"The default strategy of the Deployments (overrides defaultStrategies.deployment in --config).")
+	fs.StringVar(&o.DefaultStatefulSetStrategy, "default-statefulset-strategy", "",
+		"The default strategy of the StatefulSets (overrides defaultStrategies.statefulSet in --config).")
+	fs.StringSliceVar(&o.ExcludedNamespaces, "excluded-namespaces", nil,
+		"The namespaces whose objects are never handled (overrides excludedNamespaces in --config).")
+	fs.StringSliceVar(&o.IncludedNamespaces, "included-namespaces", nil,
+		"The only namespaces whose objects are handled if set (overrides includedNamespaces in --config).")
+	fs.BoolVar(&o.DryRun, "dry-run", false,
+		"Only record the placement of the new Pods without enforcing it (overrides dryRun.enabled in --config).")
 }
 
 func (o *Options) Validate() field.ErrorList {
@@ -47,6 +93,50 @@ func (o *Options) Validate() field.ErrorList {
 	if o.CertDir == "" {
 		errList = append(errList, field.Required(field.NewPath("cert-dir"), "must specify --cert-dir"))
 	}
+	if o.ConfigReloadInterval < 0 {
+		errList = append(errList, field.Invalid(field.NewPath("config-reload-interval"), o.ConfigReloadInterval,
+			"must be greater than or equal to 0"))
+	}
 
 	return errList
 }
+
+// LoadConfig loads the WebhookManagerConfiguration from --config, or the default one if not specified,
+// then overrides it with the flags explicitly set in fs.
+func (o *Options) LoadConfig(fs *pflag.FlagSet) (*config.WebhookManagerConfiguration, error) {
+	c := &config.WebhookManagerConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: config.APIVersion, Kind: config.Kind},
+	}
+	if o.ConfigFile != "" {
+		var err error
+		if c, err = config.LoadFromFile(o.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Override before defaulting, so that the affinity templates can be derived from the overridden label key.
+	if fs.Changed("node-type-label-key") {
+		c.NodeType.LabelKey = o.NodeTypeLabelKey
+	}
+	if fs.Changed("default-deployment-strategy") {
+		c.DefaultStrategies.Deployment = o.DefaultDeploymentStrategy
+	}
+	if fs.Changed("default-statefulset-strategy") {
+		c.DefaultStrategies.StatefulSet = o.DefaultStatefulSetStrategy
+	}
+	if fs.Changed("excluded-namespaces") {
+		c.ExcludedNamespaces = o.ExcludedNamespaces
+	}
+	if fs.Changed("included-namespaces") {
+		c.IncludedNamespaces = o.IncludedNamespaces
+	}
+	if fs.Changed("dry-run") {
+		c.DryRun.Enabled = o.DryRun
+	}
+
+	config.SetDefaults(c)
+	if errs := c.Validate(); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return c, nil
+}
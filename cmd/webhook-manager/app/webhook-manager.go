@@ -8,17 +8,28 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"vacant.sh/vmanager/cmd/webhook-manager/app/options"
+	"vacant.sh/vmanager/pkg/config"
+	nodeinterruption "vacant.sh/vmanager/pkg/controllers/node-interruption"
+	spotfallback "vacant.sh/vmanager/pkg/controllers/spot-fallback"
+	strategychange "vacant.sh/vmanager/pkg/controllers/strategy-change"
+	workloadstatus "vacant.sh/vmanager/pkg/controllers/workload-status"
 	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cronjob"
 	"vacant.sh/vmanager/pkg/webhook/deployment"
+	"vacant.sh/vmanager/pkg/webhook/job"
 	"vacant.sh/vmanager/pkg/webhook/pod"
+	"vacant.sh/vmanager/pkg/webhook/replicaset"
 	"vacant.sh/vmanager/pkg/webhook/statefulset"
+	webhookutils "vacant.sh/vmanager/pkg/webhook/utils"
 )
 
 const ComponentName = "vmanager-webhook-manager"
@@ -40,7 +51,26 @@ func NewWebhookManagerCommand(ctx context.Context) *cobra.Command {
 				return errs.ToAggregate()
 			}
 
-			return Run(ctx, opts)
+			// Load the configuration, the flags override the values in the configuration file.
+			loadConfig := func() (*config.WebhookManagerConfiguration, error) {
+				return opts.LoadConfig(pflag.CommandLine)
+			}
+			webhookManagerConfig, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			configHolder := config.NewHolder(webhookManagerConfig)
+
+			// Reload the configuration at runtime if it's from a file.
+			if opts.ConfigFile != "" && opts.ConfigReloadInterval > 0 {
+				watcher, err := config.NewWatcher(opts.ConfigFile, opts.ConfigReloadInterval, configHolder, loadConfig)
+				if err != nil {
+					return err
+				}
+				go watcher.Run(ctx.Done())
+			}
+
+			return Run(ctx, opts, configHolder)
 		},
 		Args: cobra.NoArgs,
 	}
@@ -48,7 +78,7 @@ func NewWebhookManagerCommand(ctx context.Context) *cobra.Command {
 	return cmd
 }
 
-func Run(ctx context.Context, opts *options.Options) error {
+func Run(ctx context.Context, opts *options.Options, configHolder *config.Holder) error {
 	klog.V(3).Infof("Start to run the %s.", ComponentName)
 
 	// Build the kube config by the option parameters.
@@ -57,8 +87,13 @@ func Run(ctx context.Context, opts *options.Options) error {
 		return err
 	}
 
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return err
+	}
+
 	// Build the webhook cache.
-	wc, err := cache.NewWebhookCache(kubeConfig)
+	wc, err := cache.NewWebhookCache(kubeConfig, configHolder)
 	if err != nil {
 		return err
 	}
@@ -74,37 +109,74 @@ func Run(ctx context.Context, opts *options.Options) error {
 			KeyName:  opts.KeyName,
 
 			TLSOpts: []func(*tls.Config){
-				func(config *tls.Config) {
-					config.MinVersion = tls.VersionTLS13
+				func(tlsConfig *tls.Config) {
+					tlsConfig.MinVersion = tls.VersionTLS13
 				},
 			},
 		}),
+		// The metrics server is started separately, since the manager is not started.
+		Metrics:        metricsserver.Options{BindAddress: "0"},
 		LeaderElection: false,
 	})
 	if err != nil {
 		return err
 	}
 
+	// Serve the metrics such as the placement decided in the dry-run mode.
+	metricsServer, err := metricsserver.NewServer(metricsserver.Options{BindAddress: opts.MetricsBindAddress}, kubeConfig, nil)
+	if err != nil {
+		return err
+	}
+	if metricsServer != nil {
+		go func() {
+			if err := metricsServer.Start(ctx); err != nil {
+				klog.Errorf("Failed to serve the metrics: %v", err)
+			}
+		}()
+	}
+
 	// Run the WebhookCache, wait for cache sync.
 	wc.Run(ctx.Done())
 
+	// Run the controllers which depend on the WebhookCache.
+	go spotfallback.NewController(kubeClient, wc, configHolder).Run(ctx)
+
+	nodeInterruptionController, err := nodeinterruption.NewController(kubeClient, wc, configHolder)
+	if err != nil {
+		return err
+	}
+	go nodeInterruptionController.Run(ctx)
+	go strategychange.NewController(kubeClient, wc).Run(ctx)
+	go workloadstatus.NewController(kubeClient, wc).Run(ctx)
+
 	klog.V(3).Infof("Registering webhook to %s.", ComponentName)
 	webhookServer := webhookManager.GetWebhookServer()
 	{
 		decoder := admission.NewDecoder(webhookManager.GetScheme())
 
-		webhookServer.Register("/mutate-pod", &webhook.Admission{
-			Handler: &pod.Mutating{Decoder: decoder, Cache: wc},
-		})
-		webhookServer.Register("/validate-pod", &webhook.Admission{
-			Handler: &pod.Validating{Decoder: decoder},
-		})
-		webhookServer.Register("/validate-deployment", &webhook.Admission{
-			Handler: &deployment.Validating{Decoder: decoder},
-		})
-		webhookServer.Register("/validate-statefulset", &webhook.Admission{
-			Handler: &statefulset.Validating{Decoder: decoder},
-		})
+		// The deadline of the API server is only passed by the timeout query parameter, the lookups of the cache
+		// leave the backoff.deadlineMargin before it.
+		webhookServer.Register("/mutate-pod", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &pod.Mutating{Decoder: decoder, Cache: wc, Config: configHolder},
+		}))
+		webhookServer.Register("/validate-pod", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &pod.Validating{Decoder: decoder, NamespaceFilter: wc},
+		}))
+		webhookServer.Register("/validate-deployment", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &deployment.Validating{Decoder: decoder, NamespaceFilter: wc, KubeClient: kubeClient},
+		}))
+		webhookServer.Register("/validate-replicaset", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &replicaset.Validating{Decoder: decoder, NamespaceFilter: wc, KubeClient: kubeClient},
+		}))
+		webhookServer.Register("/validate-statefulset", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &statefulset.Validating{Decoder: decoder, NamespaceFilter: wc, KubeClient: kubeClient},
+		}))
+		webhookServer.Register("/validate-job", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &job.Validating{Decoder: decoder, NamespaceFilter: wc},
+		}))
+		webhookServer.Register("/validate-cronjob", webhookutils.WithRequestDeadline(&webhook.Admission{
+			Handler: &cronjob.Validating{Decoder: decoder, NamespaceFilter: wc},
+		}))
 	}
 
 	// Block until err or context is done.
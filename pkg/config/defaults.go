@@ -0,0 +1,148 @@
+package config
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	nodetype "vacant.sh/vmanager/pkg/definitions/node-type"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+const (
+	defaultSpotPreferenceWeight = 10
+
+	defaultBackoffDuration = 100 * time.Millisecond
+	defaultBackoffFactor   = 1.0
+	defaultBackoffJitter   = 0.1
+	defaultBackoffSteps    = 5
+	// Half of the 3s webhook timeout.
+	defaultBackoffTimeout        = 1500 * time.Millisecond
+	defaultBackoffDeadlineMargin = 500 * time.Millisecond
+
+	defaultCacheResyncPeriod = 5 * time.Minute
+
+	defaultSpotFallbackUnschedulableTimeout = 5 * time.Minute
+	defaultSpotFallbackCheckInterval        = 30 * time.Second
+	defaultSpotFallbackOnDemandBiasDuration = 10 * time.Minute
+
+	defaultNodeInterruptionOnDemandBiasDuration = 10 * time.Minute
+)
+
+// defaultNodeInterruptionTaintKeys are the taints added by the AWS Node Termination Handler and Karpenter
+// before a node is interrupted.
+var defaultNodeInterruptionTaintKeys = []string{
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/rebalance-recommendation",
+	"karpenter.sh/disruption",
+	"karpenter.sh/disrupted",
+}
+
+// NewDefaultConfiguration returns a WebhookManagerConfiguration with all the fields defaulted.
+func NewDefaultConfiguration() *WebhookManagerConfiguration {
+	c := &WebhookManagerConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+	}
+	SetDefaults(c)
+	return c
+}
+
+// SetDefaults fills the unset fields of the WebhookManagerConfiguration except the apiVersion and kind,
+// which must always be specified in the configuration file.
+func SetDefaults(c *WebhookManagerConfiguration) {
+	if c.NodeType.LabelKey == "" {
+		c.NodeType.LabelKey = nodetype.NodeTypeLabelKey
+	}
+	if c.NodeType.OnDemandValue == "" {
+		c.NodeType.OnDemandValue = string(nodetype.NodeTypeOnDemand)
+	}
+	if c.NodeType.SpotValue == "" {
+		c.NodeType.SpotValue = string(nodetype.NodeTypeSpot)
+	}
+
+	// The affinity templates are built from the NodeType, so they must be defaulted after it.
+	if c.Affinity.OnDemand == nil {
+		c.Affinity.OnDemand = &corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{
+					Key:      c.NodeType.LabelKey,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{c.NodeType.OnDemandValue},
+				},
+			},
+		}
+	}
+	if c.Affinity.Spot == nil {
+		c.Affinity.Spot = &corev1.PreferredSchedulingTerm{
+			Weight: defaultSpotPreferenceWeight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{
+						Key:      c.NodeType.LabelKey,
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{c.NodeType.SpotValue},
+					},
+				},
+			},
+		}
+	}
+
+	if c.Backoff.Duration.Duration == 0 {
+		c.Backoff.Duration = metav1.Duration{Duration: defaultBackoffDuration}
+	}
+	if c.Backoff.Factor == 0 {
+		c.Backoff.Factor = defaultBackoffFactor
+	}
+	if c.Backoff.Jitter == nil {
+		c.Backoff.Jitter = ptr.To(defaultBackoffJitter)
+	}
+	if c.Backoff.Steps == 0 {
+		c.Backoff.Steps = defaultBackoffSteps
+	}
+	if c.Backoff.Timeout.Duration == 0 {
+		c.Backoff.Timeout = metav1.Duration{Duration: defaultBackoffTimeout}
+	}
+	if c.Backoff.DeadlineMargin.Duration == 0 {
+		c.Backoff.DeadlineMargin = metav1.Duration{Duration: defaultBackoffDeadlineMargin}
+	}
+
+	if c.CacheResyncPeriod.Duration == 0 {
+		c.CacheResyncPeriod = metav1.Duration{Duration: defaultCacheResyncPeriod}
+	}
+
+	if c.SpotFallback.UnschedulableTimeout.Duration == 0 {
+		c.SpotFallback.UnschedulableTimeout = metav1.Duration{Duration: defaultSpotFallbackUnschedulableTimeout}
+	}
+	if c.SpotFallback.CheckInterval.Duration == 0 {
+		c.SpotFallback.CheckInterval = metav1.Duration{Duration: defaultSpotFallbackCheckInterval}
+	}
+	if c.SpotFallback.OnDemandBiasDuration.Duration == 0 {
+		c.SpotFallback.OnDemandBiasDuration = metav1.Duration{Duration: defaultSpotFallbackOnDemandBiasDuration}
+	}
+
+	if c.NodeInterruption.TaintKeys == nil {
+		c.NodeInterruption.TaintKeys = append([]string{}, defaultNodeInterruptionTaintKeys...)
+	}
+	if c.NodeInterruption.OnDemandBiasDuration.Duration == 0 {
+		c.NodeInterruption.OnDemandBiasDuration = metav1.Duration{Duration: defaultNodeInterruptionOnDemandBiasDuration}
+	}
+
+	if c.DefaultStrategies.Deployment == "" {
+		c.DefaultStrategies.Deployment = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	}
+	if c.DefaultStrategies.StatefulSet == "" {
+		c.DefaultStrategies.StatefulSet = optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand
+	}
+	if c.DefaultStrategies.SingleReplicaStatefulSet == "" {
+		c.DefaultStrategies.SingleReplicaStatefulSet = optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand
+	}
+	if c.DefaultStrategies.Job == "" {
+		// The batch workloads tolerate the interruptions by retrying, they are ideal for spot.
+		c.DefaultStrategies.Job = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	}
+	if c.DefaultStrategies.Generic == "" {
+		c.DefaultStrategies.Generic = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestSetDefaultsBackoffJitter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		data   string
+		expect float64
+	}{
+		{name: "unset", data: "backoff: {}", expect: defaultBackoffJitter},
+		{name: "disabled", data: "backoff:\n  jitter: 0", expect: 0},
+		{name: "set", data: "backoff:\n  jitter: 0.5", expect: 0.5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Load([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("failed to load the configuration: %v", err)
+			}
+			SetDefaults(c)
+
+			if c.Backoff.Jitter == nil || *c.Backoff.Jitter != tc.expect {
+				t.Errorf("expect jitter %v, got %v", tc.expect, c.Backoff.Jitter)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Holder holds the active WebhookManagerConfiguration, which can be swapped at runtime.
+// The configuration returned by Get must be treated as read-only.
+type Holder struct {
+	current atomic.Pointer[WebhookManagerConfiguration]
+
+	mutex     sync.Mutex
+	listeners []func(c *WebhookManagerConfiguration)
+}
+
+func NewHolder(c *WebhookManagerConfiguration) *Holder {
+	h := &Holder{}
+	h.current.Store(c)
+	return h
+}
+
+// Get returns the active configuration.
+func (h *Holder) Get() *WebhookManagerConfiguration {
+	return h.current.Load()
+}
+
+// Set swaps the active configuration atomically, then notifies the listeners.
+func (h *Holder) Set(c *WebhookManagerConfiguration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.current.Store(c)
+	for _, listener := range h.listeners {
+		listener(c)
+	}
+}
+
+// AddListener registers a func which will be called after each Set with the new configuration.
+func (h *Holder) AddListener(listener func(c *WebhookManagerConfiguration)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.listeners = append(h.listeners, listener)
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadFromFile reads the WebhookManagerConfiguration from a YAML or JSON file.
+func LoadFromFile(path string) (*WebhookManagerConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	c, err := Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Load decodes the WebhookManagerConfiguration and rejects the unknown fields,
+// the unset fields are left empty so that the caller can override them before SetDefaults.
+func Load(data []byte) (*WebhookManagerConfiguration, error) {
+	c := &WebhookManagerConfiguration{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// GroupName is the group of the WebhookManagerConfiguration file.
+	GroupName = "vmanager.vacant.sh"
+	// Version is the current version of the WebhookManagerConfiguration file.
+	Version = "v1alpha1"
+	// Kind is the kind of the WebhookManagerConfiguration file.
+	Kind = "WebhookManagerConfiguration"
+)
+
+// APIVersion is the full apiVersion expected in the configuration file.
+var APIVersion = GroupName + "/" + Version
+
+// WebhookManagerConfiguration holds the runtime settings of the webhook-manager,
+// it is loaded from the file specified by --config.
+type WebhookManagerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// NodeType defines how the on-demand and spot nodes are labeled in the cluster.
+	NodeType NodeTypeConfiguration `json:"nodeType"`
+	// Affinity defines the node affinity templates applied to the new Pods.
+	Affinity AffinityConfiguration `json:"affinity"`
+	// Backoff defines how to retry when the workload of a new Pod is not in the cache yet.
+	Backoff BackoffConfiguration `json:"backoff"`
+	// DefaultStrategies defines the strategy used when a workload doesn't specify one.
+	DefaultStrategies DefaultStrategiesConfiguration `json:"defaultStrategies"`
+	// CacheResyncPeriod is the period of rebuilding the scheduling info of the workloads from the cached Pods,
+	// which corrects the drift caused by the missed events. It's not reloaded at runtime.
+	CacheResyncPeriod metav1.Duration `json:"cacheResyncPeriod"`
+	// SpotFallback defines when the unschedulable spot Pods fall back to on-demand.
+	SpotFallback SpotFallbackConfiguration `json:"spotFallback"`
+	// NodeInterruption defines how to detect the interrupted spot nodes, whose Pods are evicted in advance.
+	NodeInterruption NodeInterruptionConfiguration `json:"nodeInterruption"`
+	// ExcludedNamespaces are the namespaces whose objects are never handled, the Pods are not mutated,
+	// the workloads are not validated, and they are not cached. The namespaces removed at runtime are
+	// cached after restarting.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// IncludedNamespaces are the only namespaces whose objects are handled if it's not empty,
+	// the ExcludedNamespaces take precedence.
+	IncludedNamespaces []string `json:"includedNamespaces,omitempty"`
+	// NamespaceSelector selects the namespaces whose objects are handled by their labels, such as
+	// vacant.sh/optimize-scheduling-enabled=true. It's also set as the namespaceSelector of the webhooks.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// DryRun defines which Pods only record the placement decided for them instead of enforcing it.
+	DryRun DryRunConfiguration `json:"dryRun"`
+}
+
+type NodeTypeConfiguration struct {
+	// LabelKey is the node label key which indicates the capacity type of the node.
+	LabelKey string `json:"labelKey"`
+	// OnDemandValue is the LabelKey value of the on-demand nodes.
+	OnDemandValue string `json:"onDemandValue"`
+	// SpotValue is the LabelKey value of the spot nodes.
+	SpotValue string `json:"spotValue"`
+}
+
+type AffinityConfiguration struct {
+	// OnDemand is appended to the required node affinity of the Pods which must be on on-demand nodes.
+	// Default to the LabelKey In [OnDemandValue] of NodeType.
+	OnDemand *corev1.NodeSelectorTerm `json:"onDemand,omitempty"`
+	// Spot is appended to the preferred node affinity of the Pods which prefer the spot nodes.
+	// Default to the LabelKey In [SpotValue] of NodeType with weight 10. The weight is added to the score of the
+	// spot nodes among the other scoring plugins, raise it to keep the spot Pods off the on-demand nodes with
+	// more free resources.
+	Spot *corev1.PreferredSchedulingTerm `json:"spot,omitempty"`
+}
+
+type BackoffConfiguration struct {
+	// Duration is the initial wait duration between two retries.
+	Duration metav1.Duration `json:"duration"`
+	// Factor multiplies the Duration on each retry.
+	Factor float64 `json:"factor"`
+	// Jitter adds a random duration of up to Jitter*Duration on each retry, 0 disables it.
+	// Default to 0.1 if not specified.
+	Jitter *float64 `json:"jitter,omitempty"`
+	// Steps is the maximum number of the lookups.
+	Steps int `json:"steps"`
+	// Timeout is the maximum duration of the lookups for a new Pod, it should be less than the webhook timeout.
+	Timeout metav1.Duration `json:"timeout"`
+	// DeadlineMargin is the duration reserved before the deadline of the admission request,
+	// the lookups stop and fall back to unset when the deadline approaches.
+	DeadlineMargin metav1.Duration `json:"deadlineMargin"`
+}
+
+type DefaultStrategiesConfiguration struct {
+	// Deployment is the default strategy of the Deployments and the standalone ReplicaSets.
+	Deployment string `json:"deployment"`
+	// StatefulSet is the default strategy of the StatefulSets which have more than one replica.
+	StatefulSet string `json:"statefulSet"`
+	// SingleReplicaStatefulSet is the default strategy of the StatefulSets which have only one replica.
+	SingleReplicaStatefulSet string `json:"singleReplicaStatefulSet"`
+	// Job is the default strategy of the Jobs, including the ones created by CronJobs.
+	Job string `json:"job"`
+	// Generic is the default strategy of the other scalable workloads, such as Argo Rollouts and OpenKruise CloneSets.
+	Generic string `json:"generic"`
+}
+
+// SpotFallbackConfiguration defines the fallback of the spot Pods. Since the spot nodes are only preferred,
+// the scheduler places a spot Pod on an on-demand node by itself if it fits. So a spot Pod is only unschedulable
+// when it can't run on the on-demand nodes either, such as when it only tolerates the taints of the spot nodes,
+// or when the node autoscaler is waiting for the spot capacity. The fallback helps in these cases, since the
+// replacement requires the on-demand nodes, which the node autoscaler provisions.
+type SpotFallbackConfiguration struct {
+	// UnschedulableTimeout is how long a spot Pod can stay unschedulable before it's recreated,
+	// only the Pods of the workloads labeled with vacant.sh/optimize-scheduling-spot-fallback=true are recreated.
+	UnschedulableTimeout metav1.Duration `json:"unschedulableTimeout"`
+	// CheckInterval is the interval of checking the pending spot Pods.
+	CheckInterval metav1.Duration `json:"checkInterval"`
+	// OnDemandBiasDuration is how long the new Pods of the workload are placed on on-demand instead of spot
+	// after a Pod fell back.
+	OnDemandBiasDuration metav1.Duration `json:"onDemandBiasDuration"`
+}
+
+type NodeInterruptionConfiguration struct {
+	// TaintKeys are the taint keys which signal that a spot node is going to be interrupted,
+	// such as the ones added by the AWS Node Termination Handler or Karpenter.
+	TaintKeys []string `json:"taintKeys,omitempty"`
+	// IgnoreCordon disables treating a cordoned spot node as interrupted.
+	IgnoreCordon bool `json:"ignoreCordon,omitempty"`
+	// OnDemandBiasDuration is how long the new Pods of the affected workloads are placed on on-demand instead of spot.
+	OnDemandBiasDuration metav1.Duration `json:"onDemandBiasDuration"`
+}
+
+// DryRunConfiguration defines the Pods in the dry-run mode, whose placement is decided and recorded in the
+// annotation vacant.sh/dry-run-affinity, the metrics and the logs, but the affinity and the label are not added.
+// A workload can also be put in the dry-run mode by vacant.sh/optimize-scheduling-dry-run=true.
+type DryRunConfiguration struct {
+	// Enabled puts the Pods of all the namespaces in the dry-run mode.
+	Enabled bool `json:"enabled,omitempty"`
+	// Namespaces are the namespaces whose Pods are in the dry-run mode.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// StrategyFor returns the default strategy of the workload.
+func (d *DefaultStrategiesConfiguration) StrategyFor(workloadType string, replicaNum int) string {
+	switch workloadType {
+	case "Deployment", "ReplicaSet":
+		return d.Deployment
+	case "StatefulSet":
+		if replicaNum == 1 {
+			return d.SingleReplicaStatefulSet
+		}
+		return d.StatefulSet
+	case "Job":
+		return d.Job
+	}
+	return d.Generic
+}
+
+// IsNamespaceExcluded returns true if the namespace is in the ExcludedNamespaces, or not in the IncludedNamespaces.
+func (c *WebhookManagerConfiguration) IsNamespaceExcluded(namespace string) bool {
+	for _, excluded := range c.ExcludedNamespaces {
+		if excluded == namespace {
+			return true
+		}
+	}
+	if len(c.IncludedNamespaces) == 0 {
+		return false
+	}
+	for _, included := range c.IncludedNamespaces {
+		if included == namespace {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesNamespaceSelector returns true if the labels of the namespace match the NamespaceSelector.
+func (c *WebhookManagerConfiguration) MatchesNamespaceSelector(namespaceLabels map[string]string) bool {
+	if c.NamespaceSelector == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(c.NamespaceSelector)
+	if err != nil {
+		// This should never happen, the selector is validated.
+		return false
+	}
+	return selector.Matches(labels.Set(namespaceLabels))
+}
+
+// WebhookNamespaceSelector returns the namespaceSelector of the webhooks, which selects the namespaces not excluded
+// by their names, and matching the NamespaceSelector. The names are matched by the kubernetes.io/metadata.name label.
+func (c *WebhookManagerConfiguration) WebhookNamespaceSelector() *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{}
+	if c.NamespaceSelector != nil {
+		selector = c.NamespaceSelector.DeepCopy()
+	}
+
+	if len(c.ExcludedNamespaces) > 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   append([]string{}, c.ExcludedNamespaces...),
+		})
+	}
+	if len(c.IncludedNamespaces) > 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   append([]string{}, c.IncludedNamespaces...),
+		})
+	}
+	return selector
+}
+
+// IsDryRun returns true if the Pods of the namespace are in the dry-run mode.
+func (d *DryRunConfiguration) IsDryRun(namespace string) bool {
+	if d.Enabled {
+		return true
+	}
+	for _, dryRunNamespace := range d.Namespaces {
+		if dryRunNamespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNodeInterrupted returns true if the node is tainted by any of the TaintKeys, or cordoned unless IgnoreCordon.
+func (n *NodeInterruptionConfiguration) IsNodeInterrupted(node *corev1.Node) bool {
+	if node.Spec.Unschedulable && !n.IgnoreCordon {
+		return true
+	}
+
+	for _, taint := range node.Spec.Taints {
+		for _, taintKey := range n.TaintKeys {
+			if taint.Key == taintKey {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+// Validate checks whether the WebhookManagerConfiguration is valid, it should be called after SetDefaults.
+func (c *WebhookManagerConfiguration) Validate() field.ErrorList {
+	errList := field.ErrorList{}
+
+	if c.APIVersion != APIVersion {
+		errList = append(errList, field.NotSupported(field.NewPath("apiVersion"), c.APIVersion, []string{APIVersion}))
+	}
+	if c.Kind != Kind {
+		errList = append(errList, field.NotSupported(field.NewPath("kind"), c.Kind, []string{Kind}))
+	}
+
+	// Validate the node type label.
+	nodeTypePath := field.NewPath("nodeType")
+	for _, msg := range validation.IsQualifiedName(c.NodeType.LabelKey) {
+		errList = append(errList, field.Invalid(nodeTypePath.Child("labelKey"), c.NodeType.LabelKey, msg))
+	}
+	for _, msg := range validation.IsValidLabelValue(c.NodeType.OnDemandValue) {
+		errList = append(errList, field.Invalid(nodeTypePath.Child("onDemandValue"), c.NodeType.OnDemandValue, msg))
+	}
+	for _, msg := range validation.IsValidLabelValue(c.NodeType.SpotValue) {
+		errList = append(errList, field.Invalid(nodeTypePath.Child("spotValue"), c.NodeType.SpotValue, msg))
+	}
+	if c.NodeType.OnDemandValue == c.NodeType.SpotValue {
+		errList = append(errList, field.Invalid(nodeTypePath.Child("spotValue"), c.NodeType.SpotValue,
+			"must be different from onDemandValue"))
+	}
+
+	// Validate the affinity templates.
+	affinityPath := field.NewPath("affinity")
+	if c.Affinity.OnDemand == nil || len(c.Affinity.OnDemand.MatchExpressions)+len(c.Affinity.OnDemand.MatchFields) == 0 {
+		errList = append(errList, field.Required(affinityPath.Child("onDemand"), "must have at least one requirement"))
+	}
+	if c.Affinity.Spot == nil || len(c.Affinity.Spot.Preference.MatchExpressions)+len(c.Affinity.Spot.Preference.MatchFields) == 0 {
+		errList = append(errList, field.Required(affinityPath.Child("spot"), "must have at least one requirement"))
+	} else if c.Affinity.Spot.Weight < 1 || c.Affinity.Spot.Weight > 100 {
+		errList = append(errList, field.Invalid(affinityPath.Child("spot", "weight"), c.Affinity.Spot.Weight,
+			"must be in the range 1-100"))
+	}
+
+	// Validate the backoff.
+	backoffPath := field.NewPath("backoff")
+	if c.Backoff.Duration.Duration <= 0 {
+		errList = append(errList, field.Invalid(backoffPath.Child("duration"), c.Backoff.Duration.String(),
+			"must be greater than 0"))
+	}
+	if c.Backoff.Factor < 1.0 {
+		errList = append(errList, field.Invalid(backoffPath.Child("factor"), c.Backoff.Factor,
+			"must be greater than or equal to 1.0"))
+	}
+	if c.Backoff.Jitter != nil && *c.Backoff.Jitter < 0 {
+		errList = append(errList, field.Invalid(backoffPath.Child("jitter"), *c.Backoff.Jitter,
+			"must be greater than or equal to 0"))
+	}
+	if c.Backoff.Steps < 1 {
+		errList = append(errList, field.Invalid(backoffPath.Child("steps"), c.Backoff.Steps,
+			"must be greater than 0"))
+	}
+	if c.Backoff.Timeout.Duration <= 0 {
+		errList = append(errList, field.Invalid(backoffPath.Child("timeout"), c.Backoff.Timeout.String(),
+			"must be greater than 0"))
+	}
+	if c.Backoff.DeadlineMargin.Duration < 0 {
+		errList = append(errList, field.Invalid(backoffPath.Child("deadlineMargin"), c.Backoff.DeadlineMargin.String(),
+			"must be greater than or equal to 0"))
+	}
+
+	if c.CacheResyncPeriod.Duration <= 0 {
+		errList = append(errList, field.Invalid(field.NewPath("cacheResyncPeriod"), c.CacheResyncPeriod.String(),
+			"must be greater than 0"))
+	}
+
+	// Validate the spot fallback.
+	spotFallbackPath := field.NewPath("spotFallback")
+	for name, duration := range map[string]metav1.Duration{
+		"unschedulableTimeout": c.SpotFallback.UnschedulableTimeout,
+		"checkInterval":        c.SpotFallback.CheckInterval,
+		"onDemandBiasDuration": c.SpotFallback.OnDemandBiasDuration,
+	} {
+		if duration.Duration <= 0 {
+			errList = append(errList, field.Invalid(spotFallbackPath.Child(name), duration.String(),
+				"must be greater than 0"))
+		}
+	}
+
+	// Validate the node interruption.
+	nodeInterruptionPath := field.NewPath("nodeInterruption")
+	for i, taintKey := range c.NodeInterruption.TaintKeys {
+		for _, msg := range validation.IsQualifiedName(taintKey) {
+			errList = append(errList, field.Invalid(nodeInterruptionPath.Child("taintKeys").Index(i), taintKey, msg))
+		}
+	}
+	if c.NodeInterruption.OnDemandBiasDuration.Duration <= 0 {
+		errList = append(errList, field.Invalid(nodeInterruptionPath.Child("onDemandBiasDuration"),
+			c.NodeInterruption.OnDemandBiasDuration.String(), "must be greater than 0"))
+	}
+
+	// Validate the default strategies in the order of the fields, the custom strategy can't be a default
+	// since it needs a count.
+	defaultStrategiesPath := field.NewPath("defaultStrategies")
+	for _, defaultStrategy := range []struct {
+		name     string
+		strategy string
+	}{
+		{"deployment", c.DefaultStrategies.Deployment},
+		{"statefulSet", c.DefaultStrategies.StatefulSet},
+		{"singleReplicaStatefulSet", c.DefaultStrategies.SingleReplicaStatefulSet},
+		{"job", c.DefaultStrategies.Job},
+		{"generic", c.DefaultStrategies.Generic},
+	} {
+		name, strategy := defaultStrategy.name, defaultStrategy.strategy
+		if !optimizescheduling.OptimizeSchedulingStrategies.Has(strategy) ||
+			strategy == optimizescheduling.OptimizeSchedulingStrategyCustom {
+			errList = append(errList, field.Invalid(defaultStrategiesPath.Child(name), strategy,
+				fmt.Sprintf("value must be in %v except %s.", optimizescheduling.OptimizeSchedulingStrategies.List(),
+					optimizescheduling.OptimizeSchedulingStrategyCustom)))
+		}
+	}
+
+	// Validate the excluded namespaces.
+	excludedNamespacesPath := field.NewPath("excludedNamespaces")
+	for i, namespace := range c.ExcludedNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errList = append(errList, field.Invalid(excludedNamespacesPath.Index(i), namespace, msg))
+		}
+	}
+
+	// Validate the included namespaces and the namespace selector.
+	includedNamespacesPath := field.NewPath("includedNamespaces")
+	for i, namespace := range c.IncludedNamespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errList = append(errList, field.Invalid(includedNamespacesPath.Index(i), namespace, msg))
+		}
+	}
+	errList = append(errList, metav1validation.ValidateLabelSelector(c.NamespaceSelector,
+		metav1validation.LabelSelectorValidationOptions{}, field.NewPath("namespaceSelector"))...)
+
+	// Validate the dry-run namespaces.
+	dryRunNamespacesPath := field.NewPath("dryRun", "namespaces")
+	for i, namespace := range c.DryRun.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errList = append(errList, field.Invalid(dryRunNamespacesPath.Index(i), namespace, msg))
+		}
+	}
+
+	return errList
+}
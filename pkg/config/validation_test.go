@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+func TestValidateDefaultStrategiesOrder(t *testing.T) {
+	c := NewDefaultConfiguration()
+	c.DefaultStrategies = DefaultStrategiesConfiguration{
+		Deployment:               optimizescheduling.OptimizeSchedulingStrategyCustom,
+		StatefulSet:              "unknown",
+		SingleReplicaStatefulSet: "unknown",
+		Job:                      "unknown",
+		Generic:                  optimizescheduling.OptimizeSchedulingStrategyCustom,
+	}
+	expect := []string{
+		"defaultStrategies.deployment",
+		"defaultStrategies.statefulSet",
+		"defaultStrategies.singleReplicaStatefulSet",
+		"defaultStrategies.job",
+		"defaultStrategies.generic",
+	}
+
+	// The errors are reported in the same order every time.
+	for i := 0; i < 10; i++ {
+		var fields []string
+		for _, err := range c.Validate() {
+			fields = append(fields, err.Field)
+		}
+		if !reflect.DeepEqual(fields, expect) {
+			t.Fatalf("expect the errors of %v, got %v", expect, fields)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// Watcher reloads the configuration file when its content changes, and swaps the configuration of the Holder.
+// The file is polled instead of watched by inotify, since a mounted ConfigMap is updated by replacing a symlink.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	holder   *Holder
+	// load builds a validated configuration from the file, the flag overrides should be applied here.
+	load func() (*WebhookManagerConfiguration, error)
+
+	lastContent []byte
+}
+
+func NewWatcher(path string, interval time.Duration, holder *Holder,
+	load func() (*WebhookManagerConfiguration, error)) (*Watcher, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		path:        path,
+		interval:    interval,
+		holder:      holder,
+		load:        load,
+		lastContent: content,
+	}, nil
+}
+
+// Run polls the configuration file until the stopCh is closed.
+func (w *Watcher) Run(stopCh <-chan struct{}) {
+	klog.V(2).Infof("Config watcher start to watch %s.", w.path)
+	wait.Until(w.reload, w.interval, stopCh)
+}
+
+func (w *Watcher) reload() {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		klog.Errorf("Failed to read config file %s: %v", w.path, err)
+		return
+	}
+	if bytes.Equal(content, w.lastContent) {
+		return
+	}
+	// Record the content first, so that an invalid file is only reported once.
+	w.lastContent = content
+
+	newConfig, err := w.load()
+	if err != nil {
+		klog.Errorf("Rejected the new config of %s, keep using the current one: %v", w.path, err)
+		return
+	}
+
+	oldConfig := w.holder.Get()
+	diff := cmp.Diff(oldConfig, newConfig)
+	if diff == "" {
+		klog.V(3).Infof("Config file %s changed without any effective change.", w.path)
+		return
+	}
+
+	klog.Infof("Reloading config from %s, diff (-old +new):\n%s", w.path, diff)
+	w.holder.Set(newConfig)
+}
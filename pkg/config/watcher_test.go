@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const watcherTestConfig = `apiVersion: vmanager.vacant.sh/v1alpha1
+kind: WebhookManagerConfiguration
+nodeType:
+  labelKey: %s
+`
+
+// newTestWatcher writes the configuration file with the node label key, and returns the watcher of the file
+// with the holder of the loaded configuration, and the configurations notified to the listener.
+func newTestWatcher(t *testing.T, labelKey string) (*Watcher, *Holder, *[]*WebhookManagerConfiguration) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestConfig(t, path, strings.Replace(watcherTestConfig, "%s", labelKey, 1))
+
+	load := func() (*WebhookManagerConfiguration, error) {
+		c, err := LoadFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		SetDefaults(c)
+		if errs := c.Validate(); len(errs) > 0 {
+			return nil, errs.ToAggregate()
+		}
+		return c, nil
+	}
+
+	c, err := load()
+	if err != nil {
+		t.Fatalf("failed to load the configuration: %v", err)
+	}
+	holder := NewHolder(c)
+	var notified []*WebhookManagerConfiguration
+	holder.AddListener(func(c *WebhookManagerConfiguration) {
+		notified = append(notified, c)
+	})
+
+	w, err := NewWatcher(path, time.Second, holder, load)
+	if err != nil {
+		t.Fatalf("failed to build the watcher: %v", err)
+	}
+	return w, holder, &notified
+}
+
+func writeTestConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write the configuration: %v", err)
+	}
+}
+
+// captureLogs redirects the logs of klog to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	if err := flags.Set("logtostderr", "false"); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	klog.SetOutput(buf)
+	t.Cleanup(func() {
+		_ = flags.Set("logtostderr", "true")
+		klog.SetOutput(os.Stderr)
+	})
+	return buf
+}
+
+func TestWatcherReloadsValidConfig(t *testing.T) {
+	w, holder, notified := newTestWatcher(t, "example.com/capacity")
+
+	writeTestConfig(t, w.path, strings.Replace(watcherTestConfig, "%s", "example.com/lifecycle", 1))
+	w.reload()
+
+	if labelKey := holder.Get().NodeType.LabelKey; labelKey != "example.com/lifecycle" {
+		t.Errorf("expect the label key example.com/lifecycle, got %s", labelKey)
+	}
+	if len(*notified) != 1 || (*notified)[0] != holder.Get() {
+		t.Errorf("expect the listener notified once with the new config, got %d notifications", len(*notified))
+	}
+}
+
+func TestWatcherRejectsInvalidConfig(t *testing.T) {
+	logs := captureLogs(t)
+	w, holder, notified := newTestWatcher(t, "example.com/capacity")
+	current := holder.Get()
+
+	// The label key is not a qualified name.
+	writeTestConfig(t, w.path, strings.Replace(watcherTestConfig, "%s", "-invalid-", 1))
+	w.reload()
+	klog.Flush()
+
+	if holder.Get() != current {
+		t.Errorf("expect the current config kept, got the label key %s", holder.Get().NodeType.LabelKey)
+	}
+	if len(*notified) != 0 {
+		t.Errorf("expect no notifications, got %d", len(*notified))
+	}
+	if !strings.Contains(logs.String(), "Rejected the new config of "+w.path) {
+		t.Errorf("expect the rejection logged, got:\n%s", logs.String())
+	}
+
+	// The same invalid file is only reported once.
+	logs.Reset()
+	w.reload()
+	klog.Flush()
+	if strings.Contains(logs.String(), "Rejected") {
+		t.Errorf("expect the unchanged invalid file not reported again, got:\n%s", logs.String())
+	}
+}
+
+func TestWatcherIgnoresUnchangedConfig(t *testing.T) {
+	w, holder, notified := newTestWatcher(t, "example.com/capacity")
+	current := holder.Get()
+
+	// Neither the same content nor a change without any effect is notified.
+	w.reload()
+	writeTestConfig(t, w.path, "# The node type of the cluster.\n"+
+		strings.Replace(watcherTestConfig, "%s", "example.com/capacity", 1))
+	w.reload()
+
+	if holder.Get() != current {
+		t.Errorf("expect the current config kept")
+	}
+	if len(*notified) != 0 {
+		t.Errorf("expect no notifications, got %d", len(*notified))
+	}
+}
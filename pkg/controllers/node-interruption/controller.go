@@ -0,0 +1,287 @@
+package node_interruption
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	informercorev1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"vacant.sh/vmanager/pkg/config"
+	controllerutils "vacant.sh/vmanager/pkg/controllers/utils"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+const (
+	podNodeNameIndex = "spec.nodeName"
+
+	// evictionRetryDelay is the delay before retrying the Pods which can't be evicted due to their PDB.
+	evictionRetryDelay = 5 * time.Second
+)
+
+// Controller watches the spot nodes, when a node is cordoned or tainted with a configured interruption taint,
+// it biases the workloads of the Pods on the node to on-demand and evicts the Pods in advance.
+// The Pods are evicted by the Eviction API so that the PodDisruptionBudgets are respected, and the Pods of
+// different workloads are evicted alternately so that a workload doesn't lose all its Pods at once.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	cache      cache.Interface
+	config     *config.Holder
+
+	informerFactory    informers.SharedInformerFactory
+	podInformerFactory informers.SharedInformerFactory
+	nodeInformer       informercorev1.NodeInformer
+	podInformer        informercorev1.PodInformer
+
+	queue workqueue.RateLimitingInterface
+	clock clock.PassiveClock
+
+	// biasExpiries are when the on-demand bias of the interrupted nodes expire, the bias is fixed when the node is
+	// handled for the first time, so that the retries blocked by the PDBs don't extend it. It's only accessed
+	// by the worker.
+	biasExpiries map[string]time.Time
+}
+
+func NewController(kubeClient kubernetes.Interface, wc cache.Interface, configHolder *config.Holder) (*Controller, error) {
+	c := &Controller{
+		kubeClient:      kubeClient,
+		cache:           wc,
+		config:          configHolder,
+		informerFactory: informers.NewSharedInformerFactory(kubeClient, 0),
+		queue: workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(
+			evictionRetryDelay, 5*time.Minute)),
+		clock:        clock.RealClock{},
+		biasExpiries: map[string]time.Time{},
+	}
+
+	c.nodeInformer = c.informerFactory.Core().V1().Nodes()
+	_, err := c.nodeInformer.Informer().AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueNode,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueueNode(newObj)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// We only care the Pods which have been marked by the webhook, they are indexed by their node.
+	podRequirement, err := labels.NewRequirement(podaffinity.PodAffinityLabelKey, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.podInformerFactory = informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labels.NewSelector().Add(*podRequirement).String()
+		}),
+		informers.WithTransform(controllerutils.TransformPod))
+	c.podInformer = c.podInformerFactory.Core().V1().Pods()
+	err = c.podInformer.Informer().AddIndexers(toolscache.Indexers{
+		podNodeNameIndex: func(obj interface{}) ([]string, error) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || pod.Spec.NodeName == "" {
+				return nil, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run handles the interrupted nodes until the ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	c.informerFactory.Start(ctx.Done())
+	c.podInformerFactory.Start(ctx.Done())
+	if !toolscache.WaitForCacheSync(ctx.Done(), c.nodeInformer.Informer().HasSynced, c.podInformer.Informer().HasSynced) {
+		klog.Error("Node interruption controller cache failed to sync.")
+		return
+	}
+
+	klog.V(2).Info("Node interruption controller start to run.")
+	go wait.UntilWithContext(ctx, c.worker, time.Second)
+
+	<-ctx.Done()
+}
+
+func (c *Controller) enqueueNode(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	activeConfig := c.config.Get()
+	// Only the spot nodes are handled, the on-demand nodes are drained by the usual maintenance.
+	if node.Labels[activeConfig.NodeType.LabelKey] != activeConfig.NodeType.SpotValue {
+		return
+	}
+	if !activeConfig.NodeInterruption.IsNodeInterrupted(node) {
+		return
+	}
+
+	c.queue.Add(node.Name)
+}
+
+func (c *Controller) worker(ctx context.Context) {
+	for c.processNextNode(ctx) {
+	}
+}
+
+func (c *Controller) processNextNode(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	nodeName := key.(string)
+	if err := c.handleInterruptedNode(ctx, nodeName); err != nil {
+		klog.V(3).Infof("Retry handling the interrupted node %s: %v", nodeName, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// handleInterruptedNode biases the workloads of the Pods on the node to on-demand, then evicts the Pods.
+// It returns an error if any Pod remains on the node, so that the node is retried later.
+func (c *Controller) handleInterruptedNode(ctx context.Context, nodeName string) error {
+	node, err := c.nodeInformer.Lister().Get(nodeName)
+	if apierrors.IsNotFound(err) {
+		delete(c.biasExpiries, nodeName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	activeConfig := c.config.Get()
+	if !activeConfig.NodeInterruption.IsNodeInterrupted(node) {
+		// The node has recovered, such as uncordoned.
+		delete(c.biasExpiries, nodeName)
+		return nil
+	}
+
+	objs, err := c.podInformer.Informer().GetIndexer().ByIndex(podNodeNameIndex, nodeName)
+	if err != nil {
+		return err
+	}
+
+	var pods []*corev1.Pod
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || !utils.IsPodLive(pod) {
+			continue
+		}
+		// The Pods labeled before their namespace was excluded are left to the usual maintenance.
+		if !c.cache.IsNamespaceManaged(pod.Namespace) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	if len(pods) == 0 {
+		delete(c.biasExpiries, nodeName)
+		return nil
+	}
+
+	klog.Infof("Spot node %s is interrupted, evicting %d pods.", nodeName, len(pods))
+
+	// Bias all the affected workloads before evicting, so that none of the replacements is placed on spot.
+	// The Pods found by the retries are biased until the same expiry.
+	biasExpiry, ok := c.biasExpiries[nodeName]
+	if !ok {
+		biasExpiry = c.clock.Now().Add(activeConfig.NodeInterruption.OnDemandBiasDuration.Duration)
+		c.biasExpiries[nodeName] = biasExpiry
+	}
+	if biasDuration := biasExpiry.Sub(c.clock.Now()); biasDuration > 0 {
+		for _, pod := range pods {
+			c.cache.BiasToOnDemand(pod, biasDuration)
+		}
+	}
+
+	blocked := 0
+	for _, pod := range orderPodsForEviction(pods) {
+		err := c.kubeClient.CoreV1().Pods(pod.Namespace).EvictV1(ctx, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+			DeleteOptions: &metav1.DeleteOptions{
+				Preconditions: metav1.NewUIDPreconditions(string(pod.UID)),
+			},
+		})
+		switch {
+		case err == nil:
+			klog.V(3).Infof("Evicted Pod %s/%s from the interrupted node %s.", pod.Namespace, pod.Name, nodeName)
+		case apierrors.IsNotFound(err):
+		case apierrors.IsTooManyRequests(err):
+			// The PodDisruptionBudget doesn't allow the eviction right now.
+			blocked++
+		default:
+			klog.Errorf("Failed to evict Pod %s/%s from the interrupted node %s: %v", pod.Namespace, pod.Name, nodeName, err)
+			blocked++
+		}
+	}
+
+	if blocked > 0 {
+		return fmt.Errorf("%d pods on node %s can't be evicted yet", blocked, nodeName)
+	}
+	return nil
+}
+
+// orderPodsForEviction interleaves the Pods of different workloads, so that the disruption is spread
+// across the workloads instead of taking down a whole workload first.
+func orderPodsForEviction(pods []*corev1.Pod) []*corev1.Pod {
+	podsByOwner := map[string][]*corev1.Pod{}
+	var owners []string
+	for _, pod := range pods {
+		owner := pod.Namespace + "/" + pod.Name
+		if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil {
+			owner = string(controllerRef.UID)
+		}
+		if _, ok := podsByOwner[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		podsByOwner[owner] = append(podsByOwner[owner], pod)
+	}
+
+	// Make the order stable between the retries.
+	sort.Strings(owners)
+	for _, owner := range owners {
+		sort.Slice(podsByOwner[owner], func(i, j int) bool {
+			return podsByOwner[owner][i].Name < podsByOwner[owner][j].Name
+		})
+	}
+
+	ordered := make([]*corev1.Pod, 0, len(pods))
+	for i := 0; len(ordered) < len(pods); i++ {
+		for _, owner := range owners {
+			if i < len(podsByOwner[owner]) {
+				ordered = append(ordered, podsByOwner[owner][i])
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,200 @@
+package node_interruption
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+)
+
+// fakeCache records when the bias of each Pod expires, and manages the namespaces other than the unmanaged ones.
+type fakeCache struct {
+	cache.Interface
+
+	clock               *clocktesting.FakeClock
+	biasExpires         map[string]time.Time
+	biasCalls           int
+	unmanagedNamespaces []string
+}
+
+func (f *fakeCache) IsNamespaceManaged(namespace string) bool {
+	return !slices.Contains(f.unmanagedNamespaces, namespace)
+}
+
+func (f *fakeCache) BiasToOnDemand(pod *corev1.Pod, duration time.Duration) {
+	f.biasExpires[pod.Name] = f.clock.Now().Add(duration)
+	f.biasCalls++
+}
+
+func newTestNode(cordoned bool) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "spot-node"},
+		Spec:       corev1.NodeSpec{Unschedulable: cordoned},
+	}
+}
+
+func newTestPod(name, ownerUID string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			UID:       types.UID(name),
+			Labels:    map[string]string{podaffinity.PodAffinityLabelKey: string(podaffinity.PodAffinitySpot)},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: ownerUID, UID: types.UID(ownerUID), Controller: ptr.To(true)},
+			},
+		},
+		Spec:   corev1.PodSpec{NodeName: "spot-node"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// newTestController returns the controller whose informers are filled with the objects instead of running,
+// and whose evictions are blocked by the PDBs while the evictionBlocked is true.
+func newTestController(t *testing.T, evictionBlocked *bool, objs ...runtime.Object) (*Controller, *fakeCache) {
+	t.Helper()
+
+	kubeClient := fake.NewSimpleClientset(objs...)
+	kubeClient.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		if *evictionBlocked {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		return true, nil, nil
+	})
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wc := &fakeCache{clock: fakeClock, biasExpires: map[string]time.Time{}}
+	c, err := NewController(kubeClient, wc, config.NewHolder(config.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("failed to build the controller: %v", err)
+	}
+	c.clock = fakeClock
+
+	for _, obj := range objs {
+		indexer := c.podInformer.Informer().GetIndexer()
+		if _, ok := obj.(*corev1.Node); ok {
+			indexer = c.nodeInformer.Informer().GetIndexer()
+		}
+		if err := indexer.Add(obj); err != nil {
+			t.Fatalf("failed to add the object: %v", err)
+		}
+	}
+	return c, wc
+}
+
+func TestBiasNotExtendedByRetries(t *testing.T) {
+	evictionBlocked := true
+	c, wc := newTestController(t, &evictionBlocked, newTestNode(true), newTestPod("web-1", "web"))
+	biasDuration := config.NewDefaultConfiguration().NodeInterruption.OnDemandBiasDuration.Duration
+	expectExpiry := wc.clock.Now().Add(biasDuration)
+
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err == nil {
+		t.Fatal("expect the blocked eviction to be retried")
+	}
+	if wc.biasExpires["web-1"] != expectExpiry {
+		t.Fatalf("expect the bias to expire at %v, got %v", expectExpiry, wc.biasExpires["web-1"])
+	}
+
+	// The retries blocked by the PDB keep the expiry.
+	wc.clock.Step(time.Minute)
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err == nil {
+		t.Fatal("expect the blocked eviction to be retried")
+	}
+	if wc.biasExpires["web-1"] != expectExpiry {
+		t.Fatalf("expect the bias to expire at %v, got %v", expectExpiry, wc.biasExpires["web-1"])
+	}
+
+	// The bias is not applied again after it expired.
+	wc.clock.Step(biasDuration)
+	calls := wc.biasCalls
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err == nil {
+		t.Fatal("expect the blocked eviction to be retried")
+	}
+	if wc.biasCalls != calls {
+		t.Fatalf("expect no bias after the expiry, got %d calls", wc.biasCalls-calls)
+	}
+
+	// A new interruption after the node recovered biases again.
+	if err := c.nodeInformer.Informer().GetIndexer().Update(newTestNode(false)); err != nil {
+		t.Fatalf("failed to update the node: %v", err)
+	}
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err != nil {
+		t.Fatalf("failed to handle the recovered node: %v", err)
+	}
+	if err := c.nodeInformer.Informer().GetIndexer().Update(newTestNode(true)); err != nil {
+		t.Fatalf("failed to update the node: %v", err)
+	}
+	evictionBlocked = false
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err != nil {
+		t.Fatalf("failed to handle the interrupted node: %v", err)
+	}
+	if expectExpiry = wc.clock.Now().Add(biasDuration); wc.biasExpires["web-1"] != expectExpiry {
+		t.Fatalf("expect the bias to expire at %v, got %v", expectExpiry, wc.biasExpires["web-1"])
+	}
+}
+
+func TestSkipUnmanagedNamespaces(t *testing.T) {
+	evictionBlocked := false
+	unmanagedPod := newTestPod("system-1", "system")
+	unmanagedPod.Namespace = "kube-system"
+	c, wc := newTestController(t, &evictionBlocked, newTestNode(true), newTestPod("web-1", "web"), unmanagedPod)
+	wc.unmanagedNamespaces = []string{"kube-system"}
+
+	if err := c.handleInterruptedNode(context.Background(), "spot-node"); err != nil {
+		t.Fatalf("failed to handle the interrupted node: %v", err)
+	}
+
+	var evicted []string
+	for _, action := range c.kubeClient.(*fake.Clientset).Actions() {
+		if action.GetVerb() == "create" && action.GetSubresource() == "eviction" {
+			evicted = append(evicted, action.GetNamespace())
+		}
+	}
+	if !slices.Equal(evicted, []string{"default"}) {
+		t.Errorf("expect only the Pod in the managed namespace evicted, got the namespaces %v", evicted)
+	}
+	if _, ok := wc.biasExpires[unmanagedPod.Name]; ok {
+		t.Errorf("expect the Pod in the unmanaged namespace not biased")
+	}
+}
+
+func TestOrderPodsForEviction(t *testing.T) {
+	pods := []*corev1.Pod{
+		newTestPod("web-2", "web"),
+		newTestPod("web-1", "web"),
+		newTestPod("web-3", "web"),
+		newTestPod("db-1", "db"),
+		newTestPod("db-0", "db"),
+	}
+
+	var got []string
+	for _, pod := range orderPodsForEviction(pods) {
+		got = append(got, pod.Name)
+	}
+	expect := []string{"db-0", "web-1", "db-1", "web-2", "web-3"}
+	if len(got) != len(expect) {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Fatalf("expect %v, got %v", expect, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package spot_fallback
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listercorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	"vacant.sh/vmanager/pkg/config"
+	controllerutils "vacant.sh/vmanager/pkg/controllers/utils"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+)
+
+// Controller recreates the spot Pods which stay unschedulable for too long, for example when the spot pool is
+// exhausted. The workload is biased to on-demand before the Pod is deleted, so that its replacement falls back
+// to on-demand. Only the workloads labeled with vacant.sh/optimize-scheduling-spot-fallback=true are handled.
+// The spot nodes are only preferred, see config.SpotFallbackConfiguration for when a spot Pod is unschedulable.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	cache      cache.Interface
+	config     *config.Holder
+
+	informerFactory informers.SharedInformerFactory
+	podLister       listercorev1.PodLister
+}
+
+func NewController(kubeClient kubernetes.Interface, wc cache.Interface, configHolder *config.Holder) *Controller {
+	// We only care the pending Pods which have been marked as spot by the webhook.
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labels.Set{podaffinity.PodAffinityLabelKey: string(podaffinity.PodAffinitySpot)}.String()
+			options.FieldSelector = fields.OneTermEqualSelector("status.phase", string(corev1.PodPending)).String()
+		}),
+		informers.WithTransform(controllerutils.TransformPod))
+
+	return &Controller{
+		kubeClient:      kubeClient,
+		cache:           wc,
+		config:          configHolder,
+		informerFactory: informerFactory,
+		podLister:       informerFactory.Core().V1().Pods().Lister(),
+	}
+}
+
+// Run checks the pending spot Pods periodically until the ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	c.informerFactory.Start(ctx.Done())
+	for informerType, ok := range c.informerFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			klog.Errorf("Spot fallback controller cache failed to sync: %v", informerType)
+			return
+		}
+	}
+
+	klog.V(2).Info("Spot fallback controller start to run.")
+	// Use the sliding loop so that the interval can be reloaded.
+	for {
+		c.checkPendingPods(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait.Jitter(c.config.Get().SpotFallback.CheckInterval.Duration, 0.1)):
+		}
+	}
+}
+
+func (c *Controller) checkPendingPods(ctx context.Context) {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pending spot pods: %v", err)
+		return
+	}
+
+	spotFallbackConfig := c.config.Get().SpotFallback
+	for _, pod := range pods {
+		unschedulableSince := getUnschedulableSince(pod)
+		if unschedulableSince == nil || time.Since(unschedulableSince.Time) < spotFallbackConfig.UnschedulableTimeout.Duration {
+			continue
+		}
+
+		setting := c.cache.GetPodOptimizeSchedulingSetting(ctx, pod)
+		if setting == nil || !setting.SpotFallback {
+			klog.V(4).Infof("Pod %s/%s is unschedulable on spot since %v, but its workload didnt enable spot fallback.",
+				pod.Namespace, pod.Name, unschedulableSince.Time)
+			continue
+		}
+
+		c.fallBackToOnDemand(ctx, pod, spotFallbackConfig.OnDemandBiasDuration.Duration)
+	}
+}
+
+// fallBackToOnDemand biases the workload to on-demand, then deletes the Pod to let its workload recreate it.
+func (c *Controller) fallBackToOnDemand(ctx context.Context, pod *corev1.Pod, biasDuration time.Duration) {
+	c.cache.BiasToOnDemand(pod, biasDuration)
+
+	// Make sure we don't delete the Pod which has been recreated with the same name, such as a StatefulSet Pod.
+	err := c.kubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		Preconditions: metav1.NewUIDPreconditions(string(pod.UID)),
+	})
+	if err != nil {
+		klog.Errorf("Failed to delete the unschedulable spot Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+
+	klog.Infof("Deleted the unschedulable spot Pod %s/%s, its replacement will fall back to on-demand.",
+		pod.Namespace, pod.Name)
+}
+
+// getUnschedulableSince returns when the Pod became unschedulable, or nil if it's not unschedulable.
+func getUnschedulableSince(pod *corev1.Pod) *metav1.Time {
+	if pod.DeletionTimestamp != nil || pod.Spec.NodeName != "" {
+		return nil
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return &condition.LastTransitionTime
+		}
+	}
+	return nil
+}
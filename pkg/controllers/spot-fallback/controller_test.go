@@ -0,0 +1,104 @@
+package spot_fallback
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	listercorev1 "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	"vacant.sh/vmanager/pkg/config"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// fakeCache returns the setting of the Pods by their names, and records the biased Pods.
+type fakeCache struct {
+	cache.Interface
+
+	settings map[string]*apis.OptimizeSchedulingSetting
+	biased   map[string]time.Duration
+}
+
+func (f *fakeCache) GetPodOptimizeSchedulingSetting(_ context.Context, pod *corev1.Pod) *apis.OptimizeSchedulingSetting {
+	return f.settings[pod.Name]
+}
+
+func (f *fakeCache) BiasToOnDemand(pod *corev1.Pod, duration time.Duration) {
+	f.biased[pod.Name] = duration
+}
+
+func newPendingPod(name string, unschedulableFor time.Duration) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name)},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	if unschedulableFor > 0 {
+		pod.Status.Conditions = []corev1.PodCondition{
+			{
+				Type:               corev1.PodScheduled,
+				Status:             corev1.ConditionFalse,
+				Reason:             corev1.PodReasonUnschedulable,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-unschedulableFor)),
+			},
+		}
+	}
+	return pod
+}
+
+func TestCheckPendingPods(t *testing.T) {
+	fallbackEnabled := &apis.OptimizeSchedulingSetting{Enable: true, SpotFallback: true}
+	fallbackDisabled := &apis.OptimizeSchedulingSetting{Enable: true}
+
+	scheduled := newPendingPod("scheduled", time.Hour)
+	scheduled.Spec.NodeName = "node"
+
+	testCases := []struct {
+		name           string
+		pod            *corev1.Pod
+		setting        *apis.OptimizeSchedulingSetting
+		expectFallback bool
+	}{
+		{name: "timed out", pod: newPendingPod("timed-out", time.Hour), setting: fallbackEnabled, expectFallback: true},
+		{name: "not timed out", pod: newPendingPod("not-timed-out", time.Minute), setting: fallbackEnabled},
+		{name: "not unschedulable", pod: newPendingPod("pending", 0), setting: fallbackEnabled},
+		{name: "scheduled", pod: scheduled, setting: fallbackEnabled},
+		{name: "fallback disabled", pod: newPendingPod("disabled", time.Hour), setting: fallbackDisabled},
+		{name: "workload not found", pod: newPendingPod("orphan", time.Hour)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(tc.pod)
+			indexer := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{})
+			if err := indexer.Add(tc.pod); err != nil {
+				t.Fatalf("failed to add the Pod: %v", err)
+			}
+			wc := &fakeCache{
+				settings: map[string]*apis.OptimizeSchedulingSetting{tc.pod.Name: tc.setting},
+				biased:   map[string]time.Duration{},
+			}
+			c := &Controller{
+				kubeClient: kubeClient,
+				cache:      wc,
+				config:     config.NewHolder(config.NewDefaultConfiguration()),
+				podLister:  listercorev1.NewPodLister(indexer),
+			}
+
+			c.checkPendingPods(context.Background())
+
+			_, err := kubeClient.CoreV1().Pods(tc.pod.Namespace).Get(context.Background(), tc.pod.Name, metav1.GetOptions{})
+			deleted := apierrors.IsNotFound(err)
+			_, biased := wc.biased[tc.pod.Name]
+			if deleted != tc.expectFallback || biased != tc.expectFallback {
+				t.Errorf("expect fallback %v, got deleted %v biased %v", tc.expectFallback, deleted, biased)
+			}
+		})
+	}
+}
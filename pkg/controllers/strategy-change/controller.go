@@ -0,0 +1,78 @@
+package strategy_change
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/controllers/utils"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+)
+
+// checkInterval is the interval of checking the workloads whose strategies have been changed.
+const checkInterval = 30 * time.Second
+
+// Controller annotates the Deployments and StatefulSets whose strategies have been changed with their pending
+// deviation, since the existing Pods are not moved until they are recreated, such as by a rollout.
+// The annotation is removed once the Pods match the new target. The changes are only remembered in memory,
+// the workloads which are still annotated are picked up again by the cache after a restart.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	cache      cache.Interface
+
+	// annotated records the last annotation value written to each workload, to avoid the repeated patches.
+	annotated map[string]string
+}
+
+func NewController(kubeClient kubernetes.Interface, wc cache.Interface) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		cache:      wc,
+		annotated:  map[string]string{},
+	}
+}
+
+// Run checks the changed workloads periodically until the ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	klog.V(2).Info("Strategy change controller start to run.")
+	wait.UntilWithContext(ctx, c.syncDeviations, checkInterval)
+}
+
+func (c *Controller) syncDeviations(ctx context.Context) {
+	for _, deviation := range c.cache.ListStrategyChangeDeviations() {
+		key := fmt.Sprintf("%s/%s", deviation.WorkloadType, deviation.WorkloadKey)
+
+		var value *string
+		if !deviation.Satisfied() {
+			value = ptr.To(fmt.Sprintf("on-demand: %+d, spot: %+d", deviation.OnDemandDelta(), deviation.SpotDelta()))
+		}
+
+		// The satisfied workloads are listed until they are forgotten, always remove their annotation.
+		if lastValue, ok := c.annotated[key]; ok && value != nil && lastValue == *value {
+			continue
+		}
+
+		err := utils.PatchWorkloadAnnotation(ctx, c.kubeClient, deviation.WorkloadType, deviation.WorkloadKey,
+			optimizescheduling.OptimizeSchedulingPendingDeviationKey, value)
+		if err != nil {
+			klog.Errorf("Failed to annotate the pending deviation of %s: %v", key, err)
+			continue
+		}
+
+		if value == nil {
+			// Forget the workload only after its annotation is removed, so that a failed patch is retried.
+			c.cache.ForgetStrategyChange(deviation.WorkloadType, deviation.WorkloadKey)
+			delete(c.annotated, key)
+			klog.Infof("%s has been rebalanced to its new strategy.", key)
+		} else {
+			c.annotated[key] = *value
+			klog.Infof("%s deviates from its new strategy, %s", key, *value)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package strategy_change
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// fakeCache lists the deviations until they are forgotten, like the webhook cache.
+type fakeCache struct {
+	cache.Interface
+
+	deviations map[types.NamespacedName]*apis.WorkloadDeviation
+}
+
+func (f *fakeCache) ListStrategyChangeDeviations() []*apis.WorkloadDeviation {
+	var deviations []*apis.WorkloadDeviation
+	for _, deviation := range f.deviations {
+		deviations = append(deviations, deviation)
+	}
+	return deviations
+}
+
+func (f *fakeCache) ForgetStrategyChange(_ string, workloadKey types.NamespacedName) {
+	delete(f.deviations, workloadKey)
+}
+
+func newDeviation(onDemand, spot int) *apis.WorkloadDeviation {
+	return &apis.WorkloadDeviation{
+		WorkloadType:        "Deployment",
+		WorkloadKey:         types.NamespacedName{Namespace: "default", Name: "web"},
+		TargetOnDemandNum:   3,
+		ObservedOnDemandNum: onDemand,
+		ObservedOnSpotNum:   spot,
+	}
+}
+
+func getAnnotation(t *testing.T, kubeClient *fake.Clientset) (string, bool) {
+	t.Helper()
+
+	deployment, err := kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the deployment: %v", err)
+	}
+	value, ok := deployment.Annotations[optimizescheduling.OptimizeSchedulingPendingDeviationKey]
+	return value, ok
+}
+
+func countPatches(kubeClient *fake.Clientset) int {
+	patches := 0
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	return patches
+}
+
+func TestSyncDeviations(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+	})
+	key := types.NamespacedName{Namespace: "default", Name: "web"}
+	wc := &fakeCache{deviations: map[types.NamespacedName]*apis.WorkloadDeviation{key: newDeviation(1, 2)}}
+	c := NewController(kubeClient, wc)
+
+	c.syncDeviations(context.TODO())
+	if value, ok := getAnnotation(t, kubeClient); !ok || value != "on-demand: +2, spot: -2" {
+		t.Fatalf("expect the deviation to be annotated, got %q", value)
+	}
+
+	// The unchanged deviation is not patched again.
+	c.syncDeviations(context.TODO())
+	if patches := countPatches(kubeClient); patches != 1 {
+		t.Errorf("expect 1 patch, got %d", patches)
+	}
+
+	// The satisfied deviation is kept until its annotation is removed.
+	wc.deviations[key] = newDeviation(3, 0)
+	kubeClient.PrependReactor("patch", "deployments", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("injected error")
+	})
+	c.syncDeviations(context.TODO())
+	if _, ok := wc.deviations[key]; !ok {
+		t.Fatalf("expect the deviation to be kept after the patch failed")
+	}
+
+	kubeClient.ReactionChain = kubeClient.ReactionChain[1:]
+	c.syncDeviations(context.TODO())
+	if _, ok := wc.deviations[key]; ok {
+		t.Errorf("expect the deviation to be forgotten after the annotation is removed")
+	}
+	if value, ok := getAnnotation(t, kubeClient); ok {
+		t.Errorf("expect the annotation to be removed, got %q", value)
+	}
+}
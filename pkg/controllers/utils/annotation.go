@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PatchWorkloadAnnotation sets the annotation of a Deployment or StatefulSet, or removes it if the value is nil.
+// The workload which has been deleted is ignored.
+func PatchWorkloadAnnotation(ctx context.Context, kubeClient kubernetes.Interface, workloadType string,
+	workloadKey types.NamespacedName, annotationKey string, value *string) error {
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{annotationKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch workloadType {
+	case "Deployment":
+		_, err = kubeClient.AppsV1().Deployments(workloadKey.Namespace).Patch(ctx, workloadKey.Name,
+			types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = kubeClient.AppsV1().StatefulSets(workloadKey.Namespace).Patch(ctx, workloadKey.Name,
+			types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		return fmt.Errorf("unsupported workload type %s", workloadType)
+	}
+
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
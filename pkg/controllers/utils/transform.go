@@ -0,0 +1,38 @@
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TransformPod strips the Pods cached by the controllers to the metadata without the managed fields and the last
+// applied configuration, the node, the phase and the scheduled condition. The controllers watch the marked Pods
+// of the whole cluster apart from the webhook cache, so they are stripped the same way to keep the memory of
+// the second copy small, only the fields above are read by the controllers and the cache lookups.
+func TransformPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	stripped := &corev1.Pod{
+		TypeMeta:   pod.TypeMeta,
+		ObjectMeta: pod.ObjectMeta,
+		Spec:       corev1.PodSpec{NodeName: pod.Spec.NodeName},
+		Status:     corev1.PodStatus{Phase: pod.Status.Phase},
+	}
+	stripped.ManagedFields = nil
+	if _, ok := pod.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		stripped.Annotations = make(map[string]string, len(pod.Annotations)-1)
+		for key, value := range pod.Annotations {
+			if key != corev1.LastAppliedConfigAnnotation {
+				stripped.Annotations[key] = value
+			}
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled {
+			stripped.Status.Conditions = []corev1.PodCondition{condition}
+		}
+	}
+	return stripped, nil
+}
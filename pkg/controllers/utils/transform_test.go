@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTransformPod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web-0",
+			UID:       "uid",
+			Labels:    map[string]string{"app": "web"},
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"kind":"Pod"}`,
+				"team":                             "a",
+			},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "web", Controller: new(bool)}},
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node",
+			Containers: []corev1.Container{{Name: "app", Image: "app:v1"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+				{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable},
+			},
+			PodIP: "10.0.0.1",
+		},
+	}
+	original := pod.DeepCopy()
+
+	obj, err := TransformPod(pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "web-0",
+			UID:             "uid",
+			Labels:          map[string]string{"app": "web"},
+			Annotations:     map[string]string{"team": "a"},
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{NodeName: "node"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodPending,
+			Conditions: []corev1.PodCondition{pod.Status.Conditions[1]},
+		},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("expect the stripped pod %+v, got %+v", expected, obj)
+	}
+	if !reflect.DeepEqual(pod, original) {
+		t.Errorf("expect the original pod not to be modified")
+	}
+
+	node := &corev1.Node{}
+	if obj, _ := TransformPod(node); obj != node {
+		t.Errorf("expect the other objects to be returned as is")
+	}
+}
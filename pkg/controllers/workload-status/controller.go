@@ -0,0 +1,104 @@
+package workload_status
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/controllers/utils"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// syncInterval is the interval of reporting the status of the workloads.
+const syncInterval = 30 * time.Second
+
+// Controller reports the target and observed placement of the Pods of the Deployments and StatefulSets
+// which enable the optimize scheduling, in their vacant.sh/optimize-scheduling-status annotation.
+// The annotation is removed when the workload disables the optimize scheduling. The lastDecisionTime alone
+// doesn't update the annotation, it's only refreshed along with the other fields.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	cache      cache.Interface
+}
+
+func NewController(kubeClient kubernetes.Interface, wc cache.Interface) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		cache:      wc,
+	}
+}
+
+// Run reports the status of the workloads periodically until the ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	klog.V(2).Info("Workload status controller start to run.")
+	wait.UntilWithContext(ctx, c.syncStatuses, syncInterval)
+}
+
+func (c *Controller) syncStatuses(ctx context.Context) {
+	for _, workloadStatus := range c.cache.ListWorkloadStatuses() {
+		currentValue, annotated := workloadStatus.Annotations[optimizescheduling.OptimizeSchedulingStatusKey]
+
+		var value *string
+		if workloadStatus.Enable {
+			status := buildStatus(workloadStatus)
+			if annotated && !isStatusChanged(currentValue, status) {
+				continue
+			}
+
+			statusValue, err := json.Marshal(status)
+			if err != nil {
+				klog.Errorf("Failed to marshal the status of %s %v: %v", workloadStatus.WorkloadType,
+					workloadStatus.WorkloadKey, err)
+				continue
+			}
+			value = ptr.To(string(statusValue))
+		} else if !annotated {
+			continue
+		}
+
+		err := utils.PatchWorkloadAnnotation(ctx, c.kubeClient, workloadStatus.WorkloadType, workloadStatus.WorkloadKey,
+			optimizescheduling.OptimizeSchedulingStatusKey, value)
+		if err != nil {
+			klog.Errorf("Failed to report the status of %s %v: %v", workloadStatus.WorkloadType,
+				workloadStatus.WorkloadKey, err)
+			continue
+		}
+		klog.V(4).Infof("Reported the status of %s %v.", workloadStatus.WorkloadType, workloadStatus.WorkloadKey)
+	}
+}
+
+func buildStatus(workloadStatus *apis.WorkloadStatus) *optimizescheduling.OptimizeSchedulingStatus {
+	status := &optimizescheduling.OptimizeSchedulingStatus{
+		Strategy:         workloadStatus.Strategy,
+		TargetOnDemand:   workloadStatus.TargetOnDemandNum,
+		TargetSpot:       workloadStatus.TargetOnSpotNum,
+		ObservedOnDemand: workloadStatus.ObservedOnDemandNum,
+		ObservedSpot:     workloadStatus.ObservedOnSpotNum,
+		Condition:        workloadStatus.Condition(),
+	}
+	if !workloadStatus.LastDecisionTime.IsZero() {
+		status.LastDecisionTime = &metav1.Time{Time: workloadStatus.LastDecisionTime.Truncate(time.Second)}
+	}
+	return status
+}
+
+// isStatusChanged returns true if the reported status differs from the current one other than the LastDecisionTime,
+// so that the workload isn't patched for every new Pod. The invalid status is always replaced.
+func isStatusChanged(currentValue string, status *optimizescheduling.OptimizeSchedulingStatus) bool {
+	current := &optimizescheduling.OptimizeSchedulingStatus{}
+	if err := json.Unmarshal([]byte(currentValue), current); err != nil {
+		return true
+	}
+
+	reported := *status
+	current.LastDecisionTime, reported.LastDecisionTime = nil, nil
+	return *current != reported
+}
@@ -0,0 +1,173 @@
+package workload_status
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// fakeCache lists the status of a Deployment with its current annotations in the API server.
+type fakeCache struct {
+	cache.Interface
+
+	kubeClient *fake.Clientset
+	status     *apis.WorkloadStatus
+}
+
+func (f *fakeCache) ListWorkloadStatuses() []*apis.WorkloadStatus {
+	deployment, _ := f.kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "web", metav1.GetOptions{})
+	status := *f.status
+	status.Annotations = deployment.Annotations
+	return []*apis.WorkloadStatus{&status}
+}
+
+func newWorkloadStatus(observedOnDemand, observedSpot int, lastDecisionTime time.Time) *apis.WorkloadStatus {
+	return &apis.WorkloadStatus{
+		WorkloadDeviation: apis.WorkloadDeviation{
+			WorkloadType:        "Deployment",
+			WorkloadKey:         types.NamespacedName{Namespace: "default", Name: "web"},
+			TargetOnDemandNum:   2,
+			TargetOnSpotNum:     1,
+			ObservedOnDemandNum: observedOnDemand,
+			ObservedOnSpotNum:   observedSpot,
+		},
+		Enable:           true,
+		Strategy:         optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand,
+		LastDecisionTime: lastDecisionTime,
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	decisionTime := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	testCases := []struct {
+		name   string
+		status *apis.WorkloadStatus
+		expect optimizescheduling.OptimizeSchedulingStatus
+	}{
+		{
+			name:   "satisfied",
+			status: newWorkloadStatus(2, 1, decisionTime),
+			expect: optimizescheduling.OptimizeSchedulingStatus{
+				Strategy: optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, TargetOnDemand: 2, TargetSpot: 1,
+				ObservedOnDemand: 2, ObservedSpot: 1, Condition: optimizescheduling.OptimizeSchedulingConditionSatisfied,
+				LastDecisionTime: &metav1.Time{Time: decisionTime.Truncate(time.Second)},
+			},
+		},
+		{
+			name:   "degraded without decision",
+			status: newWorkloadStatus(1, 2, time.Time{}),
+			expect: optimizescheduling.OptimizeSchedulingStatus{
+				Strategy: optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, TargetOnDemand: 2, TargetSpot: 1,
+				ObservedOnDemand: 1, ObservedSpot: 2, Condition: optimizescheduling.OptimizeSchedulingConditionDegraded,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := buildStatus(tc.status)
+			gotTime, expectTime := got.LastDecisionTime, tc.expect.LastDecisionTime
+			if (gotTime == nil) != (expectTime == nil) || gotTime != nil && !gotTime.Equal(expectTime) {
+				t.Errorf("expect lastDecisionTime %v, got %v", expectTime, gotTime)
+			}
+
+			got.LastDecisionTime, tc.expect.LastDecisionTime = nil, nil
+			if *got != tc.expect {
+				t.Errorf("expect status %+v, got %+v", tc.expect, *got)
+			}
+		})
+	}
+}
+
+func TestSyncStatuses(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+	})
+	wc := &fakeCache{kubeClient: kubeClient}
+	c := NewController(kubeClient, wc)
+	now := time.Now()
+
+	steps := []struct {
+		name        string
+		status      *apis.WorkloadStatus
+		expectPatch bool
+	}{
+		{
+			name:        "reported",
+			status:      newWorkloadStatus(1, 0, now),
+			expectPatch: true,
+		},
+		{
+			name:   "only the decision time changed",
+			status: newWorkloadStatus(1, 0, now.Add(time.Minute)),
+		},
+		{
+			name:        "counts changed",
+			status:      newWorkloadStatus(2, 0, now.Add(2*time.Minute)),
+			expectPatch: true,
+		},
+		{
+			name: "disabled",
+			status: func() *apis.WorkloadStatus {
+				status := newWorkloadStatus(2, 0, now)
+				status.Enable = false
+				return status
+			}(),
+			expectPatch: true,
+		},
+		{
+			name: "disabled without the annotation",
+			status: func() *apis.WorkloadStatus {
+				status := newWorkloadStatus(2, 0, now)
+				status.Enable = false
+				return status
+			}(),
+		},
+	}
+
+	for _, step := range steps {
+		kubeClient.ClearActions()
+		wc.status = step.status
+		c.syncStatuses(context.TODO())
+
+		patched := false
+		for _, action := range kubeClient.Actions() {
+			patched = patched || action.GetVerb() == "patch"
+		}
+		if patched != step.expectPatch {
+			t.Errorf("%s: expect patched %v, got %v", step.name, step.expectPatch, patched)
+		}
+
+		deployment, err := kubeClient.AppsV1().Deployments("default").Get(context.TODO(), "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get the deployment: %v", err)
+		}
+		value, annotated := deployment.Annotations[optimizescheduling.OptimizeSchedulingStatusKey]
+		if annotated != step.status.Enable {
+			t.Errorf("%s: expect annotated %v, got %q", step.name, step.status.Enable, value)
+			continue
+		}
+		if !annotated {
+			continue
+		}
+		status := &optimizescheduling.OptimizeSchedulingStatus{}
+		if err := json.Unmarshal([]byte(value), status); err != nil {
+			t.Fatalf("%s: failed to unmarshal the status %q: %v", step.name, value, err)
+		}
+		if status.ObservedOnDemand != step.status.ObservedOnDemandNum {
+			t.Errorf("%s: expect observed on-demand %d, got %d", step.name, step.status.ObservedOnDemandNum,
+				status.ObservedOnDemand)
+		}
+	}
+}
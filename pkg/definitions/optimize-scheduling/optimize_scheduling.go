@@ -2,6 +2,9 @@ package optimize_scheduling
 
 import "k8s.io/apimachinery/pkg/util/sets"
 
+// The keys below can be set as either labels or annotations of a workload. Annotations are recommended for the
+// values which don't fit in a label. When a key is set in both, the annotation takes precedence over the label.
+
 const (
 	// OptimizeSchedulingKey defines whether optimization scheduling needs to be enabled for the load.
 	// The value must be a boolean.
@@ -19,6 +22,23 @@ const (
 	// you can specify the minimum number of replicas that need to be on on-demand nodes.
 	// This value must be greater than or equal to 0.
 	OptimizeSchedulingStrategyCustomOnDemandKey = "vacant.sh/optimize-scheduling-strategy-custom-on-demand"
+
+	// OptimizeSchedulingSpotFallbackKey defines whether the spot Pods of the workload which stay unschedulable
+	// for too long should be recreated on the on-demand nodes. The value must be a boolean.
+	OptimizeSchedulingSpotFallbackKey = "vacant.sh/optimize-scheduling-spot-fallback"
+
+	// OptimizeSchedulingDryRunKey defines whether the placement of the new Pods of the workload is only recorded
+	// in the annotation vacant.sh/dry-run-affinity instead of being enforced. The value must be a boolean.
+	OptimizeSchedulingDryRunKey = "vacant.sh/optimize-scheduling-dry-run"
+
+	// OptimizeSchedulingPendingDeviationKey is the annotation written by vmanager after the strategy of a workload
+	// has been changed, while its existing Pods don't match the new target yet, such as "on-demand: +2, spot: -2".
+	// The numbers are the Pods missing on on-demand and spot, it's removed once the Pods are rebalanced by a rollout.
+	OptimizeSchedulingPendingDeviationKey = "vacant.sh/optimize-scheduling-pending-deviation"
+
+	// OptimizeSchedulingStatusKey is the annotation written by vmanager to report the target and observed placement
+	// of the Pods of a workload which enables the optimize scheduling, the value is an OptimizeSchedulingStatus in JSON.
+	OptimizeSchedulingStatusKey = "vacant.sh/optimize-scheduling-status"
 )
 
 var OptimizeSchedulingStrategies = sets.NewString(
@@ -27,3 +47,46 @@ var OptimizeSchedulingStrategies = sets.NewString(
 	OptimizeSchedulingStrategyMajorityInOnDemand,
 	OptimizeSchedulingStrategyCustom,
 )
+
+// OptimizeSchedulingKeys are all the keys of the optimize scheduling configuration.
+var OptimizeSchedulingKeys = sets.NewString(
+	OptimizeSchedulingKey,
+	OptimizeSchedulingStrategyKey,
+	OptimizeSchedulingStrategyCustomOnDemandKey,
+	OptimizeSchedulingSpotFallbackKey,
+	OptimizeSchedulingDryRunKey,
+)
+
+// GetOptimizeSchedulingConfiguration merges the optimize scheduling configuration from the labels and the annotations
+// of a workload, the annotations take precedence over the labels.
+func GetOptimizeSchedulingConfiguration(labels, annotations map[string]string) map[string]string {
+	configuration := map[string]string{}
+	for _, source := range []map[string]string{labels, annotations} {
+		for key, value := range source {
+			if OptimizeSchedulingKeys.Has(key) {
+				configuration[key] = value
+			}
+		}
+	}
+	return configuration
+}
+
+// IsCustomStrategyEnabled returns true if the workload enables the optimize scheduling with the custom strategy.
+func IsCustomStrategyEnabled(labels, annotations map[string]string) bool {
+	configuration := GetOptimizeSchedulingConfiguration(labels, annotations)
+	return configuration[OptimizeSchedulingKey] == "true" &&
+		configuration[OptimizeSchedulingStrategyKey] == OptimizeSchedulingStrategyCustom
+}
+
+// IsCustomOnDemandChanged returns true if the custom on-demand count of the workload is set or changed,
+// including enabling the optimize scheduling and switching to the custom strategy.
+func IsCustomOnDemandChanged(oldLabels, oldAnnotations, labels, annotations map[string]string) bool {
+	if !IsCustomStrategyEnabled(labels, annotations) {
+		return false
+	}
+	if !IsCustomStrategyEnabled(oldLabels, oldAnnotations) {
+		return true
+	}
+	return GetOptimizeSchedulingConfiguration(oldLabels, oldAnnotations)[OptimizeSchedulingStrategyCustomOnDemandKey] !=
+		GetOptimizeSchedulingConfiguration(labels, annotations)[OptimizeSchedulingStrategyCustomOnDemandKey]
+}
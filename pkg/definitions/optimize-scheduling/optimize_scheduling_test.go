@@ -0,0 +1,54 @@
+package optimize_scheduling
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetOptimizeSchedulingConfiguration(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		expect      map[string]string
+	}{
+		{
+			name:   "labels only",
+			labels: map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyAllInSpot, "app": "web"},
+			expect: map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyAllInSpot},
+		},
+		{
+			name:        "merged from labels and annotations",
+			labels:      map[string]string{OptimizeSchedulingKey: "true"},
+			annotations: map[string]string{OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyCustom, OptimizeSchedulingStrategyCustomOnDemandKey: "2"},
+			expect: map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyCustom,
+				OptimizeSchedulingStrategyCustomOnDemandKey: "2"},
+		},
+		{
+			name:        "annotation wins over the disagreeing label",
+			labels:      map[string]string{OptimizeSchedulingKey: "false", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyAllInSpot},
+			annotations: map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyCustom},
+			expect:      map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyCustom},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetOptimizeSchedulingConfiguration(tt.labels, tt.annotations); !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expect %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestIsCustomStrategyEnabledPrefersAnnotations(t *testing.T) {
+	labels := map[string]string{OptimizeSchedulingKey: "true", OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyCustom}
+	annotations := map[string]string{OptimizeSchedulingStrategyKey: OptimizeSchedulingStrategyAllInSpot}
+
+	if IsCustomStrategyEnabled(labels, annotations) {
+		t.Errorf("expect the all-in-spot strategy of the annotation to override the custom strategy of the label")
+	}
+	if !IsCustomStrategyEnabled(annotations, labels) {
+		t.Errorf("expect the custom strategy of the annotation to override the all-in-spot strategy of the label")
+	}
+}
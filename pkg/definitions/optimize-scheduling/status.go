@@ -0,0 +1,27 @@
+package optimize_scheduling
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// OptimizeSchedulingConditionSatisfied means the workload has at least the target number of on-demand Pods.
+	OptimizeSchedulingConditionSatisfied = "Satisfied"
+	// OptimizeSchedulingConditionDegraded means the workload has fewer on-demand Pods than the target,
+	// so more of its Pods than expected may be interrupted at once.
+	OptimizeSchedulingConditionDegraded = "Degraded"
+)
+
+// OptimizeSchedulingStatus is the value of the OptimizeSchedulingStatusKey annotation.
+type OptimizeSchedulingStatus struct {
+	Strategy       string `json:"strategy"`
+	TargetOnDemand int    `json:"targetOnDemand"`
+	TargetSpot     int    `json:"targetSpot"`
+	// ObservedOnDemand and ObservedSpot count the live Pods which have been marked by the webhook.
+	ObservedOnDemand int `json:"observedOnDemand"`
+	ObservedSpot     int `json:"observedSpot"`
+	// LastDecisionTime is when the webhook determined the placement of a new Pod of the workload last time.
+	LastDecisionTime *metav1.Time `json:"lastDecisionTime,omitempty"`
+	// Condition is either OptimizeSchedulingConditionSatisfied or OptimizeSchedulingConditionDegraded.
+	Condition string `json:"condition"`
+}
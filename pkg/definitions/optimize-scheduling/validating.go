@@ -4,58 +4,108 @@ import (
 	"fmt"
 	"strconv"
 
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
-// ValidateOptimizeSchedulingConfiguration checks whether the label configuration of a load meets the target requirements.
-func ValidateOptimizeSchedulingConfiguration(labelSet labels.Set) field.ErrorList {
-	if labelSet == nil {
-		return nil
+// ValidateOptimizeSchedulingConfiguration checks whether the label and annotation configuration of a load meets
+// the target requirements. The errors point to the labels or annotations under the metadataPath where the
+// invalid values come from.
+func ValidateOptimizeSchedulingConfiguration(labels, annotations map[string]string, metadataPath *field.Path) field.ErrorList {
+	labelsPath := metadataPath.Child("labels")
+	annotationsPath := metadataPath.Child("annotations")
+
+	errs := validateOptimizeSchedulingValues(labels, labelsPath)
+	errs = append(errs, validateOptimizeSchedulingValues(annotations, annotationsPath)...)
+
+	// The custom strategy requires the count, which may come from either labels or annotations.
+	configuration := GetOptimizeSchedulingConfiguration(labels, annotations)
+	if configuration[OptimizeSchedulingStrategyKey] == OptimizeSchedulingStrategyCustom {
+		if _, ok := configuration[OptimizeSchedulingStrategyCustomOnDemandKey]; !ok {
+			strategyPath := labelsPath
+			if _, ok := annotations[OptimizeSchedulingStrategyKey]; ok {
+				strategyPath = annotationsPath
+			}
+			errs = append(errs, field.Required(strategyPath.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
+				"value must not be empty when the strategy is custom."))
+		}
 	}
 
+	return errs
+}
+
+// validateOptimizeSchedulingValues checks the value of each key in the labels or annotations.
+func validateOptimizeSchedulingValues(values map[string]string, path *field.Path) field.ErrorList {
 	errs := field.ErrorList{}
-	labelsPath := field.NewPath("labelSet")
 
 	// Validate the optimizeScheduling value, must be a boolean.
-	if labelSet.Has(OptimizeSchedulingKey) {
-		optimizeSchedulingValue := labelSet.Get(OptimizeSchedulingKey)
+	if optimizeSchedulingValue, ok := values[OptimizeSchedulingKey]; ok {
 		if optimizeSchedulingValue != "true" && optimizeSchedulingValue != "false" {
-			errs = append(errs, field.Invalid(labelsPath.Key(OptimizeSchedulingKey),
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingKey),
 				optimizeSchedulingValue, "value must be a boolean."))
 		}
 	}
 
-	var optimizeSchedulingStrategyValue string
+	// Validate the optimizeSchedulingSpotFallback value, must be a boolean.
+	if spotFallbackValue, ok := values[OptimizeSchedulingSpotFallbackKey]; ok {
+		if spotFallbackValue != "true" && spotFallbackValue != "false" {
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingSpotFallbackKey),
+				spotFallbackValue, "value must be a boolean."))
+		}
+	}
+
+	// Validate the optimizeSchedulingDryRun value, must be a boolean.
+	if dryRunValue, ok := values[OptimizeSchedulingDryRunKey]; ok {
+		if dryRunValue != "true" && dryRunValue != "false" {
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingDryRunKey),
+				dryRunValue, "value must be a boolean."))
+		}
+	}
+
 	// Validate the optimizeSchedulingStrategy value, must be in the OptimizeSchedulingStrategies.
-	if labelSet.Has(OptimizeSchedulingStrategyKey) {
-		optimizeSchedulingStrategyValue = labelSet.Get(OptimizeSchedulingStrategyKey)
+	if optimizeSchedulingStrategyValue, ok := values[OptimizeSchedulingStrategyKey]; ok {
 		if !OptimizeSchedulingStrategies.Has(optimizeSchedulingStrategyValue) {
-			errs = append(errs, field.Invalid(labelsPath.Key(OptimizeSchedulingStrategyKey),
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingStrategyKey),
 				optimizeSchedulingStrategyValue, fmt.Sprintf("value must be in %v.", OptimizeSchedulingStrategies.List())))
 		}
 	}
 
-	customOnDemandHas := labelSet.Has(OptimizeSchedulingStrategyCustomOnDemandKey)
-	customOnDemandValue := labelSet.Get(OptimizeSchedulingStrategyCustomOnDemandKey)
 	// Validate the optimizeSchedulingStrategyCustomOnDemand value must be greater than or equal to 0.
-	if customOnDemandHas {
+	if customOnDemandValue, ok := values[OptimizeSchedulingStrategyCustomOnDemandKey]; ok {
 		if onDemandCount, err := strconv.Atoi(customOnDemandValue); err != nil {
-			errs = append(errs, field.Invalid(labelsPath.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
-				customOnDemandValue, fmt.Sprintf("value must be a number.")))
-		} else {
-			if onDemandCount < 0 {
-				errs = append(errs, field.Invalid(labelsPath.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
-					customOnDemandValue, fmt.Sprintf("value must be greater than or equal to 0.")))
-			}
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
+				customOnDemandValue, "value must be a number."))
+		} else if onDemandCount < 0 {
+			errs = append(errs, field.Invalid(path.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
+				customOnDemandValue, "value must be greater than or equal to 0."))
 		}
 	}
 
-	// If using OptimizeSchedulingStrategyCustom, the OptimizeSchedulingStrategyCustomOnDemandKey must be specified.
-	if optimizeSchedulingStrategyValue == OptimizeSchedulingStrategyCustom && !customOnDemandHas {
-		errs = append(errs, field.Required(labelsPath.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
-			"value must not be empty when the strategy is custom."))
+	return errs
+}
+
+// ValidateOptimizeSchedulingReplicas checks whether the custom on-demand count of a load can be satisfied by its
+// replicas. The maxReplicas is the highest number of replicas the load can run with, such as the spec.replicas, or
+// the maxReplicas of the HorizontalPodAutoscaler targeting it, which is described by the maxReplicasSource.
+// It should only be checked when the count is set or changed, since the count exceeding the replicas after scaling
+// down is handled by placing all the replicas on on-demand.
+func ValidateOptimizeSchedulingReplicas(labels, annotations map[string]string, metadataPath *field.Path,
+	maxReplicas int, maxReplicasSource string) field.ErrorList {
+
+	if !IsCustomStrategyEnabled(labels, annotations) {
+		return nil
+	}
+
+	customOnDemandValue := GetOptimizeSchedulingConfiguration(labels, annotations)[OptimizeSchedulingStrategyCustomOnDemandKey]
+	onDemandCount, err := strconv.Atoi(customOnDemandValue)
+	if err != nil || onDemandCount <= maxReplicas {
+		// The invalid number is reported by ValidateOptimizeSchedulingConfiguration.
+		return nil
 	}
 
-	return errs
+	customOnDemandPath := metadataPath.Child("labels")
+	if _, ok := annotations[OptimizeSchedulingStrategyCustomOnDemandKey]; ok {
+		customOnDemandPath = metadataPath.Child("annotations")
+	}
+	return field.ErrorList{field.Invalid(customOnDemandPath.Key(OptimizeSchedulingStrategyCustomOnDemandKey),
+		customOnDemandValue, fmt.Sprintf("value must be less than or equal to the %s (%d).", maxReplicasSource, maxReplicas))}
 }
@@ -0,0 +1,76 @@
+package optimize_scheduling
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// WarnOptimizeSchedulingConfiguration returns the warnings of the configuration which is valid but has no effect,
+// such as a strategy without enabling the optimize scheduling.
+func WarnOptimizeSchedulingConfiguration(labels, annotations map[string]string, metadataPath *field.Path) []string {
+	configuration := GetOptimizeSchedulingConfiguration(labels, annotations)
+	if len(configuration) == 0 || configuration[OptimizeSchedulingKey] == "true" {
+		return nil
+	}
+
+	var warnings []string
+	for _, key := range OptimizeSchedulingKeys.List() {
+		if _, ok := configuration[key]; !ok || key == OptimizeSchedulingKey {
+			continue
+		}
+		keyPath := metadataPath.Child("labels").Key(key)
+		if _, ok := annotations[key]; ok {
+			keyPath = metadataPath.Child("annotations").Key(key)
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: has no effect unless %s is \"true\".",
+			keyPath, OptimizeSchedulingKey))
+	}
+	return warnings
+}
+
+// WarnCronJobOptimizeSchedulingConfiguration returns the warning if the configuration is set on the metadata of
+// a CronJob, which has no effect since the Jobs are created with the metadata of the jobTemplate.
+func WarnCronJobOptimizeSchedulingConfiguration(labels, annotations map[string]string, metadataPath *field.Path) []string {
+	if len(GetOptimizeSchedulingConfiguration(labels, annotations)) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: the optimize scheduling configuration of a CronJob has no effect, "+
+		"set it in spec.jobTemplate.metadata instead.", metadataPath)}
+}
+
+// WarnOptimizeSchedulingReplicas returns the warnings of the risky strategies for the replicas of a load, the replicas
+// is the lowest number of replicas the load runs with, described by the replicasSource. The hpaName is the name of
+// the HorizontalPodAutoscaler targeting the load, or empty if it's not autoscaled.
+func WarnOptimizeSchedulingReplicas(labels, annotations map[string]string, workloadType string,
+	replicas int, replicasSource string, hpaName string) []string {
+
+	configuration := GetOptimizeSchedulingConfiguration(labels, annotations)
+	if configuration[OptimizeSchedulingKey] != "true" {
+		return nil
+	}
+
+	var warnings []string
+	switch configuration[OptimizeSchedulingStrategyKey] {
+	case OptimizeSchedulingStrategyAllInSpot:
+		// The batch workloads retry their Pods, a single replica on spot is fine for them.
+		if replicas == 1 && workloadType != "Job" {
+			warnings = append(warnings, fmt.Sprintf("%s with a single replica (%s) and strategy %s is unavailable "+
+				"whenever its spot node is interrupted.", workloadType, replicasSource, OptimizeSchedulingStrategyAllInSpot))
+		}
+	case OptimizeSchedulingStrategyCustom:
+		if customOnDemand, err := strconv.Atoi(configuration[OptimizeSchedulingStrategyCustomOnDemandKey]); err == nil &&
+			customOnDemand > replicas {
+			warnings = append(warnings, fmt.Sprintf("%s (%d) is more than the %s (%d), all the replicas are placed on "+
+				"on-demand while %s runs with fewer replicas than the count.", OptimizeSchedulingStrategyCustomOnDemandKey,
+				customOnDemand, replicasSource, replicas, workloadType))
+		}
+		if hpaName != "" {
+			warnings = append(warnings, fmt.Sprintf("%s is a fixed count while HorizontalPodAutoscaler %s scales %s, "+
+				"the replicas added by scaling out are all placed on spot.", OptimizeSchedulingStrategyCustomOnDemandKey,
+				hpaName, workloadType))
+		}
+	}
+	return warnings
+}
@@ -6,6 +6,9 @@ type PodAffinitySettingName string
 
 const (
 	PodAffinityLabelKey = "vacant.sh/affinity"
+	// PodAffinityDryRunAnnotationKey records the PodAffinitySettingName decided for a Pod in the dry-run mode,
+	// instead of the PodAffinityLabelKey and the node affinity.
+	PodAffinityDryRunAnnotationKey = "vacant.sh/dry-run-affinity"
 
 	PodAffinityOnDemand PodAffinitySettingName = "on-demand"
 	PodAffinitySpot     PodAffinitySettingName = "spot"
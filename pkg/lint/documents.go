@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// StdinPath is the path which reads the manifests from the stdin, such as the output of kustomize build.
+const StdinPath = "-"
+
+// manifestExtensions are the extensions of the files read from the directories.
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// Document is an object in the manifests.
+type Document struct {
+	// File is the path of the manifest, or StdinPath.
+	File string
+	// Line is where the document starts in the file, starting from 1.
+	Line int
+	// Lines are the lines of the document, used to locate the findings.
+	Lines  []string
+	Object *unstructured.Unstructured
+}
+
+// ReadDocuments reads the objects from the files, the directories recursively, or the stdin if the path is StdinPath.
+// A file may contain multiple YAML documents, and the items of a List are read as separate objects.
+func ReadDocuments(paths []string, stdin io.Reader) ([]*Document, error) {
+	var documents []*Document
+	for _, path := range paths {
+		if path == StdinPath {
+			docs, err := readDocuments(StdinPath, stdin)
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, docs...)
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			// The files in the directories are filtered by the extension, but a file given explicitly is always read.
+			if file != path && !hasManifestExtension(file) {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			docs, err := readDocuments(file, f)
+			if err != nil {
+				return err
+			}
+			documents = append(documents, docs...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return documents, nil
+}
+
+func hasManifestExtension(file string) bool {
+	for _, extension := range manifestExtensions {
+		if strings.EqualFold(filepath.Ext(file), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// readDocuments splits the YAML documents by the "---" lines, a JSON file is read as a single document.
+func readDocuments(file string, r io.Reader) ([]*Document, error) {
+	var documents []*Document
+
+	var lines []string
+	startLine := 1
+	flush := func() error {
+		docs, err := decodeDocument(file, startLine, lines)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, docs...)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if line == "---" || strings.HasPrefix(line, "--- ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			lines, startLine = nil, lineNumber+1
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// decodeDocument decodes a YAML or JSON document, an empty document returns nothing.
+func decodeDocument(file string, startLine int, lines []string) ([]*Document, error) {
+	// Skip the leading comments and blank lines, so that the document points to its first field.
+	for len(lines) > 0 {
+		trimmed := strings.TrimSpace(lines[0])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		lines, startLine = lines[1:], startLine+1
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	content := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &content); err != nil {
+		return nil, fmt.Errorf("%s:%d: failed to decode the document: %v", file, startLine, err)
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	obj := &unstructured.Unstructured{Object: content}
+	if !obj.IsList() {
+		return []*Document{{File: file, Line: startLine, Lines: lines, Object: obj}}, nil
+	}
+
+	list, err := obj.ToList()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: failed to decode the list: %v", file, startLine, err)
+	}
+	documents := make([]*Document, 0, len(list.Items))
+	for i := range list.Items {
+		documents = append(documents, &Document{File: file, Line: startLine, Lines: lines, Object: &list.Items[i]})
+	}
+	return documents, nil
+}
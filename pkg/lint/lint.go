@@ -0,0 +1,213 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/utils"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+
+	// RuleConfiguration reports the invalid values of the optimize scheduling labels and annotations.
+	RuleConfiguration = "optimize-scheduling-configuration"
+	// RuleReplicas reports the custom on-demand count which can't be satisfied by the replicas.
+	RuleReplicas = "optimize-scheduling-replicas"
+	// RuleWarning reports the configuration which is valid but has no effect or is risky.
+	RuleWarning = "optimize-scheduling-warning"
+)
+
+// Finding is an error or a warning of an object, the errors are denied by the admission webhook,
+// while the warnings are returned by it without blocking the object.
+type Finding struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Severity  string `json:"severity"`
+	Rule      string `json:"rule"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Field is the path of the invalid value, such as metadata.labels[vacant.sh/optimize-scheduling].
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Lint checks the objects the same way as the validating webhooks, the HorizontalPodAutoscalers in the documents
+// are used to check the replicas of the workloads they target.
+func Lint(documents []*Document) []*Finding {
+	hpas := map[string][]autoscalingv2.HorizontalPodAutoscaler{}
+	for _, document := range documents {
+		if document.Object.GetKind() != "HorizontalPodAutoscaler" {
+			continue
+		}
+		hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+		// The HorizontalPodAutoscalers of autoscaling/v1 have the same scaleTargetRef and minReplicas.
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(document.Object.Object, hpa); err != nil {
+			continue
+		}
+		hpas[hpa.Namespace] = append(hpas[hpa.Namespace], *hpa)
+	}
+
+	var findings []*Finding
+	for _, document := range documents {
+		findings = append(findings, lintDocument(document, hpas)...)
+	}
+	return findings
+}
+
+func lintDocument(document *Document, hpas map[string][]autoscalingv2.HorizontalPodAutoscaler) []*Finding {
+	obj := document.Object
+	gvk := obj.GroupVersionKind()
+	metadataPath := field.NewPath("metadata")
+
+	var findings []*Finding
+	addErrors := func(rule string, errs field.ErrorList) {
+		for _, err := range errs {
+			findings = append(findings, newFinding(document, SeverityError, rule, err.Field, err.ErrorBody()))
+		}
+	}
+	addWarnings := func(warnings []string) {
+		for _, warning := range warnings {
+			path, message := splitWarning(warning)
+			findings = append(findings, newFinding(document, SeverityWarning, RuleWarning, path, message))
+		}
+	}
+
+	switch {
+	case gvk.Group == appsv1.GroupName && (gvk.Kind == "Deployment" || gvk.Kind == "StatefulSet"):
+		errs := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath)
+		if len(errs) > 0 {
+			addErrors(RuleConfiguration, errs)
+			return findings
+		}
+
+		// The manifests are checked as they are created.
+		hpa := utils.FindTargetingHPA(hpas[obj.GetNamespace()], gvk, obj.GetName())
+		errs, replicasWarnings := utils.CheckWorkloadReplicas(gvk.Kind, nil, obj, getReplicas(obj), hpa, metadataPath)
+		if len(errs) > 0 {
+			addErrors(RuleReplicas, errs)
+			return findings
+		}
+
+		addWarnings(optimizescheduling.WarnOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath))
+		addWarnings(replicasWarnings)
+	case gvk.Group == appsv1.GroupName && gvk.Kind == "ReplicaSet", gvk.Group == "batch" && gvk.Kind == "Job":
+		errs := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath)
+		if len(errs) > 0 {
+			addErrors(RuleConfiguration, errs)
+			return findings
+		}
+		addWarnings(optimizescheduling.WarnOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath))
+	case gvk.Group == "batch" && gvk.Kind == "CronJob":
+		jobTemplatePath := field.NewPath("spec", "jobTemplate", "metadata")
+		jobTemplateLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "jobTemplate", "metadata", "labels")
+		jobTemplateAnnotations, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "jobTemplate", "metadata", "annotations")
+
+		errs := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath)
+		errs = append(errs, optimizescheduling.ValidateOptimizeSchedulingConfiguration(jobTemplateLabels,
+			jobTemplateAnnotations, jobTemplatePath)...)
+		if len(errs) > 0 {
+			addErrors(RuleConfiguration, errs)
+			return findings
+		}
+		addWarnings(optimizescheduling.WarnCronJobOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath))
+		addWarnings(optimizescheduling.WarnOptimizeSchedulingConfiguration(jobTemplateLabels, jobTemplateAnnotations, jobTemplatePath))
+	}
+
+	return findings
+}
+
+// getReplicas returns the spec.replicas of the workload, or nil if it's not set.
+// The numbers are decoded as float64 from the YAML manifests, but as int64 from the typed objects.
+func getReplicas(obj *unstructured.Unstructured) *int32 {
+	value, found, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "replicas")
+	if !found || err != nil {
+		return nil
+	}
+
+	var replicas int32
+	switch v := value.(type) {
+	case int64:
+		replicas = int32(v)
+	case float64:
+		replicas = int32(v)
+	default:
+		return nil
+	}
+	return &replicas
+}
+
+func newFinding(document *Document, severity, rule, path, message string) *Finding {
+	obj := document.Object
+	return &Finding{
+		File:      document.File,
+		Line:      locate(document, path),
+		Severity:  severity,
+		Rule:      rule,
+		Kind:      obj.GetKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Field:     path,
+		Message:   message,
+	}
+}
+
+// splitWarning splits the field path from the warnings in the format of "path: message".
+func splitWarning(warning string) (string, string) {
+	path, message, found := strings.Cut(warning, ": ")
+	if !found || strings.ContainsAny(path, " ") {
+		return "", warning
+	}
+	return path, message
+}
+
+// locate returns the line of the key of the labels or annotations in the path, such as
+// metadata.labels[vacant.sh/optimize-scheduling], or the first line of the document if it's not found.
+func locate(document *Document, path string) int {
+	start := strings.LastIndex(path, "[")
+	if start < 0 || !strings.HasSuffix(path, "]") {
+		return document.Line
+	}
+	key := path[start+1 : len(path)-1]
+	// The same key may be in both the labels and the annotations, search it after the map of the path.
+	mapName := path[strings.LastIndex(path[:start], ".")+1 : start]
+
+	inMap := false
+	for i, line := range document.Lines {
+		if !inMap {
+			inMap = strings.Contains(line, mapName+":") || strings.Contains(line, `"`+mapName+`"`)
+			continue
+		}
+		if strings.Contains(line, key) {
+			return document.Line + i
+		}
+	}
+	return document.Line
+}
+
+// String formats the finding in the format of "file:line: severity: Kind namespace/name: field: message".
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Severity, f.describe())
+}
+
+// describe formats the finding without the location.
+func (f *Finding) describe() string {
+	name := f.Name
+	if f.Namespace != "" {
+		name = f.Namespace + "/" + f.Name
+	}
+	message := f.Message
+	if f.Field != "" {
+		message = f.Field + ": " + f.Message
+	}
+	return fmt.Sprintf("%s %s: %s", f.Kind, name, message)
+}
@@ -0,0 +1,111 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+const customDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  labels:
+    vacant.sh/optimize-scheduling: "true"
+  annotations:
+    vacant.sh/optimize-scheduling-strategy: custom
+    vacant.sh/optimize-scheduling-strategy-custom-on-demand: "3"
+spec:
+`
+
+const webHPA = `---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: default
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+  minReplicas: 2
+  maxReplicas: 10
+`
+
+func TestLintReplicas(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expect   []string
+	}{
+		{
+			name:     "enough replicas",
+			manifest: customDeployment + "  replicas: 5\n",
+		},
+		{
+			name:     "too few replicas",
+			manifest: customDeployment + "  replicas: 2\n",
+			expect:   []string{SeverityError + "/" + RuleReplicas},
+		},
+		{
+			name:     "replicas unset",
+			manifest: customDeployment + "  minReadySeconds: 0\n",
+			expect:   []string{SeverityError + "/" + RuleReplicas},
+		},
+		{
+			name:     "autoscaled within maxReplicas",
+			manifest: customDeployment + "  replicas: 2\n" + webHPA,
+			// The count is more than the minReplicas, and it's fixed while the replicas are scaled.
+			expect: []string{SeverityWarning + "/" + RuleWarning, SeverityWarning + "/" + RuleWarning},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			documents, err := ReadDocuments([]string{StdinPath}, strings.NewReader(tc.manifest))
+			if err != nil {
+				t.Fatalf("failed to read the documents: %v", err)
+			}
+
+			var got []string
+			for _, finding := range Lint(documents) {
+				got = append(got, finding.Severity+"/"+finding.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.expect, ",") {
+				t.Errorf("expect findings %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestGetReplicas(t *testing.T) {
+	testCases := []struct {
+		name     string
+		replicas interface{}
+		expect   *int32
+	}{
+		{name: "unset"},
+		{name: "float64", replicas: float64(3), expect: ptr.To[int32](3)},
+		{name: "int64", replicas: int64(3), expect: ptr.To[int32](3)},
+		{name: "invalid", replicas: "3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := map[string]interface{}{}
+			if tc.replicas != nil {
+				spec["replicas"] = tc.replicas
+			}
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+
+			got := getReplicas(obj)
+			if (got == nil) != (tc.expect == nil) || (got != nil && *got != *tc.expect) {
+				t.Errorf("expect replicas %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	OutputText  = "text"
+	OutputJSON  = "json"
+	OutputSARIF = "sarif"
+)
+
+var Outputs = sets.NewString(OutputText, OutputJSON, OutputSARIF)
+
+// sarifToolName is the name of the tool in the SARIF log.
+const sarifToolName = "vmanager-lint"
+
+var ruleDescriptions = map[string]string{
+	RuleConfiguration: "The optimize scheduling labels and annotations must have valid values.",
+	RuleReplicas:      "The custom on-demand count must not exceed the replicas or the minReplicas of the HorizontalPodAutoscaler.",
+	RuleWarning:       "The optimize scheduling configuration has no effect or is risky.",
+}
+
+// Write writes the findings in the output format.
+func Write(w io.Writer, output string, findings []*Finding) error {
+	switch output {
+	case OutputText:
+		return writeText(w, findings)
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if findings == nil {
+			findings = []*Finding{}
+		}
+		return encoder.Encode(findings)
+	case OutputSARIF:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(newSARIFLog(findings))
+	}
+	return fmt.Errorf("unknown output %s, must be in %v", output, Outputs.List())
+}
+
+func writeText(w io.Writer, findings []*Finding) error {
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errors++
+		}
+		if _, err := fmt.Fprintln(w, finding); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d errors, %d warnings\n", errors, len(findings)-errors)
+	return err
+}
+
+// The types below are the subset of the SARIF 2.1.0 log used by the code scanning tools, such as GitHub.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func newSARIFLog(findings []*Finding) *sarifLog {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: sarifToolName}},
+		Results: []sarifResult{},
+	}
+	for _, rule := range sets.StringKeySet(ruleDescriptions).List() {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               rule,
+			ShortDescription: sarifMessage{Text: ruleDescriptions[rule]},
+		})
+	}
+
+	for _, finding := range findings {
+		uri := filepath.ToSlash(finding.File)
+		if finding.File == StdinPath {
+			uri = "stdin"
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  finding.Rule,
+			Level:   finding.Severity,
+			Message: sarifMessage{Text: finding.describe()},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: uri},
+				Region:           sarifRegion{StartLine: finding.Line},
+			}}},
+		})
+	}
+
+	return &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
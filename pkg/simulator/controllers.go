@@ -0,0 +1,331 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+// The functions below play the role of the workload controllers, the Pods are created through admitPod,
+// which decides the affinity of the Pod the same way as the mutating webhook.
+
+// applyWorkload creates the workload, and its Pods up to the replicas.
+func (s *Simulator) applyWorkload(ctx context.Context, obj runtime.Object) error {
+	w := &workload{}
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		deployment, err := s.kubeClient.AppsV1().Deployments(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		w.Type, w.deployment = "Deployment", deployment
+	case *appsv1.StatefulSet:
+		statefulSet, err := s.kubeClient.AppsV1().StatefulSets(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		w.Type, w.statefulSet = "StatefulSet", statefulSet
+	default:
+		return fmt.Errorf("%T is not supported", obj)
+	}
+	w.Key = types.NamespacedName{Namespace: w.object().GetNamespace(), Name: w.object().GetName()}
+	s.workloads = append(s.workloads, w)
+
+	if err := s.waitForCache(ctx, w); err != nil {
+		return err
+	}
+	if w.deployment != nil {
+		if err := s.newReplicaSet(ctx, w); err != nil {
+			return err
+		}
+	}
+	return s.reconcile(ctx, w)
+}
+
+// newReplicaSet creates a new revision of the Deployment. It's not waited for, the cache gets the ReplicaSet of a new Pod
+// from the clientset if it has not been handled yet.
+func (s *Simulator) newReplicaSet(ctx context.Context, w *workload) error {
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: w.deployment.Namespace,
+			Name:      fmt.Sprintf("%s-%d", w.deployment.Name, len(w.replicaSets)+1),
+			Labels:    w.deployment.Spec.Template.Labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(w.deployment, appsv1.SchemeGroupVersion.WithKind("Deployment")),
+			},
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: ptr.To[int32](0),
+			Selector: w.deployment.Spec.Selector,
+			Template: w.deployment.Spec.Template,
+		},
+	}
+	replicaSet, err := s.kubeClient.AppsV1().ReplicaSets(replicaSet.Namespace).Create(ctx, replicaSet, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	w.replicaSets = append(w.replicaSets, replicaSet)
+	return nil
+}
+
+// reconcile creates or deletes the Pods one by one until the workload has the replicas.
+func (s *Simulator) reconcile(ctx context.Context, w *workload) error {
+	for len(w.pods) < w.replicas() {
+		pod, err := s.admitPod(ctx, s.newPod(w))
+		if err != nil {
+			return err
+		}
+		if err := s.createPods(ctx, w, pod); err != nil {
+			return err
+		}
+	}
+	for len(w.pods) > w.replicas() {
+		// The newest Pod is deleted first, like the StatefulSet deletes the highest ordinal.
+		if err := s.deletePods(ctx, w, w.pods[len(w.pods)-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scale updates the replicas of the workload, then reconciles its Pods.
+func (s *Simulator) scale(ctx context.Context, w *workload, replicas int) error {
+	if err := s.updateWorkload(ctx, w, func(obj metav1.Object) {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			o.Spec.Replicas = ptr.To(int32(replicas))
+		case *appsv1.StatefulSet:
+			o.Spec.Replicas = ptr.To(int32(replicas))
+		}
+	}); err != nil {
+		return err
+	}
+	return s.reconcile(ctx, w)
+}
+
+// burstCreate scales up the workload by the count, all the new Pods are admitted before any of them is created.
+func (s *Simulator) burstCreate(ctx context.Context, w *workload, count int) error {
+	if err := s.updateWorkload(ctx, w, func(obj metav1.Object) {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			o.Spec.Replicas = ptr.To(*o.Spec.Replicas + int32(count))
+		case *appsv1.StatefulSet:
+			o.Spec.Replicas = ptr.To(*o.Spec.Replicas + int32(count))
+		}
+	}); err != nil {
+		return err
+	}
+
+	var pods []*corev1.Pod
+	for len(w.pods)+len(pods) < w.replicas() {
+		pod := s.newPod(w)
+		// The names of the StatefulSet Pods are the ordinals, which count the Pods not created yet.
+		if w.statefulSet != nil {
+			pod.Name = fmt.Sprintf("%s-%d", w.statefulSet.Name, len(w.pods)+len(pods))
+		}
+		pod, err := s.admitPod(ctx, pod)
+		if err != nil {
+			return err
+		}
+		pods = append(pods, pod)
+	}
+	return s.createPods(ctx, w, pods...)
+}
+
+// rollout replaces all the Pods of the workload one by one.
+func (s *Simulator) rollout(ctx context.Context, w *workload) error {
+	if w.statefulSet != nil {
+		// The StatefulSet recreates the Pods from the highest ordinal, with the same names.
+		for i := len(w.pods) - 1; i >= 0; i-- {
+			old := w.pods[i]
+			if err := s.deletePods(ctx, w, old); err != nil {
+				return err
+			}
+			pod := s.newPod(w)
+			pod.Name = old.Name
+			pod, err := s.admitPod(ctx, pod)
+			if err != nil {
+				return err
+			}
+			if err := s.createPods(ctx, w, pod); err != nil {
+				return err
+			}
+			// Keep the Pods in the order of the ordinals.
+			w.pods = append(w.pods[:i], append([]*corev1.Pod{pod}, w.pods[i:len(w.pods)-1]...)...)
+		}
+		return nil
+	}
+
+	// The Deployment surges a Pod of the new ReplicaSet before deleting an old one.
+	if err := s.newReplicaSet(ctx, w); err != nil {
+		return err
+	}
+	oldPods := append([]*corev1.Pod{}, w.pods...)
+	for _, old := range oldPods {
+		pod, err := s.admitPod(ctx, s.newPod(w))
+		if err != nil {
+			return err
+		}
+		if err := s.createPods(ctx, w, pod); err != nil {
+			return err
+		}
+		if err := s.deletePods(ctx, w, old); err != nil {
+			return err
+		}
+	}
+	return s.reconcile(ctx, w)
+}
+
+// interruptSpot biases the workload to on-demand and evicts its spot Pods like the node interruption controller,
+// then the evicted Pods are replaced.
+func (s *Simulator) interruptSpot(ctx context.Context, w *workload, count int) error {
+	var evicted []*corev1.Pod
+	for _, pod := range w.pods {
+		if pod.Labels[podaffinity.PodAffinityLabelKey] == string(podaffinity.PodAffinitySpot) {
+			evicted = append(evicted, pod)
+		}
+		if count > 0 && len(evicted) == count {
+			break
+		}
+	}
+	if len(evicted) == 0 {
+		return nil
+	}
+
+	for _, pod := range evicted {
+		s.cache.BiasToOnDemand(pod, s.config.Get().NodeInterruption.OnDemandBiasDuration.Duration)
+	}
+	if err := s.deletePods(ctx, w, evicted...); err != nil {
+		return err
+	}
+	return s.reconcile(ctx, w)
+}
+
+// setStrategy sets the strategy annotations of the workload, which take precedence over the labels.
+func (s *Simulator) setStrategy(ctx context.Context, w *workload, strategy string, customOnDemand *int) error {
+	return s.updateWorkload(ctx, w, func(obj metav1.Object) {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[optimizescheduling.OptimizeSchedulingStrategyKey] = strategy
+		delete(annotations, optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey)
+		if customOnDemand != nil {
+			annotations[optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey] = strconv.Itoa(*customOnDemand)
+		}
+		obj.SetAnnotations(annotations)
+	})
+}
+
+// updateWorkload mutates the workload and waits for the cache to handle the update.
+func (s *Simulator) updateWorkload(ctx context.Context, w *workload, mutate func(obj metav1.Object)) error {
+	if w.deployment != nil {
+		deployment := w.deployment.DeepCopy()
+		mutate(deployment)
+		deployment, err := s.kubeClient.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		w.deployment = deployment
+		return s.waitForCache(ctx, w)
+	}
+
+	statefulSet := w.statefulSet.DeepCopy()
+	mutate(statefulSet)
+	statefulSet, err := s.kubeClient.AppsV1().StatefulSets(statefulSet.Namespace).Update(ctx, statefulSet, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	w.statefulSet = statefulSet
+	return s.waitForCache(ctx, w)
+}
+
+// newPod builds a Pod of the current revision of the workload.
+func (s *Simulator) newPod(w *workload) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         w.Key.Namespace,
+			CreationTimestamp: metav1.NewTime(s.clock.Now()),
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	if w.deployment != nil {
+		replicaSet := w.replicaSets[len(w.replicaSets)-1]
+		s.podSequence++
+		pod.Name = fmt.Sprintf("%s-%05d", replicaSet.Name, s.podSequence)
+		pod.Labels = replicaSet.Spec.Template.Labels
+		pod.OwnerReferences = []metav1.OwnerReference{
+			*metav1.NewControllerRef(replicaSet, appsv1.SchemeGroupVersion.WithKind("ReplicaSet")),
+		}
+		pod.Spec = replicaSet.Spec.Template.Spec
+		return pod
+	}
+
+	pod.Name = fmt.Sprintf("%s-%d", w.statefulSet.Name, len(w.pods))
+	pod.Labels = w.statefulSet.Spec.Template.Labels
+	pod.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(w.statefulSet, appsv1.SchemeGroupVersion.WithKind("StatefulSet")),
+	}
+	pod.Spec = w.statefulSet.Spec.Template.Spec
+	return pod
+}
+
+// admitPod decides the affinity of the new Pod with the cache, and labels it like the mutating webhook.
+// The dry-run mode is ignored, the simulator replays the placement as if it was enforced.
+func (s *Simulator) admitPod(ctx context.Context, pod *corev1.Pod) (*corev1.Pod, error) {
+	pod = pod.DeepCopy()
+	affinity, _ := s.cache.DetermineNewPodAffinityPreference(ctx, pod)
+	if affinity == podaffinity.PodAffinityUnset {
+		return pod, nil
+	}
+
+	labels := map[string]string{}
+	for key, value := range pod.Labels {
+		labels[key] = value
+	}
+	labels[podaffinity.PodAffinityLabelKey] = string(affinity)
+	pod.Labels = labels
+	return pod, nil
+}
+
+// createPods creates the admitted Pods and waits for the cache to count them.
+func (s *Simulator) createPods(ctx context.Context, w *workload, pods ...*corev1.Pod) error {
+	for _, pod := range pods {
+		pod, err := s.kubeClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		s.printPod("+", pod)
+		w.pods = append(w.pods, pod)
+	}
+	return s.waitForCache(ctx, w)
+}
+
+// deletePods deletes the Pods and waits for the cache to stop counting them.
+func (s *Simulator) deletePods(ctx context.Context, w *workload, pods ...*corev1.Pod) error {
+	for _, pod := range pods {
+		if err := s.kubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+		s.printPod("-", pod)
+
+		for i := range w.pods {
+			if w.pods[i] == pod {
+				w.pods = append(w.pods[:i], w.pods[i+1:]...)
+				break
+			}
+		}
+	}
+	return s.waitForCache(ctx, w)
+}
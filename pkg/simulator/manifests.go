@@ -0,0 +1,82 @@
+package simulator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+)
+
+// LoadWorkloadsFromFiles reads the Deployments and StatefulSets from the YAML or JSON manifests,
+// a file may contain multiple documents, the objects of the other kinds such as Services are skipped.
+func LoadWorkloadsFromFiles(paths []string) ([]runtime.Object, error) {
+	var workloads []runtime.Object
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest file %s: %v", path, err)
+		}
+		objs, err := decodeWorkloads(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifest file %s: %v", path, err)
+		}
+		workloads = append(workloads, objs...)
+	}
+	return workloads, nil
+}
+
+func decodeWorkloads(r io.Reader) ([]runtime.Object, error) {
+	var workloads []runtime.Object
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return workloads, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(document, nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			if o.Namespace == "" {
+				o.Namespace = metav1.NamespaceDefault
+			}
+			if o.Spec.Replicas == nil {
+				o.Spec.Replicas = ptr.To[int32](1)
+			}
+		case *appsv1.StatefulSet:
+			if o.Namespace == "" {
+				o.Namespace = metav1.NamespaceDefault
+			}
+			if o.Spec.Replicas == nil {
+				o.Spec.Replicas = ptr.To[int32](1)
+			}
+		default:
+			continue
+		}
+		workloads = append(workloads, obj)
+	}
+}
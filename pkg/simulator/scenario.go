@@ -0,0 +1,172 @@
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+const (
+	// ActionScale scales the workload to the Replicas, the Pods are created or deleted one by one.
+	ActionScale = "scale"
+	// ActionBurstCreate scales up the workload by the Count, all the Pods are admitted before the cache sees any of them,
+	// like the Pods created concurrently.
+	ActionBurstCreate = "burst-create"
+	// ActionRollout replaces all the Pods of the workload, a Deployment surges a Pod of the new ReplicaSet before
+	// deleting an old one, a StatefulSet recreates its Pods from the highest ordinal.
+	ActionRollout = "rollout"
+	// ActionInterruptSpot evicts the spot Pods of the workload, or of all the workloads if it's not specified,
+	// the same way as the node interruption controller, then the Pods are replaced.
+	ActionInterruptSpot = "interrupt-spot"
+	// ActionSetStrategy changes the strategy annotations of the workload, the existing Pods are kept.
+	ActionSetStrategy = "set-strategy"
+	// ActionWait advances the time of the simulation by the Duration at once, such as for the on-demand bias to expire.
+	ActionWait = "wait"
+)
+
+var actions = sets.NewString(ActionScale, ActionBurstCreate, ActionRollout, ActionInterruptSpot, ActionSetStrategy, ActionWait)
+
+// Scenario is the script of the events replayed by the simulator.
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+type Step struct {
+	Action string `json:"action"`
+	// Workload is the target of the action in the format of TYPE/NAME, such as deploy/web or sts/db.
+	Workload string `json:"workload,omitempty"`
+	// Namespace of the Workload, default to "default".
+	Namespace string `json:"namespace,omitempty"`
+
+	// Replicas is required by ActionScale.
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Count is required by ActionBurstCreate, it limits the evicted Pods of ActionInterruptSpot if it's not 0.
+	Count int `json:"count,omitempty"`
+	// Strategy and CustomOnDemand are used by ActionSetStrategy.
+	Strategy       string `json:"strategy,omitempty"`
+	CustomOnDemand *int   `json:"customOnDemand,omitempty"`
+	// Duration is required by ActionWait.
+	Duration metav1.Duration `json:"duration,omitempty"`
+}
+
+// String describes the step in the timeline.
+func (s *Step) String() string {
+	switch s.Action {
+	case ActionScale:
+		return fmt.Sprintf("scale %s to %d", s.Workload, *s.Replicas)
+	case ActionBurstCreate:
+		return fmt.Sprintf("burst-create %d pods of %s", s.Count, s.Workload)
+	case ActionInterruptSpot:
+		target := s.Workload
+		if target == "" {
+			target = "all workloads"
+		}
+		if s.Count > 0 {
+			return fmt.Sprintf("interrupt %d spot pods of %s", s.Count, target)
+		}
+		return fmt.Sprintf("interrupt the spot pods of %s", target)
+	case ActionSetStrategy:
+		if s.CustomOnDemand != nil {
+			return fmt.Sprintf("set the strategy of %s to %s with %d on-demand", s.Workload, s.Strategy, *s.CustomOnDemand)
+		}
+		return fmt.Sprintf("set the strategy of %s to %s", s.Workload, s.Strategy)
+	case ActionWait:
+		return fmt.Sprintf("wait %v", s.Duration.Duration)
+	}
+	return fmt.Sprintf("%s %s", s.Action, s.Workload)
+}
+
+// LoadScenarioFromFile reads the Scenario from a YAML or JSON file.
+func LoadScenarioFromFile(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file %s: %v", path, err)
+	}
+
+	scenario := &Scenario{}
+	if err := yaml.UnmarshalStrict(data, scenario); err != nil {
+		return nil, fmt.Errorf("failed to load scenario file %s: %v", path, err)
+	}
+	for i := range scenario.Steps {
+		if scenario.Steps[i].Namespace == "" {
+			scenario.Steps[i].Namespace = metav1.NamespaceDefault
+		}
+	}
+	return scenario, nil
+}
+
+func (s *Scenario) Validate() field.ErrorList {
+	var errs field.ErrorList
+
+	for i, step := range s.Steps {
+		stepPath := field.NewPath("steps").Index(i)
+		if !actions.Has(step.Action) {
+			errs = append(errs, field.NotSupported(stepPath.Child("action"), step.Action, actions.List()))
+			continue
+		}
+
+		if step.Workload != "" {
+			if _, _, err := parseWorkloadReference(step.Workload); err != nil {
+				errs = append(errs, field.Invalid(stepPath.Child("workload"), step.Workload, err.Error()))
+			}
+		} else if step.Action != ActionInterruptSpot && step.Action != ActionWait {
+			errs = append(errs, field.Required(stepPath.Child("workload"), fmt.Sprintf("required by %s", step.Action)))
+		}
+
+		switch step.Action {
+		case ActionScale:
+			if step.Replicas == nil {
+				errs = append(errs, field.Required(stepPath.Child("replicas"), "required by scale"))
+			} else if *step.Replicas < 0 {
+				errs = append(errs, field.Invalid(stepPath.Child("replicas"), *step.Replicas, "must be greater than or equal to 0"))
+			}
+		case ActionBurstCreate:
+			if step.Count <= 0 {
+				errs = append(errs, field.Invalid(stepPath.Child("count"), step.Count, "must be greater than 0"))
+			}
+		case ActionInterruptSpot:
+			if step.Count < 0 {
+				errs = append(errs, field.Invalid(stepPath.Child("count"), step.Count, "must be greater than or equal to 0"))
+			}
+		case ActionSetStrategy:
+			if !optimizescheduling.OptimizeSchedulingStrategies.Has(step.Strategy) {
+				errs = append(errs, field.NotSupported(stepPath.Child("strategy"), step.Strategy,
+					optimizescheduling.OptimizeSchedulingStrategies.List()))
+			}
+			if step.Strategy == optimizescheduling.OptimizeSchedulingStrategyCustom &&
+				(step.CustomOnDemand == nil || *step.CustomOnDemand < 0) {
+				errs = append(errs, field.Required(stepPath.Child("customOnDemand"),
+					"must be greater than or equal to 0 for the custom strategy"))
+			}
+		case ActionWait:
+			if step.Duration.Duration <= 0 {
+				errs = append(errs, field.Invalid(stepPath.Child("duration"), step.Duration.Duration, "must be greater than 0"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// parseWorkloadReference parses the workload such as deploy/web or sts/db into the workload type and name.
+func parseWorkloadReference(workload string) (string, string, error) {
+	resource, name, found := strings.Cut(workload, "/")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("must be in the format of TYPE/NAME, such as deploy/web")
+	}
+
+	switch strings.ToLower(resource) {
+	case "deploy", "deployment", "deployments":
+		return "Deployment", name, nil
+	case "sts", "statefulset", "statefulsets":
+		return "StatefulSet", name, nil
+	}
+	return "", "", fmt.Errorf("the workload type %q is not supported, must be a Deployment or StatefulSet", resource)
+}
@@ -0,0 +1,248 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"vacant.sh/vmanager/pkg/config"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
+)
+
+// syncTimeout is how long to wait for the cache to handle the objects changed by the simulator.
+const syncTimeout = 10 * time.Second
+
+// Simulator replays a Scenario against the WebhookCache backed by a fake clientset, the simulator plays the role of
+// the workload controllers and the webhook: it decides the affinity of every new Pod with the cache before creating it.
+type Simulator struct {
+	config     *config.Holder
+	kubeClient *fake.Clientset
+	cache      *cache.WebhookCache
+	// clock is the time of the simulation shared with the cache, it's only stepped by the wait actions.
+	clock *clocktesting.FakeClock
+
+	// workloads are kept in the order of the manifests.
+	workloads []*workload
+	out       io.Writer
+	// podSequence generates the names of the Pods of the ReplicaSets.
+	podSequence int
+}
+
+// workload is the state of a Deployment or StatefulSet which is managed by the simulator.
+type workload struct {
+	Type string
+	Key  types.NamespacedName
+
+	deployment  *appsv1.Deployment
+	statefulSet *appsv1.StatefulSet
+	// replicaSets are the revisions of the Deployment, the last one is the current revision.
+	replicaSets []*appsv1.ReplicaSet
+
+	// pods are the live Pods in the order of creation.
+	pods []*corev1.Pod
+}
+
+func (w *workload) replicas() int {
+	if w.deployment != nil {
+		return int(*w.deployment.Spec.Replicas)
+	}
+	return int(*w.statefulSet.Spec.Replicas)
+}
+
+func (w *workload) object() metav1.Object {
+	if w.deployment != nil {
+		return w.deployment
+	}
+	return w.statefulSet
+}
+
+func NewSimulator(c *config.WebhookManagerConfiguration, out io.Writer) (*Simulator, error) {
+	kubeClient := fake.NewSimpleClientset()
+	configHolder := config.NewHolder(c)
+
+	// The generic workloads are not simulated, the resolver never finds their owners.
+	ownerResolver := owner.NewResolverForClients(metadatafake.NewSimpleMetadataClient(runtime.NewScheme()),
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), meta.NewDefaultRESTMapper(nil))
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wc, err := cache.NewWebhookCacheForClients(kubeClient, ownerResolver, configHolder, fakeClock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Simulator{
+		config:     configHolder,
+		kubeClient: kubeClient,
+		cache:      wc,
+		clock:      fakeClock,
+		out:        out,
+	}, nil
+}
+
+// Run applies the workloads with their initial Pods, then replays the steps of the Scenario. It returns the placement
+// of the workloads which enable the optimize scheduling after the last step.
+func (s *Simulator) Run(ctx context.Context, workloads []runtime.Object, scenario *Scenario) ([]*apis.WorkloadStatus, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.cache.Run(ctx.Done())
+
+	fmt.Fprintln(s.out, "#0 apply the workloads")
+	for _, obj := range workloads {
+		if err := s.applyWorkload(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	s.printResults()
+
+	for i := range scenario.Steps {
+		step := &scenario.Steps[i]
+		fmt.Fprintf(s.out, "\n#%d %s\n", i+1, step)
+		if err := s.runStep(ctx, step); err != nil {
+			return nil, fmt.Errorf("step #%d %s failed: %v", i+1, step, err)
+		}
+		s.printResults()
+	}
+
+	return s.getResults(), nil
+}
+
+func (s *Simulator) runStep(ctx context.Context, step *Step) error {
+	if step.Action == ActionWait {
+		s.clock.Step(step.Duration.Duration)
+		return nil
+	}
+	if step.Action == ActionInterruptSpot && step.Workload == "" {
+		for _, w := range s.workloads {
+			if err := s.interruptSpot(ctx, w, step.Count); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w, err := s.getWorkload(step.Workload, step.Namespace)
+	if err != nil {
+		return err
+	}
+
+	switch step.Action {
+	case ActionScale:
+		return s.scale(ctx, w, int(*step.Replicas))
+	case ActionBurstCreate:
+		return s.burstCreate(ctx, w, step.Count)
+	case ActionRollout:
+		return s.rollout(ctx, w)
+	case ActionInterruptSpot:
+		return s.interruptSpot(ctx, w, step.Count)
+	case ActionSetStrategy:
+		return s.setStrategy(ctx, w, step.Strategy, step.CustomOnDemand)
+	}
+	return fmt.Errorf("unknown action %s", step.Action)
+}
+
+func (s *Simulator) getWorkload(reference, namespace string) (*workload, error) {
+	workloadType, name, err := parseWorkloadReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	for _, w := range s.workloads {
+		if w.Type == workloadType && w.Key == key {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s is not in the manifests", workloadType, key)
+}
+
+// getResults returns the placement of the workloads which enable the optimize scheduling.
+func (s *Simulator) getResults() []*apis.WorkloadStatus {
+	var results []*apis.WorkloadStatus
+	for _, status := range s.cache.ListWorkloadStatuses() {
+		if status.Enable {
+			results = append(results, status)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].WorkloadKey != results[j].WorkloadKey {
+			return results[i].WorkloadKey.String() < results[j].WorkloadKey.String()
+		}
+		return results[i].WorkloadType < results[j].WorkloadType
+	})
+	return results
+}
+
+func (s *Simulator) printResults() {
+	for _, result := range s.getResults() {
+		met := "met"
+		if !result.Satisfied() {
+			met = fmt.Sprintf("not met, missing on-demand %+d, spot %+d", result.OnDemandDelta(), result.SpotDelta())
+		}
+		fmt.Fprintf(s.out, "  = %s %s (%s): target on-demand %d, spot %d; observed on-demand %d, spot %d; %s\n",
+			result.WorkloadType, result.WorkloadKey, result.Strategy, result.TargetOnDemandNum, result.TargetOnSpotNum,
+			result.ObservedOnDemandNum, result.ObservedOnSpotNum, met)
+	}
+}
+
+// waitForCache waits until the status of the workload in the cache reflects its current setting and the labels
+// of its live Pods, then the next Pod is decided with the changes of the simulator. The cache only counts the Pods
+// of the enabled workloads, the Pods of the disabled ones are placed anyway.
+func (s *Simulator) waitForCache(ctx context.Context, w *workload) error {
+	obj := w.object()
+	setting := apis.NewOptimizeSchedulingSetting(obj.GetLabels(), obj.GetAnnotations(), w.replicas(), w.Type,
+		&s.config.Get().DefaultStrategies)
+	var onDemandNum, spotNum int
+	if setting.Enable {
+		for _, pod := range w.pods {
+			switch podaffinity.PodAffinitySettingName(pod.Labels[podaffinity.PodAffinityLabelKey]) {
+			case podaffinity.PodAffinityOnDemand:
+				onDemandNum++
+			case podaffinity.PodAffinitySpot:
+				spotNum++
+			}
+		}
+	}
+
+	err := wait.PollUntilContextTimeout(ctx, 5*time.Millisecond, syncTimeout, true, func(context.Context) (bool, error) {
+		for _, status := range s.cache.ListWorkloadStatuses() {
+			if status.WorkloadType != w.Type || status.WorkloadKey != w.Key {
+				continue
+			}
+			if status.Enable != setting.Enable || status.Strategy != setting.Strategy {
+				return false, nil
+			}
+			return !setting.Enable || (status.TargetOnDemandNum == setting.TargetOnDemandNum &&
+				status.TargetOnSpotNum == setting.TargetOnSpotNum &&
+				status.ObservedOnDemandNum == onDemandNum && status.ObservedOnSpotNum == spotNum), nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s %s is not synced to the cache: %v", w.Type, w.Key, err)
+	}
+	return nil
+}
+
+func (s *Simulator) printPod(prefix string, pod *corev1.Pod) {
+	affinity := pod.Labels[podaffinity.PodAffinityLabelKey]
+	if affinity == "" {
+		affinity = string(podaffinity.PodAffinityUnset)
+	}
+	fmt.Fprintf(s.out, "  %s %s/%s %s\n", prefix, pod.Namespace, pod.Name, affinity)
+}
@@ -0,0 +1,231 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+const testManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    vacant.sh/optimize-scheduling: "true"
+    vacant.sh/optimize-scheduling-strategy: majority-in-on-demand
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: data
+  labels:
+    vacant.sh/optimize-scheduling: "true"
+    vacant.sh/optimize-scheduling-strategy: all-in-spot
+spec:
+  template:
+    metadata:
+      labels:
+        app: db
+`
+
+func newTestSimulator(t *testing.T) (*Simulator, *bytes.Buffer) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	s, err := NewSimulator(config.NewDefaultConfiguration(), out)
+	if err != nil {
+		t.Fatalf("failed to build the simulator: %v", err)
+	}
+	return s, out
+}
+
+func newTestDeployment(name string, replicas int32, strategy string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      name,
+			Labels: map[string]string{
+				optimizescheduling.OptimizeSchedulingKey:         "true",
+				optimizescheduling.OptimizeSchedulingStrategyKey: strategy,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}}},
+		},
+	}
+}
+
+// countAffinities returns the numbers of the live on-demand and spot Pods of the workload.
+func countAffinities(w *workload) (int, int) {
+	var onDemandNum, spotNum int
+	for _, pod := range w.pods {
+		switch podaffinity.PodAffinitySettingName(pod.Labels[podaffinity.PodAffinityLabelKey]) {
+		case podaffinity.PodAffinityOnDemand:
+			onDemandNum++
+		case podaffinity.PodAffinitySpot:
+			spotNum++
+		}
+	}
+	return onDemandNum, spotNum
+}
+
+func TestDecodeWorkloads(t *testing.T) {
+	workloads, err := decodeWorkloads(strings.NewReader(testManifests))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(workloads) != 2 {
+		t.Fatalf("expect 2 workloads, got %d", len(workloads))
+	}
+	deployment, ok := workloads[0].(*appsv1.Deployment)
+	if !ok || deployment.Namespace != metav1.NamespaceDefault || *deployment.Spec.Replicas != 3 {
+		t.Errorf("expect the Deployment default/web of 3 replicas, got %+v", workloads[0])
+	}
+	statefulSet, ok := workloads[1].(*appsv1.StatefulSet)
+	if !ok || statefulSet.Namespace != "data" || *statefulSet.Spec.Replicas != 1 {
+		t.Errorf("expect the StatefulSet data/db of 1 replica, got %+v", workloads[1])
+	}
+}
+
+func TestValidateScenario(t *testing.T) {
+	scenario := &Scenario{Steps: []Step{
+		{Action: ActionScale, Workload: "deploy/web", Replicas: ptr.To[int32](3)},
+		{Action: ActionScale, Workload: "deploy/web"},
+		{Action: ActionBurstCreate, Workload: "sts/db"},
+		{Action: ActionRollout, Workload: "job/batch"},
+		{Action: ActionRollout},
+		{Action: ActionInterruptSpot},
+		{Action: ActionSetStrategy, Workload: "deploy/web", Strategy: optimizescheduling.OptimizeSchedulingStrategyCustom},
+		{Action: ActionWait},
+		{Action: "restart", Workload: "deploy/web"},
+	}}
+
+	var fields []string
+	for _, err := range scenario.Validate() {
+		fields = append(fields, err.Field)
+	}
+	expect := []string{"steps[1].replicas", "steps[2].count", "steps[3].workload", "steps[4].workload",
+		"steps[6].customOnDemand", "steps[7].duration", "steps[8].action"}
+	if strings.Join(fields, ",") != strings.Join(expect, ",") {
+		t.Errorf("expect errors of %v, got %v", expect, fields)
+	}
+}
+
+func TestRun(t *testing.T) {
+	workloads, err := decodeWorkloads(strings.NewReader(testManifests))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scenario := &Scenario{Steps: []Step{
+		{Action: ActionScale, Workload: "deploy/web", Namespace: metav1.NamespaceDefault, Replicas: ptr.To[int32](5)},
+		{Action: ActionBurstCreate, Workload: "sts/db", Namespace: "data", Count: 2},
+		{Action: ActionSetStrategy, Workload: "deploy/web", Namespace: metav1.NamespaceDefault,
+			Strategy: optimizescheduling.OptimizeSchedulingStrategyCustom, CustomOnDemand: ptr.To(1)},
+		{Action: ActionRollout, Workload: "deploy/web", Namespace: metav1.NamespaceDefault},
+		{Action: ActionRollout, Workload: "sts/db", Namespace: "data"},
+		{Action: ActionScale, Workload: "deploy/web", Namespace: metav1.NamespaceDefault, Replicas: ptr.To[int32](2)},
+	}}
+
+	s, out := newTestSimulator(t)
+	statuses, err := s.Run(context.Background(), workloads, scenario)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("expect the statuses of 2 workloads, got %d", len(statuses))
+	}
+	for _, status := range statuses {
+		if !status.Satisfied() {
+			t.Errorf("expect %s %v to meet its target, got %+v\n%s", status.WorkloadType, status.WorkloadKey, status, out)
+		}
+	}
+	web, err := s.getWorkload("deploy/web", metav1.NamespaceDefault)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(web.replicaSets) != 2 || len(web.pods) != 2 {
+		t.Errorf("expect 2 ReplicaSets and 2 Pods of the rolled out Deployment, got %d and %d",
+			len(web.replicaSets), len(web.pods))
+	}
+	if onDemandNum, spotNum := countAffinities(web); onDemandNum != 1 || spotNum != 1 {
+		t.Errorf("expect 1 on-demand and 1 spot Pod of the custom strategy, got %d and %d", onDemandNum, spotNum)
+	}
+	if !strings.Contains(out.String(), "#6 scale deploy/web to 2") {
+		t.Errorf("expect the timeline of the steps, got\n%s", out)
+	}
+}
+
+func TestWaitForBiasToExpire(t *testing.T) {
+	scenario := &Scenario{Steps: []Step{
+		{Action: ActionInterruptSpot, Workload: "deploy/web", Namespace: metav1.NamespaceDefault, Count: 1},
+		{Action: ActionWait, Duration: metav1.Duration{Duration: 24 * time.Hour}},
+		{Action: ActionScale, Workload: "deploy/web", Namespace: metav1.NamespaceDefault, Replicas: ptr.To[int32](4)},
+	}}
+
+	s, out := newTestSimulator(t)
+	start := s.clock.Now()
+	workloads := []runtime.Object{newTestDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyAllInSpot)}
+	if _, err := s.Run(context.Background(), workloads, scenario); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+
+	// The wait step only advances the clock of the simulation.
+	if elapsed := s.clock.Since(start); elapsed != 24*time.Hour {
+		t.Errorf("expect the clock to be advanced by 24h, got %v", elapsed)
+	}
+	web, _ := s.getWorkload("deploy/web", metav1.NamespaceDefault)
+	var affinities []string
+	for _, pod := range web.pods {
+		affinities = append(affinities, pod.Labels[podaffinity.PodAffinityLabelKey])
+	}
+	// The replacement of the interrupted Pod is biased to on-demand, the bias expires before the scale.
+	expect := "spot,spot,on-demand,spot"
+	if strings.Join(affinities, ",") != expect {
+		t.Errorf("expect the affinities %s, got %s\n%s", expect, strings.Join(affinities, ","), out)
+	}
+}
+
+func TestInterruptAllSpotPods(t *testing.T) {
+	scenario := &Scenario{Steps: []Step{
+		{Action: ActionInterruptSpot},
+	}}
+
+	s, out := newTestSimulator(t)
+	workloads := []runtime.Object{newTestDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyAllInSpot)}
+	if _, err := s.Run(context.Background(), workloads, scenario); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+
+	// All the Pods are replaced while the workload is biased to on-demand.
+	web, _ := s.getWorkload("deploy/web", metav1.NamespaceDefault)
+	if onDemandNum, spotNum := countAffinities(web); onDemandNum != 3 || spotNum != 0 {
+		t.Errorf("expect 3 on-demand Pods, got on-demand %d spot %d\n%s", onDemandNum, spotNum, out)
+	}
+}
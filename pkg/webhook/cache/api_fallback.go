@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+// fetchedObjectExpiration is how long an object fetched from the API server is kept in the cache while its informer
+// doesn't have it. The informers receive a new object within seconds, the ones missing longer were deleted before
+// the informers saw them, and their delete events never come.
+const fetchedObjectExpiration = time.Minute
+
+// fetchMissingWorkloadObjects gets the owner objects of a new Pod which are not in the cache yet from the API server,
+// and seeds the cache with them. It returns true if any object has been added to the cache.
+func (wc *WebhookCache) fetchMissingWorkloadObjects(ctx context.Context, podSourceWorkloadType string,
+	podSourceWorkloadKey types.NamespacedName) bool {
+
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		return wc.fetchMissingReplicaSetObjects(ctx, podSourceWorkloadKey)
+	case "StatefulSet":
+		return wc.fetchMissingStatefulSet(ctx, podSourceWorkloadKey)
+	case "Job":
+		return wc.fetchMissingJob(ctx, podSourceWorkloadKey)
+	}
+	return false
+}
+
+// fetchMissingReplicaSetObjects fetches the ReplicaSet and its source Deployment if they are missing.
+func (wc *WebhookCache) fetchMissingReplicaSetObjects(ctx context.Context, replicaSetKey types.NamespacedName) bool {
+	fetched := false
+
+	shard := wc.shardFor(replicaSetKey.Namespace)
+	shard.mutex.Lock()
+	replicaSet, ok := shard.replicaSets[replicaSetKey]
+	shard.mutex.Unlock()
+
+	if !ok {
+		var err error
+		replicaSet, err = wc.kubeClient.AppsV1().ReplicaSets(replicaSetKey.Namespace).Get(ctx, replicaSetKey.Name, metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("Failed to get the missing ReplicaSet %v from API server: %v", replicaSetKey, err)
+			return false
+		}
+		wc.addReplicaSet(stripReplicaSet(replicaSet))
+		wc.markFetched(workloadReference{Type: "ReplicaSet", Key: replicaSetKey})
+		fetched = true
+		klog.V(3).Infof("Fetched the missing ReplicaSet %v from API server.", replicaSetKey)
+	}
+
+	deploymentKey := utils.GetReplicaSetSourceDeploymentKey(replicaSet)
+	if deploymentKey == nil {
+		return fetched
+	}
+
+	// The Deployment is in the same namespace, so in the same shard.
+	shard.mutex.Lock()
+	_, ok = shard.deployments[*deploymentKey]
+	shard.mutex.Unlock()
+
+	if !ok {
+		deployment, err := wc.kubeClient.AppsV1().Deployments(deploymentKey.Namespace).Get(ctx, deploymentKey.Name, metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("Failed to get the missing Deployment %v from API server: %v", *deploymentKey, err)
+			return fetched
+		}
+		wc.addDeployment(stripDeployment(deployment))
+		wc.markFetched(workloadReference{Type: "Deployment", Key: *deploymentKey})
+		fetched = true
+		klog.V(3).Infof("Fetched the missing Deployment %v from API server.", *deploymentKey)
+	}
+
+	return fetched
+}
+
+// fetchMissingStatefulSet fetches the StatefulSet if it's missing.
+func (wc *WebhookCache) fetchMissingStatefulSet(ctx context.Context, statefulSetKey types.NamespacedName) bool {
+	shard := wc.shardFor(statefulSetKey.Namespace)
+	shard.mutex.Lock()
+	_, ok := shard.statefulSets[statefulSetKey]
+	shard.mutex.Unlock()
+
+	if ok {
+		return false
+	}
+
+	statefulSet, err := wc.kubeClient.AppsV1().StatefulSets(statefulSetKey.Namespace).Get(ctx, statefulSetKey.Name, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Failed to get the missing StatefulSet %v from API server: %v", statefulSetKey, err)
+		return false
+	}
+	wc.addStatefulSet(stripStatefulSet(statefulSet))
+	wc.markFetched(workloadReference{Type: "StatefulSet", Key: statefulSetKey})
+	klog.V(3).Infof("Fetched the missing StatefulSet %v from API server.", statefulSetKey)
+
+	return true
+}
+
+// fetchMissingJob fetches the Job if it's missing.
+func (wc *WebhookCache) fetchMissingJob(ctx context.Context, jobKey types.NamespacedName) bool {
+	shard := wc.shardFor(jobKey.Namespace)
+	shard.mutex.Lock()
+	_, ok := shard.jobs[jobKey]
+	shard.mutex.Unlock()
+
+	if ok {
+		return false
+	}
+
+	job, err := wc.kubeClient.BatchV1().Jobs(jobKey.Namespace).Get(ctx, jobKey.Name, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Failed to get the missing Job %v from API server: %v", jobKey, err)
+		return false
+	}
+	wc.addJob(stripJob(job))
+	wc.markFetched(workloadReference{Type: "Job", Key: jobKey})
+	klog.V(3).Infof("Fetched the missing Job %v from API server.", jobKey)
+
+	return true
+}
+
+// markFetched records when the object was fetched from the API server, it's removed by expireFetchedObjects
+// if its informer never receives it.
+func (wc *WebhookCache) markFetched(reference workloadReference) {
+	shard := wc.shardFor(reference.Key.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	shard.fetchedObjects[reference] = wc.clock.Now()
+}
+
+// expireFetchedObjects removes the objects fetched from the API server which are still not in the listers
+// of their informers after the fetchedObjectExpiration. require mutex locked.
+func (wc *WebhookCache) expireFetchedObjects(shard *cacheShard) {
+	for reference, fetchedAt := range shard.fetchedObjects {
+		if !shard.hasObject(reference) || wc.isInLister(reference) {
+			// The object has been deleted, or the informer watches it now.
+			delete(shard.fetchedObjects, reference)
+			continue
+		}
+		if wc.clock.Since(fetchedAt) < fetchedObjectExpiration {
+			continue
+		}
+
+		klog.Warningf("Removed the %s %v fetched from API server %v ago, which the informer never received.",
+			reference.Type, reference.Key, wc.clock.Since(fetchedAt).Round(time.Second))
+		shard.deleteObject(reference)
+		delete(shard.fetchedObjects, reference)
+	}
+}
+
+// isInLister returns true if the object is in the lister of its informer.
+func (wc *WebhookCache) isInLister(reference workloadReference) bool {
+	var err error
+	switch reference.Type {
+	case "ReplicaSet":
+		_, err = wc.replicaSetInformer.Lister().ReplicaSets(reference.Key.Namespace).Get(reference.Key.Name)
+	case "Deployment":
+		_, err = wc.deploymentInformer.Lister().Deployments(reference.Key.Namespace).Get(reference.Key.Name)
+	case "StatefulSet":
+		_, err = wc.statefulSetInformer.Lister().StatefulSets(reference.Key.Namespace).Get(reference.Key.Name)
+	case "Job":
+		_, err = wc.jobInformer.Lister().Jobs(reference.Key.Namespace).Get(reference.Key.Name)
+	}
+	return err == nil
+}
+
+// hasObject returns true if the object is in the shard. require mutex locked.
+func (shard *cacheShard) hasObject(reference workloadReference) bool {
+	var ok bool
+	switch reference.Type {
+	case "ReplicaSet":
+		_, ok = shard.replicaSets[reference.Key]
+	case "Deployment":
+		_, ok = shard.deployments[reference.Key]
+	case "StatefulSet":
+		_, ok = shard.statefulSets[reference.Key]
+	case "Job":
+		_, ok = shard.jobs[reference.Key]
+	}
+	return ok
+}
+
+// deleteObject removes the object and its scheduling info from the shard, as its delete event does.
+// require mutex locked.
+func (shard *cacheShard) deleteObject(reference workloadReference) {
+	switch reference.Type {
+	case "ReplicaSet":
+		delete(shard.replicaSets, reference.Key)
+	case "Deployment":
+		delete(shard.deployments, reference.Key)
+		return
+	case "StatefulSet":
+		delete(shard.statefulSets, reference.Key)
+	case "Job":
+		delete(shard.jobs, reference.Key)
+	}
+	shard.clearWorkloadSchedulingInfo(reference.Type, reference.Key)
+}
@@ -0,0 +1,35 @@
+package apis
+
+import (
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+// DetermineNewPodAffinity decides the affinity of a new Pod of an enabled workload, from the target numbers
+// of the setting and the Pods which have been marked in the WorkloadSchedulingInfo. The Pods in the dry-run
+// mode are decided as if the placement recorded for the dry-run Pods was enforced.
+func DetermineNewPodAffinity(schedulingSetting *OptimizeSchedulingSetting, wsi *WorkloadSchedulingInfo,
+	dryRun bool) podaffinity.PodAffinitySettingName {
+
+	// Now, we know the target numbers for on-demand and spot Replicas,
+	// and we also know how many existing Pods have been marked as on-demand or spot.
+	// We simply need to make a straightforward judgment based on this information.
+	onDemandReplicaCount, spotReplicaCount := wsi.OnDemandReplicaCount, wsi.SpotReplicaCount
+	if dryRun {
+		onDemandReplicaCount += wsi.DryRunOnDemandReplicaCount
+		spotReplicaCount += wsi.DryRunSpotReplicaCount
+	}
+
+	// If the number of currently created Pods that have been marked as on-demand has not reached the target,
+	// then return pod_affinity.PodAffinityOnDemand directly.
+	if onDemandReplicaCount < schedulingSetting.TargetOnDemandNum {
+		return podaffinity.PodAffinityOnDemand
+	}
+
+	// If the number of on-demand replicas has been satisfied, under normal circumstances,
+	// the remaining Pods should all be assigned to spot.
+	if spotReplicaCount < schedulingSetting.TargetOnSpotNum {
+		return podaffinity.PodAffinitySpot
+	}
+
+	return podaffinity.PodAffinityUnset
+}
@@ -2,6 +2,8 @@ package apis
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+
+	"vacant.sh/vmanager/pkg/config"
 )
 
 type DeploymentInfo struct {
@@ -9,9 +11,9 @@ type DeploymentInfo struct {
 	*OptimizeSchedulingSetting
 }
 
-func NewDeploymentInfo(deployment *appsv1.Deployment) *DeploymentInfo {
+func NewDeploymentInfo(deployment *appsv1.Deployment, defaultStrategies *config.DefaultStrategiesConfiguration) *DeploymentInfo {
 	return &DeploymentInfo{
 		Deployment:                deployment,
-		OptimizeSchedulingSetting: NewOptimizeSchedulingSettingFromLabels(deployment.Labels, int(*deployment.Spec.Replicas), "Deployment"),
+		OptimizeSchedulingSetting: NewOptimizeSchedulingSetting(deployment.Labels, deployment.Annotations, int(*deployment.Spec.Replicas), "Deployment", defaultStrategies),
 	}
 }
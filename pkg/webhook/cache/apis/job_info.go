@@ -0,0 +1,32 @@
+package apis
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+
+	"vacant.sh/vmanager/pkg/config"
+)
+
+type JobInfo struct {
+	Job *batchv1.Job
+	*OptimizeSchedulingSetting
+}
+
+func NewJobInfo(job *batchv1.Job, defaultStrategies *config.DefaultStrategiesConfiguration) *JobInfo {
+	return &JobInfo{
+		Job:                       job,
+		OptimizeSchedulingSetting: NewOptimizeSchedulingSetting(job.Labels, job.Annotations, GetJobReplicas(job), "Job", defaultStrategies),
+	}
+}
+
+// GetJobReplicas returns the maximum number of the Pods of the Job running at the same time,
+// which is the parallelism, limited by the completions if it's set.
+func GetJobReplicas(job *batchv1.Job) int {
+	replicas := 1
+	if job.Spec.Parallelism != nil {
+		replicas = int(*job.Spec.Parallelism)
+	}
+	if job.Spec.Completions != nil && int(*job.Spec.Completions) < replicas {
+		replicas = int(*job.Spec.Completions)
+	}
+	return replicas
+}
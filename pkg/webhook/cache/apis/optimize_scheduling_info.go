@@ -5,6 +5,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/labels"
 
+	"vacant.sh/vmanager/pkg/config"
 	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
 )
 
@@ -15,20 +16,28 @@ type OptimizeSchedulingSetting struct {
 	Strategy string
 	// CustomOnDemand, target: optimize_scheduling.OptimizeSchedulingStrategyCustomOnDemandKey
 	CustomOnDemand int
+	// SpotFallback, target: optimize_scheduling.OptimizeSchedulingSpotFallbackKey
+	SpotFallback bool
+	// DryRun, target: optimize_scheduling.OptimizeSchedulingDryRunKey
+	DryRun bool
 
 	TargetOnDemandNum int
 	TargetOnSpotNum   int
 }
 
-func NewOptimizeSchedulingSettingFromLabels(labels labels.Set, replicaNum int, workloadType string) *OptimizeSchedulingSetting {
+// NewOptimizeSchedulingSetting builds the setting from the labels and annotations of the workload,
+// the annotations take precedence over the labels.
+func NewOptimizeSchedulingSetting(workloadLabels, workloadAnnotations map[string]string, replicaNum int, workloadType string,
+	defaultStrategies *config.DefaultStrategiesConfiguration) *OptimizeSchedulingSetting {
 	osi := &OptimizeSchedulingSetting{}
 
-	if labels == nil || replicaNum == 0 {
+	configuration := labels.Set(optimizescheduling.GetOptimizeSchedulingConfiguration(workloadLabels, workloadAnnotations))
+	if len(configuration) == 0 || replicaNum == 0 {
 		return osi
 	}
 
 	// Get if enable the optimized scheduling.
-	enable := labels.Get(optimizescheduling.OptimizeSchedulingKey)
+	enable := configuration.Get(optimizescheduling.OptimizeSchedulingKey)
 	if enable == "true" {
 		osi.Enable = true
 	} else {
@@ -36,24 +45,20 @@ func NewOptimizeSchedulingSettingFromLabels(labels labels.Set, replicaNum int, w
 	}
 
 	// Get the optimize scheduling strategy.
-	osi.Strategy = labels.Get(optimizescheduling.OptimizeSchedulingStrategyKey)
+	osi.Strategy = configuration.Get(optimizescheduling.OptimizeSchedulingStrategyKey)
 	if osi.Strategy == "" {
 		// Set to default strategy.
-		if workloadType == "Deployment" {
-			osi.Strategy = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
-		}
-
-		if workloadType == "StatefulSet" {
-			if replicaNum == 1 {
-				osi.Strategy = optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand
-			} else {
-				osi.Strategy = optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand
-			}
-		}
+		osi.Strategy = defaultStrategies.StrategyFor(workloadType, replicaNum)
 	}
 
+	// Get if the unschedulable spot Pods should fall back to on-demand.
+	osi.SpotFallback = configuration.Get(optimizescheduling.OptimizeSchedulingSpotFallbackKey) == "true"
+
+	// Get if the placement is only recorded instead of enforced.
+	osi.DryRun = configuration.Get(optimizescheduling.OptimizeSchedulingDryRunKey) == "true"
+
 	// Get the custom on demand replica number.
-	customOnDemandValue := labels.Get(optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey)
+	customOnDemandValue := configuration.Get(optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey)
 	osi.CustomOnDemand, _ = strconv.Atoi(customOnDemandValue)
 
 	// Calculate the TargetOnDemandNum and TargetOnSpotNum by strategy.
@@ -66,7 +71,9 @@ func NewOptimizeSchedulingSettingFromLabels(labels labels.Set, replicaNum int, w
 		osi.TargetOnDemandNum = (replicaNum / 2) + 1
 		osi.TargetOnSpotNum = replicaNum - osi.TargetOnDemandNum
 	case optimizescheduling.OptimizeSchedulingStrategyCustom:
-		osi.TargetOnDemandNum, osi.TargetOnSpotNum = osi.CustomOnDemand, replicaNum-osi.CustomOnDemand
+		// The custom count may exceed the replicas after scaling down, all the replicas are on-demand then.
+		customOnDemand := min(max(osi.CustomOnDemand, 0), replicaNum)
+		osi.TargetOnDemandNum, osi.TargetOnSpotNum = customOnDemand, replicaNum-customOnDemand
 	}
 
 	return osi
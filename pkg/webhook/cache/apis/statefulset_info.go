@@ -1,15 +1,19 @@
 package apis
 
-import appsv1 "k8s.io/api/apps/v1"
+import (
+	appsv1 "k8s.io/api/apps/v1"
+
+	"vacant.sh/vmanager/pkg/config"
+)
 
 type StatefulSetInfo struct {
 	StatefulSet *appsv1.StatefulSet
 	*OptimizeSchedulingSetting
 }
 
-func NewStatefulSetInfo(statefulSet *appsv1.StatefulSet) *StatefulSetInfo {
+func NewStatefulSetInfo(statefulSet *appsv1.StatefulSet, defaultStrategies *config.DefaultStrategiesConfiguration) *StatefulSetInfo {
 	return &StatefulSetInfo{
 		StatefulSet:               statefulSet,
-		OptimizeSchedulingSetting: NewOptimizeSchedulingSettingFromLabels(statefulSet.Labels, int(*statefulSet.Spec.Replicas), "StatefulSet"),
+		OptimizeSchedulingSetting: NewOptimizeSchedulingSetting(statefulSet.Labels, statefulSet.Annotations, int(*statefulSet.Spec.Replicas), "StatefulSet", defaultStrategies),
 	}
 }
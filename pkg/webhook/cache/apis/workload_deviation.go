@@ -0,0 +1,45 @@
+package apis
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+// WorkloadDeviation is the difference between the target and the observed placement of the Pods of a workload,
+// such as a Deployment whose strategy has been changed while its Pods stay where they are.
+type WorkloadDeviation struct {
+	WorkloadType string
+	WorkloadKey  types.NamespacedName
+
+	TargetOnDemandNum   int
+	TargetOnSpotNum     int
+	ObservedOnDemandNum int
+	ObservedOnSpotNum   int
+}
+
+// OnDemandDelta is the number of Pods which are missing on on-demand, it's negative if there are more
+// on-demand Pods than the target.
+func (d *WorkloadDeviation) OnDemandDelta() int {
+	return d.TargetOnDemandNum - d.ObservedOnDemandNum
+}
+
+// SpotDelta is the number of Pods which are missing on spot, it's negative if there are more
+// spot Pods than the target.
+func (d *WorkloadDeviation) SpotDelta() int {
+	return d.TargetOnSpotNum - d.ObservedOnSpotNum
+}
+
+// Satisfied returns true if the observed placement meets the target.
+func (d *WorkloadDeviation) Satisfied() bool {
+	return d.OnDemandDelta() == 0 && d.SpotDelta() == 0
+}
+
+// Condition returns OptimizeSchedulingConditionDegraded if there are fewer on-demand Pods than the target,
+// the missing spot Pods only cost more while the missing on-demand Pods risk the availability.
+func (d *WorkloadDeviation) Condition() string {
+	if d.ObservedOnDemandNum < d.TargetOnDemandNum {
+		return optimizescheduling.OptimizeSchedulingConditionDegraded
+	}
+	return optimizescheduling.OptimizeSchedulingConditionSatisfied
+}
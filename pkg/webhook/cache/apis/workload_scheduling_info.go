@@ -7,16 +7,85 @@ import (
 )
 
 // WorkloadSchedulingInfo stores whether the current pods of the workload have node affinity settings,
-// and what those settings are.
+// and what those settings are. Only the live pods are counted, the terminal or terminating pods don't
+// occupy the on-demand or spot replicas since they will be replaced.
+// The placement recorded for the Pods in the dry-run mode is counted separately, since those Pods are not placed.
 type WorkloadSchedulingInfo struct {
 	OnDemandReplicaCount int
 	SpotReplicaCount     int
 
+	DryRunOnDemandReplicaCount int
+	DryRunSpotReplicaCount     int
+
 	Pods map[types.NamespacedName]podaffinity.PodAffinitySettingName
+	// DryRunPods are the placement recorded for the Pods in the dry-run mode.
+	DryRunPods map[types.NamespacedName]podaffinity.PodAffinitySettingName
 }
 
 func NewWorkloadSchedulingInfo() *WorkloadSchedulingInfo {
 	return &WorkloadSchedulingInfo{
-		Pods: make(map[types.NamespacedName]podaffinity.PodAffinitySettingName),
+		Pods:       make(map[types.NamespacedName]podaffinity.PodAffinitySettingName),
+		DryRunPods: make(map[types.NamespacedName]podaffinity.PodAffinitySettingName),
+	}
+}
+
+// UpdatePod records the Pod if it's live, otherwise removes it from the records.
+func (wsi *WorkloadSchedulingInfo) UpdatePod(podKey types.NamespacedName, setting, dryRunSetting podaffinity.PodAffinitySettingName,
+	live bool) {
+
+	if !live {
+		wsi.RemovePod(podKey)
+		return
+	}
+	wsi.AddPod(podKey, setting, dryRunSetting)
+}
+
+// AddPod records the affinity setting of the Pod and the one recorded in the dry-run mode, the Pod recorded
+// before is replaced.
+func (wsi *WorkloadSchedulingInfo) AddPod(podKey types.NamespacedName, setting, dryRunSetting podaffinity.PodAffinitySettingName) {
+	wsi.RemovePod(podKey)
+
+	wsi.Pods[podKey] = setting
+	switch setting {
+	case podaffinity.PodAffinityOnDemand:
+		wsi.OnDemandReplicaCount++
+	case podaffinity.PodAffinitySpot:
+		wsi.SpotReplicaCount++
+	}
+
+	if dryRunSetting == podaffinity.PodAffinityUnset {
+		return
+	}
+	wsi.DryRunPods[podKey] = dryRunSetting
+	switch dryRunSetting {
+	case podaffinity.PodAffinityOnDemand:
+		wsi.DryRunOnDemandReplicaCount++
+	case podaffinity.PodAffinitySpot:
+		wsi.DryRunSpotReplicaCount++
+	}
+}
+
+// RemovePod removes the Pod from the records, it returns false if the Pod is not recorded.
+func (wsi *WorkloadSchedulingInfo) RemovePod(podKey types.NamespacedName) bool {
+	setting, ok := wsi.Pods[podKey]
+	if !ok {
+		return false
+	}
+
+	switch setting {
+	case podaffinity.PodAffinityOnDemand:
+		wsi.OnDemandReplicaCount--
+	case podaffinity.PodAffinitySpot:
+		wsi.SpotReplicaCount--
+	}
+	delete(wsi.Pods, podKey)
+
+	switch wsi.DryRunPods[podKey] {
+	case podaffinity.PodAffinityOnDemand:
+		wsi.DryRunOnDemandReplicaCount--
+	case podaffinity.PodAffinitySpot:
+		wsi.DryRunSpotReplicaCount--
 	}
+	delete(wsi.DryRunPods, podKey)
+	return true
 }
@@ -0,0 +1,18 @@
+package apis
+
+import (
+	"time"
+)
+
+// WorkloadStatus is the placement of the Pods of a Deployment or StatefulSet, reported to the workload owners.
+type WorkloadStatus struct {
+	WorkloadDeviation
+
+	// Enable is false if the workload doesn't enable the optimize scheduling, the status isn't reported then.
+	Enable   bool
+	Strategy string
+	// LastDecisionTime is the zero time if no Pod of the workload has been determined since the webhook started.
+	LastDecisionTime time.Time
+	// Annotations are the current annotations of the workload.
+	Annotations map[string]string
+}
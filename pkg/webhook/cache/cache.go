@@ -1,64 +1,101 @@
 package cache
 
 import (
-	"sync"
+	"reflect"
 
 	appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/types"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	informerappsv1 "k8s.io/client-go/informers/apps/v1"
+	informerbatchv1 "k8s.io/client-go/informers/batch/v1"
 	informercorev1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 
-	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/config"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
 )
 
 // Check if the Cache implements necessary func.
 var _ Interface = &WebhookCache{}
 
 type WebhookCache struct {
-	mutex sync.Mutex
+	config *config.Holder
 
 	kubeClient      kubernetes.Interface
 	informerFactory informers.SharedInformerFactory
 
-	replicaSetInformer informerappsv1.ReplicaSetInformer
-	replicaSets        map[types.NamespacedName]*appsv1.ReplicaSet
-
-	deploymentInformer informerappsv1.DeploymentInformer
-	deployments        map[types.NamespacedName]*apis.DeploymentInfo
+	// namespaceInformer is from a separate factory, since the informerFactory filters the objects by the namespace.
+	namespaceInformerFactory informers.SharedInformerFactory
+	namespaceInformer        informercorev1.NamespaceInformer
 
+	replicaSetInformer  informerappsv1.ReplicaSetInformer
+	deploymentInformer  informerappsv1.DeploymentInformer
 	statefulSetInformer informerappsv1.StatefulSetInformer
-	statefulSets        map[types.NamespacedName]*apis.StatefulSetInfo
+	jobInformer         informerbatchv1.JobInformer
+	podInformer         informercorev1.PodInformer
+
+	// eventHandlers are the handlers registered to the informers, by the type of their objects.
+	eventHandlers map[reflect.Type]cache.ResourceEventHandler
+
+	// shards hold the cached objects, sharded by the namespace, each shard has its own mutex.
+	shards []*cacheShard
 
-	podInformer                       informercorev1.PodInformer
-	replicaSetWorkloadSchedulingInfo  map[types.NamespacedName]*apis.WorkloadSchedulingInfo
-	statefulSetWorkloadSchedulingInfo map[types.NamespacedName]*apis.WorkloadSchedulingInfo
+	// ownerResolver resolves the settings of the generic workloads, which are not watched by the informers.
+	ownerResolver owner.Resolver
+
+	// clock tells when the on-demand bias expires and when the decisions and strategy changes happen.
+	clock clock.Clock
 }
 
-func NewWebhookCache(kubeConfig *rest.Config) (Interface, error) {
+func NewWebhookCache(kubeConfig *rest.Config, configHolder *config.Holder) (Interface, error) {
 	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
 		return nil, err
 	}
+	ownerResolver, err := owner.NewResolver(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	wc := &WebhookCache{
-		kubeClient:      kubeClient,
-		informerFactory: informers.NewSharedInformerFactory(kubeClient, 0),
+	return NewWebhookCacheForClients(kubeClient, ownerResolver, configHolder, clock.RealClock{})
+}
 
-		replicaSets:  map[types.NamespacedName]*appsv1.ReplicaSet{},
-		deployments:  map[types.NamespacedName]*apis.DeploymentInfo{},
-		statefulSets: map[types.NamespacedName]*apis.StatefulSetInfo{},
+// NewWebhookCacheForClients builds the cache from the clients and the clock, so that it can run without a cluster,
+// such as with the fake clientset and the fake clock in the simulator.
+func NewWebhookCacheForClients(kubeClient kubernetes.Interface, ownerResolver owner.Resolver,
+	configHolder *config.Holder, clock clock.Clock) (*WebhookCache, error) {
 
-		replicaSetWorkloadSchedulingInfo:  map[types.NamespacedName]*apis.WorkloadSchedulingInfo{},
-		statefulSetWorkloadSchedulingInfo: map[types.NamespacedName]*apis.WorkloadSchedulingInfo{},
+	wc := &WebhookCache{
+		config: configHolder,
+
+		kubeClient: kubeClient,
+		// The objects of the excluded namespaces are not listed at all to save the memory, the other namespaces
+		// which are not handled are filtered by the event handlers. The objects are stripped before stored.
+		informerFactory: informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+			informers.WithTweakListOptions(excludeNamespaces(configHolder.Get().ExcludedNamespaces)),
+			informers.WithTransform(transformObject)),
+		namespaceInformerFactory: informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+			informers.WithTransform(transformObject)),
+
+		shards:        newCacheShards(shardNum),
+		eventHandlers: map[reflect.Type]cache.ResourceEventHandler{},
+
+		ownerResolver: ownerResolver,
+		clock:         clock,
 	}
 
+	// The default strategies may be changed by reloading the configuration.
+	configHolder.AddListener(wc.refreshOptimizeSchedulingSettings)
+
 	wc.deploymentInformer = wc.informerFactory.Apps().V1().Deployments()
-	_, err = wc.deploymentInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	err := wc.addEventHandler(wc.deploymentInformer.Informer(), &appsv1.Deployment{}, cache.ResourceEventHandlerFuncs{
 		AddFunc:    wc.addDeployment,
 		UpdateFunc: wc.updateDeployment,
 		DeleteFunc: wc.deleteDeployment,
@@ -68,7 +105,7 @@ func NewWebhookCache(kubeConfig *rest.Config) (Interface, error) {
 	}
 
 	wc.statefulSetInformer = wc.informerFactory.Apps().V1().StatefulSets()
-	_, err = wc.statefulSetInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	err = wc.addEventHandler(wc.statefulSetInformer.Informer(), &appsv1.StatefulSet{}, cache.ResourceEventHandlerFuncs{
 		AddFunc:    wc.addStatefulSet,
 		UpdateFunc: wc.updateStatefulSet,
 		DeleteFunc: wc.deleteStatefulSet,
@@ -77,25 +114,73 @@ func NewWebhookCache(kubeConfig *rest.Config) (Interface, error) {
 		return nil, err
 	}
 
+	wc.jobInformer = wc.informerFactory.Batch().V1().Jobs()
+	err = wc.addEventHandler(wc.jobInformer.Informer(), &batchv1.Job{}, cache.ResourceEventHandlerFuncs{
+		AddFunc:    wc.addJob,
+		UpdateFunc: wc.updateJob,
+		DeleteFunc: wc.deleteJob,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wc.namespaceInformer = wc.namespaceInformerFactory.Core().V1().Namespaces()
+	wc.namespaceInformer.Informer()
+
 	wc.podInformer = wc.informerFactory.Core().V1().Pods()
-	_, err = wc.podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	err = wc.addEventHandler(wc.podInformer.Informer(), &corev1.Pod{}, cache.ResourceEventHandlerFuncs{
 		AddFunc:    wc.addPod,
 		UpdateFunc: wc.updatePod,
 		DeleteFunc: wc.deletePod,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	wc.replicaSetInformer = wc.informerFactory.Apps().V1().ReplicaSets()
-	_, err = wc.replicaSetInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	err = wc.addEventHandler(wc.replicaSetInformer.Informer(), &appsv1.ReplicaSet{}, cache.ResourceEventHandlerFuncs{
 		AddFunc:    wc.addReplicaSet,
 		UpdateFunc: wc.updateReplicaSet,
 		DeleteFunc: wc.deleteReplicaSet,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	return wc, nil
 }
 
+// addEventHandler registers the handler of the objects to the informer, the handler is also kept by the type
+// of the objects, so that the events can be delivered without running the informers, such as in the tests.
+func (wc *WebhookCache) addEventHandler(informer cache.SharedIndexInformer, obj runtime.Object,
+	handler cache.ResourceEventHandlerFuncs) error {
+
+	eventHandler := wc.newEventHandler(handler)
+	if _, err := informer.AddEventHandler(eventHandler); err != nil {
+		return err
+	}
+	wc.eventHandlers[reflect.TypeOf(obj)] = eventHandler
+	return nil
+}
+
+// newEventHandler skips the events of the objects in the namespaces which are not handled.
+func (wc *WebhookCache) newEventHandler(handler cache.ResourceEventHandlerFuncs) cache.ResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: wc.isInManagedNamespace,
+		Handler:    handler,
+	}
+}
+
 func (wc *WebhookCache) Run(stopCh <-chan struct{}) {
-	// Start the informerFactory, wait for cache sync.
+	// Start the informerFactory, wait for cache sync. The namespaces are synced first, so that the objects
+	// are filtered by the labels of their namespaces.
+	wc.namespaceInformerFactory.Start(stopCh)
+	for informerType, ok := range wc.namespaceInformerFactory.WaitForCacheSync(stopCh) {
+		if !ok {
+			klog.Errorf("Cache failed to sync: %v", informerType)
+		}
+	}
+
 	wc.informerFactory.Start(stopCh)
 
 	for informerType, ok := range wc.informerFactory.WaitForCacheSync(stopCh) {
@@ -104,5 +189,8 @@ func (wc *WebhookCache) Run(stopCh <-chan struct{}) {
 		}
 	}
 
+	// Rebuild the scheduling info periodically to self-heal from the drift.
+	go wait.Until(wc.resyncWorkloadSchedulingInfo, wc.config.Get().CacheResyncPeriod.Duration, stopCh)
+
 	klog.V(2).Info("WebhookCache start to run.")
 }
@@ -1,12 +1,23 @@
 package cache
 
 import (
+	"context"
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
 )
 
 type Interface interface {
 	Run(stopCh <-chan struct{})
-	DetermineNewPodAffinityPreference(pod *corev1.Pod) podaffinity.PodAffinitySettingName
+	DetermineNewPodAffinityPreference(ctx context.Context, pod *corev1.Pod) (podaffinity.PodAffinitySettingName, bool)
+	GetPodOptimizeSchedulingSetting(ctx context.Context, pod *corev1.Pod) *apis.OptimizeSchedulingSetting
+	BiasToOnDemand(pod *corev1.Pod, duration time.Duration)
+	ListStrategyChangeDeviations() []*apis.WorkloadDeviation
+	ForgetStrategyChange(workloadType string, workloadKey types.NamespacedName)
+	ListWorkloadStatuses() []*apis.WorkloadStatus
+	IsNamespaceManaged(namespace string) bool
 }
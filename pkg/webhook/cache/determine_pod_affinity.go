@@ -1,12 +1,18 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 
 	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
 	"vacant.sh/vmanager/pkg/webhook/cache/apis"
@@ -18,141 +24,298 @@ import (
 // and the strategy requires a majority-in-on-demand, there might be a situation where all three pods are on-demand.
 
 // DetermineNewPodAffinityPreference determines what NodeAffinity should be applied to a new Pod
-// to meet our requirements, based on data collected from the Cache.
-func (wc *WebhookCache) DetermineNewPodAffinityPreference(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
+// to meet our requirements, based on data collected from the Cache. It also returns true if the Pod is in the dry-run
+// mode, whose decision is only recorded.
+// The lookups are retried until the deadline of the ctx minus the backoff.deadlineMargin, then it falls back to unset,
+// so that the admission request still has time to respond.
+func (wc *WebhookCache) DetermineNewPodAffinityPreference(ctx context.Context, pod *corev1.Pod) (podaffinity.PodAffinitySettingName, bool) {
 	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
 
 	if podSourceWorkloadType == "" {
 		// Return if we cant get the workload type.
 		klog.V(3).Infof("Pod %s/%s has no workload type", pod.Namespace, pod.Name)
-		return podaffinity.PodAffinityUnset
+		return podaffinity.PodAffinityUnset, false
 	}
 
 	if podSourceWorkloadKey == nil {
 		// Return if we cant get the source workload key.
 		klog.V(3).Infof("Pod source workload key is nil for Pod %s/%s ", pod.Namespace, pod.Name)
-		return podaffinity.PodAffinityUnset
+		return podaffinity.PodAffinityUnset, false
 	}
 
 	// In some cases, we need to attempt a retry and wait for the cache to synchronize the deployment.
 	// For example, when a deployment has just been created, the pods might have already been generated,
 	// but the replica set has not yet been synchronized to our cache.
 	var result podaffinity.PodAffinitySettingName
+	var dryRun bool
+
+	backoff := wc.config.Get().Backoff
+	ctx, cancel := withLookupDeadline(ctx, backoff.Timeout.Duration, backoff.DeadlineMargin.Duration)
+	defer cancel()
 
-	err := wait.ExponentialBackoff(wait.Backoff{
-		Duration: 100 * time.Millisecond,
-		Factor:   1.0,
-		Jitter:   0.1,
-		Steps:    5,
-	}, func() (bool, error) {
+	err := wait.ExponentialBackoffWithContext(ctx, wait.Backoff{
+		Duration: backoff.Duration.Duration,
+		Factor:   backoff.Factor,
+		Jitter:   ptr.Deref(backoff.Jitter, 0),
+		Steps:    backoff.Steps,
+	}, func(ctx context.Context) (bool, error) {
 		var needRetry bool
 
-		switch podSourceWorkloadType {
-		case "ReplicaSet":
-			result, needRetry = wc.determineNewPodAffinityPreferenceForReplicaSet(*podSourceWorkloadKey)
-		case "StatefulSet":
-			result, needRetry = wc.determineNewPodAffinityPreferenceForStatefulSet(*podSourceWorkloadKey)
+		result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceFor(ctx, pod, podSourceWorkloadType, *podSourceWorkloadKey)
+		// Instead of waiting for the informers, get the missing objects from the API server directly.
+		if needRetry && wc.fetchMissingWorkloadObjects(ctx, podSourceWorkloadType, *podSourceWorkloadKey) {
+			result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceFor(ctx, pod, podSourceWorkloadType, *podSourceWorkloadKey)
 		}
 
 		return !needRetry, nil
 	})
 
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		klog.Errorf("Retry determine new pod affinity for Pod %s/%s stopped before the deadline: %v, return unset.",
+			pod.Namespace, pod.Name, err)
+		return podaffinity.PodAffinityUnset, false
+	}
 	if err != nil {
 		klog.Errorf("Retry determine new pod affinity for Pod %s/%s failed: %v, return unset.", pod.Namespace, pod.Name, err)
-		return podaffinity.PodAffinityUnset
+		return podaffinity.PodAffinityUnset, false
+	}
+
+	if result != podaffinity.PodAffinityUnset {
+		shard := wc.shardFor(podSourceWorkloadKey.Namespace)
+		shard.mutex.Lock()
+		shard.lastDecisionTimes[workloadReference{Type: podSourceWorkloadType, Key: *podSourceWorkloadKey}] = wc.clock.Now()
+		shard.mutex.Unlock()
 	}
-	return result
+	return result, dryRun
 }
 
-func (wc *WebhookCache) determineNewPodAffinityPreferenceForReplicaSet(replicaSetKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool) {
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+// determineNewPodAffinityPreferenceFor returns the affinity of the Pod, whether the Pod is in the dry-run mode,
+// and whether the lookup needs to be retried.
+func (wc *WebhookCache) determineNewPodAffinityPreferenceFor(ctx context.Context, pod *corev1.Pod,
+	podSourceWorkloadType string, podSourceWorkloadKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool, bool) {
+
+	var result podaffinity.PodAffinitySettingName
+	var dryRun, needRetry bool
+
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		// The ReplicaSet may be controlled by a workload other than Deployment, such as an Argo Rollout.
+		if ownerRef := wc.getReplicaSetSourceGenericOwner(podSourceWorkloadKey); ownerRef != nil {
+			result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceForGenericOwner(ctx, *ownerRef,
+				podSourceWorkloadType, podSourceWorkloadKey)
+		} else {
+			result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceForReplicaSet(podSourceWorkloadKey)
+		}
+	case "StatefulSet":
+		result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceForStatefulSet(podSourceWorkloadKey)
+	case "Job":
+		result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceForJob(podSourceWorkloadKey)
+	default:
+		// The Pod is controlled by a generic workload directly, such as an OpenKruise CloneSet.
+		controllerRef := metav1.GetControllerOf(pod)
+		if controllerRef == nil {
+			return podaffinity.PodAffinityUnset, false, false
+		}
+		result, dryRun, needRetry = wc.determineNewPodAffinityPreferenceForGenericOwner(ctx, *controllerRef,
+			podSourceWorkloadType, podSourceWorkloadKey)
+	}
+
+	// The spot nodes are not available for the workload recently, use on-demand instead.
+	if result == podaffinity.PodAffinitySpot {
+		shard := wc.shardFor(podSourceWorkloadKey.Namespace)
+		shard.mutex.Lock()
+		biased := shard.isBiasedToOnDemand(podSourceWorkloadType, podSourceWorkloadKey, wc.clock.Now())
+		shard.mutex.Unlock()
+
+		if biased {
+			klog.V(3).Infof("%s %v is biased to on-demand, return on-demand instead of spot.",
+				podSourceWorkloadType, podSourceWorkloadKey)
+			return podaffinity.PodAffinityOnDemand, dryRun, needRetry
+		}
+	}
+	return result, dryRun, needRetry
+}
+
+// withLookupDeadline limits the ctx to the timeout, and leaves the margin before the deadline of the ctx.
+func withLookupDeadline(ctx context.Context, timeout, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Add(-margin).Before(deadline) {
+		deadline = ctxDeadline.Add(-margin)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+func (wc *WebhookCache) determineNewPodAffinityPreferenceForReplicaSet(replicaSetKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool, bool) {
+	shard := wc.shardFor(replicaSetKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
 	// First, we need to obtain the cached ReplicaSet, and through it, acquire the associated DeploymentKey.
-	replicaSet, ok := wc.replicaSets[replicaSetKey]
+	replicaSet, ok := shard.replicaSets[replicaSetKey]
 	if !ok {
 		klog.V(3).Infof("Cant find ReplicaSet in cache by key %v, wait for cache sync.", replicaSetKey)
-		return "", true
+		return "", false, true
 	}
 	deploymentKey := utils.GetReplicaSetSourceDeploymentKey(replicaSet)
-	if deploymentKey == nil {
-		// The ReplicaSet have no parent Deployment resource.
+	if deploymentKey == nil && metav1.GetControllerOf(replicaSet) != nil {
+		// The ReplicaSet is controlled by a workload which is not supported.
 		klog.V(3).Infof("Cant find ReplicaSet %v source deployment.", replicaSetKey)
-		return podaffinity.PodAffinityUnset, false
+		return podaffinity.PodAffinityUnset, false, false
 	}
 
-	// Then, we need to obtain the Deployment associated with the ReplicaSet,
-	// as well as the SchedulingInfo object associated with the ReplicaSet.
-	deploymentInfo, ok := wc.deployments[*deploymentKey]
-	if !ok {
-		klog.Infof("Cant find Deployment %v in cache, ReplicaSet key %v, wait for cache sync.", *deploymentKey, replicaSetKey)
-		return "", true
+	// Then, we need to obtain the OptimizeSchedulingSetting from the Deployment associated with the ReplicaSet,
+	// or from the ReplicaSet itself if it's standalone, as well as the SchedulingInfo object associated with the ReplicaSet.
+	var schedulingSetting *apis.OptimizeSchedulingSetting
+	if deploymentKey != nil {
+		deploymentInfo, ok := shard.deployments[*deploymentKey]
+		if !ok {
+			klog.Infof("Cant find Deployment %v in cache, ReplicaSet key %v, wait for cache sync.", *deploymentKey, replicaSetKey)
+			return "", false, true
+		}
+		schedulingSetting = deploymentInfo.OptimizeSchedulingSetting
+	} else {
+		schedulingSetting = wc.getStandaloneReplicaSetSetting(replicaSet)
 	}
-	replicaSetSchedulingInfo, ok := wc.replicaSetWorkloadSchedulingInfo[replicaSetKey]
+	replicaSetSchedulingInfo, ok := shard.replicaSetWorkloadSchedulingInfo[replicaSetKey]
 	if !ok {
 		// This should never happen.
 		klog.Errorf("Cant find ReplicaSet %v scheduling info in cache.", replicaSetKey)
-		return podaffinity.PodAffinityUnset, false
+		return podaffinity.PodAffinityUnset, false, false
 	}
 
 	// Finally, we have collected all the necessary information required to determine the Affinity.
-	return wc.determineNewPodAffinityPreference(deploymentInfo.OptimizeSchedulingSetting, replicaSetSchedulingInfo), false
+	result, dryRun := wc.determineNewPodAffinityPreference(schedulingSetting, replicaSetSchedulingInfo, replicaSetKey.Namespace)
+	return result, dryRun, false
+}
+
+// getStandaloneReplicaSetSetting builds the OptimizeSchedulingSetting from the metadata of a ReplicaSet
+// which has no parent, it's built on demand so that it always follows the current default strategies.
+func (wc *WebhookCache) getStandaloneReplicaSetSetting(replicaSet *appsv1.ReplicaSet) *apis.OptimizeSchedulingSetting {
+	replicas := 1
+	if replicaSet.Spec.Replicas != nil {
+		replicas = int(*replicaSet.Spec.Replicas)
+	}
+	return apis.NewOptimizeSchedulingSetting(replicaSet.Labels, replicaSet.Annotations, replicas, "ReplicaSet",
+		&wc.config.Get().DefaultStrategies)
 }
 
-func (wc *WebhookCache) determineNewPodAffinityPreferenceForStatefulSet(statefulSetKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool) {
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+func (wc *WebhookCache) determineNewPodAffinityPreferenceForStatefulSet(statefulSetKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool, bool) {
+	shard := wc.shardFor(statefulSetKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	statefulSetInfo, ok := wc.statefulSets[statefulSetKey]
+	statefulSetInfo, ok := shard.statefulSets[statefulSetKey]
 	if !ok {
 		klog.Errorf("Cant find StatefulSet %v in cache, wait for cache sync.", statefulSetKey)
-		return "", true
+		return "", false, true
 	}
 
-	statefulSetSchedulingInfo, ok := wc.statefulSetWorkloadSchedulingInfo[statefulSetKey]
+	statefulSetSchedulingInfo, ok := shard.statefulSetWorkloadSchedulingInfo[statefulSetKey]
 	if !ok {
 		// This should never happen.
 		klog.Errorf("Cant find StatefulSet %v scheduling info in cache.", statefulSetKey)
-		return podaffinity.PodAffinityUnset, false
+		return podaffinity.PodAffinityUnset, false, false
 	}
 
-	return wc.determineNewPodAffinityPreference(statefulSetInfo.OptimizeSchedulingSetting, statefulSetSchedulingInfo), false
+	result, dryRun := wc.determineNewPodAffinityPreference(statefulSetInfo.OptimizeSchedulingSetting, statefulSetSchedulingInfo,
+		statefulSetKey.Namespace)
+	return result, dryRun, false
 }
 
-// require mutex locked.
+func (wc *WebhookCache) determineNewPodAffinityPreferenceForJob(jobKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool, bool) {
+	shard := wc.shardFor(jobKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	jobInfo, ok := shard.jobs[jobKey]
+	if !ok {
+		klog.V(3).Infof("Cant find Job %v in cache, wait for cache sync.", jobKey)
+		return "", false, true
+	}
+
+	jobSchedulingInfo, ok := shard.jobWorkloadSchedulingInfo[jobKey]
+	if !ok {
+		// This should never happen.
+		klog.Errorf("Cant find Job %v scheduling info in cache.", jobKey)
+		return podaffinity.PodAffinityUnset, false, false
+	}
+
+	result, dryRun := wc.determineNewPodAffinityPreference(jobInfo.OptimizeSchedulingSetting, jobSchedulingInfo, jobKey.Namespace)
+	return result, dryRun, false
+}
+
+// getReplicaSetSourceGenericOwner returns the controller of the cached ReplicaSet if it's not a Deployment.
+func (wc *WebhookCache) getReplicaSetSourceGenericOwner(replicaSetKey types.NamespacedName) *metav1.OwnerReference {
+	shard := wc.shardFor(replicaSetKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	return utils.GetReplicaSetSourceGenericOwner(shard.replicaSets[replicaSetKey])
+}
+
+// determineNewPodAffinityPreferenceForGenericOwner resolves the OptimizeSchedulingSetting from the top-level owner
+// of the ownerRef, then determines by the WorkloadSchedulingInfo of the source workload of the Pod.
+func (wc *WebhookCache) determineNewPodAffinityPreferenceForGenericOwner(ctx context.Context, ownerRef metav1.OwnerReference,
+	podSourceWorkloadType string, podSourceWorkloadKey types.NamespacedName) (podaffinity.PodAffinitySettingName, bool, bool) {
+
+	setting := wc.resolveGenericOwnerSetting(ctx, podSourceWorkloadKey.Namespace, ownerRef)
+	if setting == nil {
+		return podaffinity.PodAffinityUnset, false, false
+	}
+
+	shard := wc.shardFor(podSourceWorkloadKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	wsi := shard.getWorkloadSchedulingInfo(podSourceWorkloadType, podSourceWorkloadKey)
+	if wsi == nil {
+		// None of the Pods of the workload has been cached yet.
+		wsi = apis.NewWorkloadSchedulingInfo()
+	}
+	result, dryRun := wc.determineNewPodAffinityPreference(setting, wsi, podSourceWorkloadKey.Namespace)
+	return result, dryRun, false
+}
+
+// resolveGenericOwnerSetting builds the OptimizeSchedulingSetting from the metadata and the scale of the top-level owner,
+// it returns nil if the owner can't be resolved. A missing owner is not retried, the owners are created before their
+// Pods, so it has been deleted.
+func (wc *WebhookCache) resolveGenericOwnerSetting(ctx context.Context, namespace string,
+	ownerRef metav1.OwnerReference) *apis.OptimizeSchedulingSetting {
+
+	scalableOwner, err := wc.ownerResolver.Resolve(ctx, namespace, ownerRef)
+	if apierrors.IsNotFound(err) {
+		klog.V(3).Infof("Cant find the owner %s %s/%s, it may have been deleted.", ownerRef.Kind, namespace, ownerRef.Name)
+		return nil
+	}
+	if err != nil {
+		klog.V(3).Infof("Cant resolve the scalable owner of %s %s/%s: %v", ownerRef.Kind, namespace, ownerRef.Name, err)
+		return nil
+	}
+
+	return apis.NewOptimizeSchedulingSetting(scalableOwner.Labels, scalableOwner.Annotations, scalableOwner.Replicas,
+		scalableOwner.GroupVersionKind.Kind, &wc.config.Get().DefaultStrategies)
+}
+
+// require the mutex of the shard of the wsi locked. It also returns true if the Pod is in the dry-run mode,
+// by the namespace or the setting of the workload.
 func (wc *WebhookCache) determineNewPodAffinityPreference(schedulingSetting *apis.OptimizeSchedulingSetting,
-	wsi *apis.WorkloadSchedulingInfo) podaffinity.PodAffinitySettingName {
+	wsi *apis.WorkloadSchedulingInfo, namespace string) (podaffinity.PodAffinitySettingName, bool) {
 
 	if schedulingSetting == nil || wsi == nil {
 		// This should never happen.
 		klog.Errorf("Workload shcuedlingSetting or workloadSchedulingInfo is nil.")
-		return podaffinity.PodAffinityUnset
+		return podaffinity.PodAffinityUnset, false
 	}
 
 	if !schedulingSetting.Enable {
 		klog.V(3).Infof("Wokrload didnt enable optimize scheduling, return unset.")
-		return podaffinity.PodAffinityUnset
+		return podaffinity.PodAffinityUnset, false
 	}
 
 	klog.V(3).Infof("Determining new pod affinity, strategy: %s, target-on-demand: %d target-spot: %d, "+
 		"available-on-demand: %d, available-spot: %d", schedulingSetting.Strategy, schedulingSetting.TargetOnDemandNum,
 		schedulingSetting.TargetOnSpotNum, wsi.OnDemandReplicaCount, wsi.SpotReplicaCount)
 
-	// Now, we know the target numbers for on-demand and spot Replicas,
-	// and we also know how many existing Pods have been marked as on-demand or spot.
-	// We simply need to make a straightforward judgment based on this information.
-
-	// If the number of currently created Pods that have been marked as on-demand has not reached the target,
-	// then return pod_affinity.PodAffinityOnDemand directly.
-	if wsi.OnDemandReplicaCount < schedulingSetting.TargetOnDemandNum {
-		return podaffinity.PodAffinityOnDemand
-	}
-
-	// If the number of on-demand replicas has been satisfied, under normal circumstances,
-	// the remaining Pods should all be assigned to spot.
-	if wsi.SpotReplicaCount < schedulingSetting.TargetOnSpotNum {
-		return podaffinity.PodAffinitySpot
-	}
-
-	return podaffinity.PodAffinityUnset
+	dryRun := schedulingSetting.DryRun || wc.config.Get().DryRun.IsDryRun(namespace)
+	return apis.DetermineNewPodAffinity(schedulingSetting, wsi, dryRun), dryRun
 }
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+func TestDryRunNamespace(t *testing.T) {
+	c := config.NewDefaultConfiguration()
+	c.DryRun.Namespaces = []string{harnessNamespace}
+	h := newTestHarness(t, c)
+
+	deployment := newHarnessDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	h.add(deployment)
+	h.add(replicaSet)
+
+	// The dry-run Pods are decided by the placement recorded for each other.
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 3)...)
+	expectAffinities(t, got, onDemand, onDemand, spot)
+	// But none of them is placed.
+	h.expectObserved("Deployment", deployment.Name, 0, 0)
+}
+
+func TestDryRunWorkload(t *testing.T) {
+	h := newTestHarness(t, nil)
+
+	deployment := newHarnessDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	deployment.Labels[optimizescheduling.OptimizeSchedulingDryRunKey] = "true"
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	h.add(deployment)
+	h.add(replicaSet)
+
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 2)...)
+	expectAffinities(t, got, onDemand, onDemand)
+	h.expectObserved("Deployment", deployment.Name, 0, 0)
+
+	// Once enforced, the new Pods are decided by the placed Pods only.
+	deployment = deployment.DeepCopy()
+	delete(deployment.Labels, optimizescheduling.OptimizeSchedulingDryRunKey)
+	h.update(deployment)
+
+	got = h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 2, 4)...)
+	expectAffinities(t, got, onDemand, onDemand)
+	h.expectObserved("Deployment", deployment.Name, 2, 0)
+}
@@ -4,7 +4,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 
-	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/config"
 	"vacant.sh/vmanager/pkg/webhook/cache/apis"
 	"vacant.sh/vmanager/pkg/webhook/cache/utils"
 )
@@ -16,17 +16,18 @@ func (wc *WebhookCache) addReplicaSet(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(replicaSet.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
 	replicaSetKey := types.NamespacedName{
 		Namespace: replicaSet.Namespace,
 		Name:      replicaSet.Name,
 	}
 
-	wc.replicaSets[replicaSetKey] = replicaSet
-	if wc.replicaSetWorkloadSchedulingInfo[replicaSetKey] == nil {
-		wc.replicaSetWorkloadSchedulingInfo[replicaSetKey] = apis.NewWorkloadSchedulingInfo()
+	shard.replicaSets[replicaSetKey] = replicaSet
+	if shard.replicaSetWorkloadSchedulingInfo[replicaSetKey] == nil {
+		shard.replicaSetWorkloadSchedulingInfo[replicaSetKey] = apis.NewWorkloadSchedulingInfo()
 	}
 
 	klog.V(5).Infof("Added ReplicaSet %s/%s", replicaSet.Namespace, replicaSet.Name)
@@ -39,13 +40,16 @@ func (wc *WebhookCache) deleteReplicaSet(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(replicaSet.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	delete(wc.replicaSets, types.NamespacedName{
+	replicaSetKey := types.NamespacedName{
 		Namespace: replicaSet.Namespace,
 		Name:      replicaSet.Name,
-	})
+	}
+	delete(shard.replicaSets, replicaSetKey)
+	shard.clearWorkloadSchedulingInfo("ReplicaSet", replicaSetKey)
 }
 
 func (wc *WebhookCache) updateReplicaSet(oldObj, newObj interface{}) {
@@ -60,13 +64,16 @@ func (wc *WebhookCache) addDeployment(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(deployment.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	wc.deployments[types.NamespacedName{
+	deploymentKey := types.NamespacedName{
 		Namespace: deployment.Namespace,
 		Name:      deployment.Name,
-	}] = apis.NewDeploymentInfo(deployment)
+	}
+	shard.deployments[deploymentKey] = apis.NewDeploymentInfo(deployment, &wc.config.Get().DefaultStrategies)
+	shard.recordPendingStrategyChange(workloadReference{Type: "Deployment", Key: deploymentKey}, deployment.Annotations, wc.clock.Now())
 
 	klog.V(5).Infof("Added DeploymentInfo %s/%s", deployment.Namespace, deployment.Name)
 }
@@ -78,10 +85,11 @@ func (wc *WebhookCache) deleteDeployment(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(deployment.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	delete(wc.deployments, types.NamespacedName{
+	delete(shard.deployments, types.NamespacedName{
 		Namespace: deployment.Namespace,
 		Name:      deployment.Name,
 	})
@@ -90,6 +98,14 @@ func (wc *WebhookCache) deleteDeployment(obj interface{}) {
 func (wc *WebhookCache) updateDeployment(oldObj, newObj interface{}) {
 	wc.deleteDeployment(oldObj)
 	wc.addDeployment(newObj)
+
+	oldDeployment, newDeployment := convertToDeployment(oldObj), convertToDeployment(newObj)
+	if oldDeployment != nil && newDeployment != nil {
+		defaultStrategies := &wc.config.Get().DefaultStrategies
+		wc.recordStrategyChange("Deployment", types.NamespacedName{Namespace: newDeployment.Namespace, Name: newDeployment.Name},
+			apis.NewDeploymentInfo(oldDeployment, defaultStrategies).OptimizeSchedulingSetting,
+			apis.NewDeploymentInfo(newDeployment, defaultStrategies).OptimizeSchedulingSetting)
+	}
 }
 
 func (wc *WebhookCache) addStatefulSet(obj interface{}) {
@@ -99,17 +115,19 @@ func (wc *WebhookCache) addStatefulSet(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(statefulSet.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
 	statefulSetKey := types.NamespacedName{
 		Namespace: statefulSet.Namespace,
 		Name:      statefulSet.Name,
 	}
 
-	wc.statefulSets[statefulSetKey] = apis.NewStatefulSetInfo(statefulSet)
-	if wc.statefulSetWorkloadSchedulingInfo[statefulSetKey] == nil {
-		wc.statefulSetWorkloadSchedulingInfo[statefulSetKey] = apis.NewWorkloadSchedulingInfo()
+	shard.statefulSets[statefulSetKey] = apis.NewStatefulSetInfo(statefulSet, &wc.config.Get().DefaultStrategies)
+	shard.recordPendingStrategyChange(workloadReference{Type: "StatefulSet", Key: statefulSetKey}, statefulSet.Annotations, wc.clock.Now())
+	if shard.statefulSetWorkloadSchedulingInfo[statefulSetKey] == nil {
+		shard.statefulSetWorkloadSchedulingInfo[statefulSetKey] = apis.NewWorkloadSchedulingInfo()
 	}
 
 	klog.V(5).Infof("Added StatefulSetInfo %s/%s", statefulSet.Namespace, statefulSet.Name)
@@ -122,18 +140,77 @@ func (wc *WebhookCache) deleteStatefulSet(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(statefulSet.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	delete(wc.statefulSets, types.NamespacedName{
+	statefulSetKey := types.NamespacedName{
 		Namespace: statefulSet.Namespace,
 		Name:      statefulSet.Name,
-	})
+	}
+	delete(shard.statefulSets, statefulSetKey)
+	shard.clearWorkloadSchedulingInfo("StatefulSet", statefulSetKey)
 }
 
 func (wc *WebhookCache) updateStatefulSet(oldObj, newObj interface{}) {
 	wc.deleteStatefulSet(oldObj)
 	wc.addStatefulSet(newObj)
+
+	oldStatefulSet, newStatefulSet := convertToStatefulSet(oldObj), convertToStatefulSet(newObj)
+	if oldStatefulSet != nil && newStatefulSet != nil {
+		defaultStrategies := &wc.config.Get().DefaultStrategies
+		wc.recordStrategyChange("StatefulSet", types.NamespacedName{Namespace: newStatefulSet.Namespace, Name: newStatefulSet.Name},
+			apis.NewStatefulSetInfo(oldStatefulSet, defaultStrategies).OptimizeSchedulingSetting,
+			apis.NewStatefulSetInfo(newStatefulSet, defaultStrategies).OptimizeSchedulingSetting)
+	}
+}
+
+func (wc *WebhookCache) addJob(obj interface{}) {
+	job := convertToJob(obj)
+
+	if job == nil {
+		return
+	}
+
+	shard := wc.shardFor(job.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	jobKey := types.NamespacedName{
+		Namespace: job.Namespace,
+		Name:      job.Name,
+	}
+
+	shard.jobs[jobKey] = apis.NewJobInfo(job, &wc.config.Get().DefaultStrategies)
+	if shard.jobWorkloadSchedulingInfo[jobKey] == nil {
+		shard.jobWorkloadSchedulingInfo[jobKey] = apis.NewWorkloadSchedulingInfo()
+	}
+
+	klog.V(5).Infof("Added JobInfo %s/%s", job.Namespace, job.Name)
+}
+
+func (wc *WebhookCache) deleteJob(obj interface{}) {
+	job := convertToJob(obj)
+
+	if job == nil {
+		return
+	}
+
+	shard := wc.shardFor(job.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	jobKey := types.NamespacedName{
+		Namespace: job.Namespace,
+		Name:      job.Name,
+	}
+	delete(shard.jobs, jobKey)
+	shard.clearWorkloadSchedulingInfo("Job", jobKey)
+}
+
+func (wc *WebhookCache) updateJob(oldObj, newObj interface{}) {
+	wc.deleteJob(oldObj)
+	wc.addJob(newObj)
 }
 
 func (wc *WebhookCache) addPod(obj interface{}) {
@@ -162,11 +239,14 @@ func (wc *WebhookCache) addPod(obj interface{}) {
 	}
 
 	// The third step is to determine whether the Pod has been marked with our required Affinity by the Webhook,
-	// which will be indicated in the label pod_affinity.PodAffinityLabelKey.
+	// which will be indicated in the label pod_affinity.PodAffinityLabelKey. The decision recorded in the dry-run mode
+	// is kept separately, since the Pod is not placed by it.
 	podAffinitySetting := utils.GetPodAffinitySetting(pod)
+	podDryRunAffinitySetting := utils.GetPodDryRunAffinitySetting(pod)
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(pod.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
 	// The fourth step is to retrieve the WorkloadSchedulingInfo of this Pod from the Cache,
 	// which includes the status of the workload we are concerned about,
@@ -174,28 +254,32 @@ func (wc *WebhookCache) addPod(obj interface{}) {
 	var wsi *apis.WorkloadSchedulingInfo
 	switch podSourceWorkloadType {
 	case "ReplicaSet":
-		if wc.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey] == nil {
-			wc.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
+		if shard.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey] == nil {
+			shard.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
 		}
-		wsi = wc.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
+		wsi = shard.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
 	case "StatefulSet":
-		if wc.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey] == nil {
-			wc.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
+		if shard.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey] == nil {
+			shard.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
+		}
+		wsi = shard.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
+	case "Job":
+		if shard.jobWorkloadSchedulingInfo[*podSourceWorkloadKey] == nil {
+			shard.jobWorkloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
 		}
-		wsi = wc.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
+		wsi = shard.jobWorkloadSchedulingInfo[*podSourceWorkloadKey]
 	default:
-		// Return if it's not ReplicaSet or StatefulSet.
-		return
+		// The Pod is controlled by a generic workload directly, such as an OpenKruise CloneSet.
+		reference := workloadReference{Type: podSourceWorkloadType, Key: *podSourceWorkloadKey}
+		if shard.genericWorkloadSchedulingInfo[reference] == nil {
+			shard.genericWorkloadSchedulingInfo[reference] = apis.NewWorkloadSchedulingInfo()
+		}
+		wsi = shard.genericWorkloadSchedulingInfo[reference]
 	}
 
-	// The fifth step is to maintain the consistency of the WorkloadSchedulingInfo data.
-	wsi.Pods[podKey] = podAffinitySetting
-	switch podAffinitySetting {
-	case podaffinity.PodAffinityOnDemand:
-		wsi.OnDemandReplicaCount++
-	case podaffinity.PodAffinitySpot:
-		wsi.SpotReplicaCount++
-	}
+	// The fifth step is to maintain the consistency of the WorkloadSchedulingInfo data,
+	// the terminal or terminating Pod is removed from it since its replacement needs the affinity.
+	wsi.UpdatePod(podKey, podAffinitySetting, podDryRunAffinitySetting, utils.IsPodLive(pod))
 }
 
 func (wc *WebhookCache) deletePod(obj interface{}) {
@@ -220,46 +304,71 @@ func (wc *WebhookCache) deletePod(obj interface{}) {
 		return
 	}
 
-	wc.mutex.Lock()
-	defer wc.mutex.Unlock()
+	shard := wc.shardFor(pod.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	var wsi *apis.WorkloadSchedulingInfo
+	wsi := shard.getWorkloadSchedulingInfo(podSourceWorkloadType, *podSourceWorkloadKey)
 
-	switch podSourceWorkloadType {
-	case "ReplicaSet":
-		wsi = wc.replicaSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
-	case "StatefulSet":
-		wsi = wc.statefulSetWorkloadSchedulingInfo[*podSourceWorkloadKey]
-	default:
-		// Return if it's not ReplicaSet or StatefulSet.
+	// In deletePod, the initial steps are the same as in addPod.
+	// The difference here is that the type of the Pod’s Affinity is directly obtained from the Cache,
+	// and then it is used to maintain the data of our WorkloadSchedulingInfo.
+	if wsi == nil || !wsi.RemovePod(podKey) {
+		// The terminal or terminating Pod has already been removed when it stops being live.
+		if utils.IsPodLive(pod) {
+			klog.Errorf("Cant find pod affinity setting for Pod %s/%s, workload type: %v, workload key: %v ",
+				pod.Namespace, pod.Name, podSourceWorkloadType, podSourceWorkloadKey)
+		}
 		return
 	}
 
-	if wsi.Pods[podKey] == "" {
-		klog.Errorf("Cant find pod affinity setting for Pod %s/%s, workload type: %v, workload key: %v ",
-			pod.Namespace, pod.Name, podSourceWorkloadType, podSourceWorkloadKey)
+	// If all the Pods of a certain workload have been deleted, then we can choose to clear the Cache.
+	shard.clearWorkloadSchedulingInfo(podSourceWorkloadType, *podSourceWorkloadKey)
+}
+
+// clearWorkloadSchedulingInfo removes the WorkloadSchedulingInfo of the source workload which has no Pods, unless
+// the workload is still cached, since its new Pods are determined by it. require mutex locked.
+func (shard *cacheShard) clearWorkloadSchedulingInfo(workloadType string, workloadKey types.NamespacedName) {
+	wsi := shard.getWorkloadSchedulingInfo(workloadType, workloadKey)
+	if wsi == nil || len(wsi.Pods) > 0 {
 		return
 	}
 
-	// In deletePod, the initial steps are the same as in addPod.
-	// The difference here is that the type of the Pod’s Affinity is directly obtained from the Cache,
-	// and then it is used to maintain the data of our WorkloadSchedulingInfo.
-	switch wsi.Pods[podKey] {
-	case podaffinity.PodAffinityOnDemand:
-		wsi.OnDemandReplicaCount--
-	case podaffinity.PodAffinitySpot:
-		wsi.SpotReplicaCount--
+	reference := workloadReference{Type: workloadType, Key: workloadKey}
+	switch workloadType {
+	case "ReplicaSet":
+		if _, ok := shard.replicaSets[workloadKey]; ok {
+			return
+		}
+		delete(shard.replicaSetWorkloadSchedulingInfo, workloadKey)
+	case "StatefulSet":
+		if _, ok := shard.statefulSets[workloadKey]; ok {
+			return
+		}
+		delete(shard.statefulSetWorkloadSchedulingInfo, workloadKey)
+	case "Job":
+		if _, ok := shard.jobs[workloadKey]; ok {
+			return
+		}
+		delete(shard.jobWorkloadSchedulingInfo, workloadKey)
+	default:
+		delete(shard.genericWorkloadSchedulingInfo, reference)
 	}
-	delete(wsi.Pods, podKey)
+	delete(shard.lastDecisionTimes, reference)
+}
 
-	// If all the Pods of a certain workload have been deleted, then we can choose to clear the Cache.
-	if len(wsi.Pods) == 0 {
-		switch podSourceWorkloadType {
-		case "ReplicaSet":
-			delete(wc.replicaSetWorkloadSchedulingInfo, *podSourceWorkloadKey)
-		case "StatefulSet":
-			delete(wc.statefulSetWorkloadSchedulingInfo, *podSourceWorkloadKey)
-		}
+// getWorkloadSchedulingInfo returns the WorkloadSchedulingInfo of the source workload of the Pods,
+// or nil if none of its Pods is cached. require mutex locked.
+func (shard *cacheShard) getWorkloadSchedulingInfo(workloadType string, workloadKey types.NamespacedName) *apis.WorkloadSchedulingInfo {
+	switch workloadType {
+	case "ReplicaSet":
+		return shard.replicaSetWorkloadSchedulingInfo[workloadKey]
+	case "StatefulSet":
+		return shard.statefulSetWorkloadSchedulingInfo[workloadKey]
+	case "Job":
+		return shard.jobWorkloadSchedulingInfo[workloadKey]
+	default:
+		return shard.genericWorkloadSchedulingInfo[workloadReference{Type: workloadType, Key: workloadKey}]
 	}
 }
 
@@ -267,3 +376,28 @@ func (wc *WebhookCache) updatePod(oldObj, newObj interface{}) {
 	wc.deletePod(oldObj)
 	wc.addPod(newObj)
 }
+
+// refreshOptimizeSchedulingSettings rebuilds the OptimizeSchedulingSetting of all the cached workloads
+// with the default strategies of the new configuration.
+func (wc *WebhookCache) refreshOptimizeSchedulingSettings(newConfig *config.WebhookManagerConfiguration) {
+	deploymentNum, statefulSetNum, jobNum := 0, 0, 0
+	for _, shard := range wc.shards {
+		shard.mutex.Lock()
+		for deploymentKey, deploymentInfo := range shard.deployments {
+			shard.deployments[deploymentKey] = apis.NewDeploymentInfo(deploymentInfo.Deployment, &newConfig.DefaultStrategies)
+		}
+		for statefulSetKey, statefulSetInfo := range shard.statefulSets {
+			shard.statefulSets[statefulSetKey] = apis.NewStatefulSetInfo(statefulSetInfo.StatefulSet, &newConfig.DefaultStrategies)
+		}
+		for jobKey, jobInfo := range shard.jobs {
+			shard.jobs[jobKey] = apis.NewJobInfo(jobInfo.Job, &newConfig.DefaultStrategies)
+		}
+		deploymentNum += len(shard.deployments)
+		statefulSetNum += len(shard.statefulSets)
+		jobNum += len(shard.jobs)
+		shard.mutex.Unlock()
+	}
+
+	klog.V(3).Infof("Refreshed the optimize scheduling settings of %d Deployments, %d StatefulSets and %d Jobs.",
+		deploymentNum, statefulSetNum, jobNum)
+}
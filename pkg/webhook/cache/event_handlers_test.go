@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+func TestPodLifecycleCounts(t *testing.T) {
+	tests := []struct {
+		name string
+		// terminate returns the new state of the first on-demand Pod.
+		terminate        func(pod *corev1.Pod) *corev1.Pod
+		expectOnDemand   int
+		expectSpot       int
+		expectNewPodType podaffinity.PodAffinitySettingName
+	}{
+		{
+			name: "graceful termination",
+			terminate: func(pod *corev1.Pod) *corev1.Pod {
+				pod = pod.DeepCopy()
+				pod.DeletionTimestamp = ptr.To(metav1.Now())
+				pod.DeletionGracePeriodSeconds = ptr.To(int64(30))
+				return pod
+			},
+			expectOnDemand:   1,
+			expectSpot:       1,
+			expectNewPodType: podaffinity.PodAffinityOnDemand,
+		},
+		{
+			name: "eviction",
+			terminate: func(pod *corev1.Pod) *corev1.Pod {
+				pod = pod.DeepCopy()
+				pod.Status.Phase = corev1.PodFailed
+				pod.Status.Reason = "Evicted"
+				return pod
+			},
+			expectOnDemand:   1,
+			expectSpot:       1,
+			expectNewPodType: podaffinity.PodAffinityOnDemand,
+		},
+		{
+			name: "succeeded",
+			terminate: func(pod *corev1.Pod) *corev1.Pod {
+				pod = pod.DeepCopy()
+				pod.Status.Phase = corev1.PodSucceeded
+				return pod
+			},
+			expectOnDemand:   1,
+			expectSpot:       1,
+			expectNewPodType: podaffinity.PodAffinityOnDemand,
+		},
+		{
+			name: "still running",
+			terminate: func(pod *corev1.Pod) *corev1.Pod {
+				return pod.DeepCopy()
+			},
+			expectOnDemand:   2,
+			expectSpot:       1,
+			expectNewPodType: podaffinity.PodAffinityUnset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, nil)
+			statefulSet := newHarnessStatefulSet("web", 3, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+			h.add(statefulSet)
+
+			pods := newHarnessPods("StatefulSet", statefulSet.Name, 0, 3)
+			h.add(withAffinity(pods[0], podaffinity.PodAffinityOnDemand))
+			h.add(withAffinity(pods[1], podaffinity.PodAffinityOnDemand))
+			h.add(withAffinity(pods[2], podaffinity.PodAffinitySpot))
+
+			terminatingPod := tt.terminate(h.pod(pods[0].Name))
+			h.update(terminatingPod)
+			h.expectObserved("StatefulSet", statefulSet.Name, tt.expectOnDemand, tt.expectSpot)
+
+			// The replacement of the terminating Pod takes its slot.
+			if result := h.determine(newHarnessPod("StatefulSet", statefulSet.Name, 3)); result != tt.expectNewPodType {
+				t.Errorf("expect new pod affinity %s, got %s", tt.expectNewPodType, result)
+			}
+
+			// The final delete event of the terminated Pod must not be counted twice.
+			h.delete(terminatingPod)
+			h.expectObserved("StatefulSet", statefulSet.Name, 1, 1)
+		})
+	}
+}
@@ -0,0 +1,512 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+	toolscache "k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/owner"
+)
+
+// harnessNamespace is the namespace of the objects built by the harness.
+const harnessNamespace = "default"
+
+// kruiseStatefulSetGVK is the Advanced StatefulSet of OpenKruise, which is resolved as a generic owner.
+var kruiseStatefulSetGVK = schema.GroupVersionKind{Group: "apps.kruise.io", Version: "v1beta1", Kind: "StatefulSet"}
+
+// testHarness drives a WebhookCache built from a fake clientset without running its informers. The events are
+// delivered synchronously to the same handlers as the informers, so the state of the cache is deterministic
+// after every step. The objects are also written to the fake clientset, which is read by the API fallback.
+type testHarness struct {
+	t          *testing.T
+	kubeClient *fake.Clientset
+	cache      *WebhookCache
+	// clock is the clock of the cache, which is stepped by the tests.
+	clock *clocktesting.FakeClock
+	// objects are the last delivered state of the objects, which are the old objects of the next update.
+	objects map[string]runtime.Object
+	// transform is applied to the delivered objects like the informers, it strips them by default.
+	transform toolscache.TransformFunc
+}
+
+// newTestHarness builds the cache with the configuration, or the defaults if it's nil. The backoff is shortened,
+// so that a lookup of a missing workload fails fast.
+func newTestHarness(t *testing.T, c *config.WebhookManagerConfiguration) *testHarness {
+	t.Helper()
+
+	if c == nil {
+		c = config.NewDefaultConfiguration()
+	}
+	c.Backoff.Duration = metav1.Duration{Duration: time.Millisecond}
+	c.Backoff.Steps = 2
+	c.Backoff.Timeout = metav1.Duration{Duration: 100 * time.Millisecond}
+
+	kubeClient := fake.NewSimpleClientset()
+	ownerResolver := owner.NewResolverForClients(metadatafake.NewSimpleMetadataClient(runtime.NewScheme()),
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), meta.NewDefaultRESTMapper(nil))
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	wc, err := NewWebhookCacheForClients(kubeClient, ownerResolver, config.NewHolder(c), fakeClock)
+	if err != nil {
+		t.Fatalf("failed to build the cache: %v", err)
+	}
+
+	return &testHarness{
+		t:          t,
+		kubeClient: kubeClient,
+		cache:      wc,
+		clock:      fakeClock,
+		objects:    map[string]runtime.Object{},
+		transform:  transformObject,
+	}
+}
+
+// setGenericOwner serves the metadata and the scale of a generic owner to the owner resolver of the cache,
+// the owners set before are dropped.
+func (h *testHarness) setGenericOwner(gvk schema.GroupVersionKind, objectMeta metav1.ObjectMeta, replicas int64) {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(gvk, meta.RESTScopeNamespace)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("get", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}}, nil
+	})
+
+	metadataScheme := metadatafake.NewTestScheme()
+	metadataScheme.AddKnownTypeWithName(gvk, &metav1.PartialObjectMetadata{})
+	ownerMeta := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind},
+		ObjectMeta: objectMeta,
+	}
+	h.cache.ownerResolver = owner.NewResolverForClients(metadatafake.NewSimpleMetadataClient(metadataScheme, ownerMeta),
+		dynamicClient, restMapper)
+}
+
+// handlerFor returns the event handler registered by the cache for the type of the object.
+func (h *testHarness) handlerFor(obj runtime.Object) toolscache.ResourceEventHandler {
+	h.t.Helper()
+
+	handler, ok := h.cache.eventHandlers[reflect.TypeOf(obj)]
+	if !ok {
+		h.t.Fatalf("no event handler for %T", obj)
+	}
+	return handler
+}
+
+// objectKey returns the key of the object in the objects, and its resource for the fake clientset.
+func (h *testHarness) objectKey(obj runtime.Object) (string, metav1.Object) {
+	h.t.Helper()
+
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		h.t.Fatalf("unknown kind of %T: %v", obj, err)
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		h.t.Fatalf("failed to access the metadata of %T: %v", obj, err)
+	}
+	return fmt.Sprintf("%s/%s/%s", gvks[0].Kind, object.GetNamespace(), object.GetName()), object
+}
+
+// create writes the object to the fake clientset only, as if its event has not been received yet.
+func (h *testHarness) create(obj runtime.Object) {
+	h.t.Helper()
+
+	if err := h.kubeClient.Tracker().Add(obj.DeepCopyObject()); err != nil {
+		h.t.Fatalf("failed to create the object: %v", err)
+	}
+}
+
+// add creates the object and delivers its add event.
+func (h *testHarness) add(obj runtime.Object) {
+	h.t.Helper()
+
+	key, _ := h.objectKey(obj)
+	h.create(obj)
+	h.objects[key] = obj.DeepCopyObject()
+	h.deliver(obj, func(handler toolscache.ResourceEventHandler, transformed interface{}) {
+		handler.OnAdd(transformed, false)
+	})
+}
+
+// update replaces the object and delivers its update event with the last delivered state.
+func (h *testHarness) update(obj runtime.Object) {
+	h.t.Helper()
+
+	key, object := h.objectKey(obj)
+	oldObj, ok := h.objects[key]
+	if !ok {
+		h.t.Fatalf("%s has not been added", key)
+	}
+	gvks, _, _ := scheme.Scheme.ObjectKinds(obj)
+	gvr, _ := meta.UnsafeGuessKindToResource(gvks[0])
+	if err := h.kubeClient.Tracker().Update(gvr, obj.DeepCopyObject(), object.GetNamespace()); err != nil {
+		h.t.Fatalf("failed to update %s: %v", key, err)
+	}
+
+	h.objects[key] = obj.DeepCopyObject()
+	oldTransformed, _ := h.transform(oldObj.DeepCopyObject())
+	h.deliver(obj, func(handler toolscache.ResourceEventHandler, transformed interface{}) {
+		handler.OnUpdate(oldTransformed, transformed)
+	})
+}
+
+// delete removes the object and delivers its delete event with the last delivered state.
+func (h *testHarness) delete(obj runtime.Object) {
+	h.t.Helper()
+
+	key, object := h.objectKey(obj)
+	oldObj, ok := h.objects[key]
+	if !ok {
+		h.t.Fatalf("%s has not been added", key)
+	}
+	gvks, _, _ := scheme.Scheme.ObjectKinds(obj)
+	gvr, _ := meta.UnsafeGuessKindToResource(gvks[0])
+	if err := h.kubeClient.Tracker().Delete(gvr, object.GetNamespace(), object.GetName()); err != nil {
+		h.t.Fatalf("failed to delete %s: %v", key, err)
+	}
+
+	delete(h.objects, key)
+	h.deliver(oldObj, func(handler toolscache.ResourceEventHandler, transformed interface{}) {
+		handler.OnDelete(transformed)
+	})
+}
+
+// deliver transforms the object like the informers, then calls the handler of its type.
+func (h *testHarness) deliver(obj runtime.Object, call func(handler toolscache.ResourceEventHandler, transformed interface{})) {
+	transformed, err := h.transform(obj.DeepCopyObject())
+	if err != nil {
+		h.t.Fatalf("failed to transform the object: %v", err)
+	}
+	call(h.handlerFor(obj), transformed)
+}
+
+// determine returns the affinity of the new Pod decided by the cache.
+func (h *testHarness) determine(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
+	affinity, _ := h.cache.DetermineNewPodAffinityPreference(context.Background(), pod)
+	return affinity
+}
+
+// admit plays the mutating webhook and the API server, the affinity decided for the new Pod is labeled,
+// or annotated in the dry-run mode, and the Pod is added. It returns the affinity.
+func (h *testHarness) admit(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
+	h.t.Helper()
+
+	affinity, dryRun := h.cache.DetermineNewPodAffinityPreference(context.Background(), pod)
+	if affinity != podaffinity.PodAffinityUnset {
+		pod = pod.DeepCopy()
+		if dryRun {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[podaffinity.PodAffinityDryRunAnnotationKey] = string(affinity)
+		} else {
+			if pod.Labels == nil {
+				pod.Labels = map[string]string{}
+			}
+			pod.Labels[podaffinity.PodAffinityLabelKey] = string(affinity)
+		}
+	}
+	h.add(pod)
+	return affinity
+}
+
+// admitAll admits the Pods in order, and returns their affinities.
+func (h *testHarness) admitAll(pods ...*corev1.Pod) []podaffinity.PodAffinitySettingName {
+	h.t.Helper()
+
+	affinities := make([]podaffinity.PodAffinitySettingName, 0, len(pods))
+	for _, pod := range pods {
+		affinities = append(affinities, h.admit(pod))
+	}
+	return affinities
+}
+
+// pod returns the last delivered state of the Pod.
+func (h *testHarness) pod(name string) *corev1.Pod {
+	h.t.Helper()
+
+	obj, ok := h.objects[fmt.Sprintf("Pod/%s/%s", harnessNamespace, name)]
+	if !ok {
+		h.t.Fatalf("Pod %s has not been added", name)
+	}
+	return obj.(*corev1.Pod)
+}
+
+// expectObserved checks the counted Pods of a Deployment, including all its ReplicaSets, a StatefulSet or a Job.
+func (h *testHarness) expectObserved(workloadType, name string, onDemand, spot int) {
+	h.t.Helper()
+
+	key := types.NamespacedName{Namespace: harnessNamespace, Name: name}
+	shard := h.cache.shardFor(key.Namespace)
+	shard.mutex.Lock()
+	var observedOnDemand, observedSpot int
+	var ok bool
+	if workloadType == "Job" {
+		var wsi *apis.WorkloadSchedulingInfo
+		if wsi, ok = shard.jobWorkloadSchedulingInfo[key]; ok {
+			observedOnDemand, observedSpot = wsi.OnDemandReplicaCount, wsi.SpotReplicaCount
+		}
+	} else {
+		var deviation *apis.WorkloadDeviation
+		if deviation, ok = shard.getWorkloadDeviation(workloadReference{Type: workloadType, Key: key},
+			shard.groupReplicaSetsByDeployment()); ok {
+			observedOnDemand, observedSpot = deviation.ObservedOnDemandNum, deviation.ObservedOnSpotNum
+		}
+	}
+	shard.mutex.Unlock()
+
+	if !ok {
+		h.t.Fatalf("%s %v is not in the cache", workloadType, key)
+	}
+	if observedOnDemand != onDemand || observedSpot != spot {
+		h.t.Errorf("expect %s %v observed on-demand %d spot %d, got on-demand %d spot %d", workloadType, key,
+			onDemand, spot, observedOnDemand, observedSpot)
+	}
+}
+
+// expectAffinities compares the affinities decided in order.
+func expectAffinities(t *testing.T, got []podaffinity.PodAffinitySettingName, expect ...podaffinity.PodAffinitySettingName) {
+	t.Helper()
+
+	if len(got) != len(expect) {
+		t.Fatalf("expect affinities %v, got %v", expect, got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Fatalf("expect affinities %v, got %v", expect, got)
+		}
+	}
+}
+
+// repeatAffinity returns the affinity n times.
+func repeatAffinity(affinity podaffinity.PodAffinitySettingName, n int) []podaffinity.PodAffinitySettingName {
+	affinities := make([]podaffinity.PodAffinitySettingName, n)
+	for i := range affinities {
+		affinities[i] = affinity
+	}
+	return affinities
+}
+
+// newHarnessWorkloadMeta returns the metadata of a workload with the strategy, an empty strategy disables it.
+func newHarnessWorkloadMeta(name, strategy string, customOnDemand int) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{Namespace: harnessNamespace, Name: name, UID: types.UID(name)}
+	if strategy == "" {
+		return objectMeta
+	}
+
+	objectMeta.Labels = map[string]string{
+		optimizescheduling.OptimizeSchedulingKey:         "true",
+		optimizescheduling.OptimizeSchedulingStrategyKey: strategy,
+	}
+	if strategy == optimizescheduling.OptimizeSchedulingStrategyCustom {
+		objectMeta.Annotations = map[string]string{
+			optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey: fmt.Sprint(customOnDemand),
+		}
+	}
+	return objectMeta
+}
+
+func newHarnessDeployment(name string, replicas int32, strategy string, customOnDemand int) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: newHarnessWorkloadMeta(name, strategy, customOnDemand),
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(replicas)},
+	}
+}
+
+// newHarnessReplicaSet returns the ReplicaSet of the revision of the Deployment.
+func newHarnessReplicaSet(deployment *appsv1.Deployment, revision string, replicas int32) *appsv1.ReplicaSet {
+	name := deployment.Name + "-" + revision
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: harnessNamespace,
+			Name:      name,
+			UID:       types.UID(name),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment.Name, UID: deployment.UID, Controller: ptr.To(true)},
+			},
+		},
+		Spec: appsv1.ReplicaSetSpec{Replicas: ptr.To(replicas)},
+	}
+}
+
+func newHarnessStatefulSet(name string, replicas int32, strategy string, customOnDemand int) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: newHarnessWorkloadMeta(name, strategy, customOnDemand),
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(replicas)},
+	}
+}
+
+func newHarnessJob(name string, parallelism int32, strategy string, customOnDemand int) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: newHarnessWorkloadMeta(name, strategy, customOnDemand),
+		Spec:       batchv1.JobSpec{Parallelism: ptr.To(parallelism)},
+	}
+}
+
+// newHarnessPod returns the new Pod of the owner, which is a ReplicaSet, a StatefulSet or a Job, named by the index.
+func newHarnessPod(ownerKind, ownerName string, i int) *corev1.Pod {
+	apiVersion := "apps/v1"
+	if ownerKind == "Job" {
+		apiVersion = "batch/v1"
+	}
+	name := fmt.Sprintf("%s-%d", ownerName, i)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: harnessNamespace,
+			Name:      name,
+			UID:       types.UID(name),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: apiVersion, Kind: ownerKind, Name: ownerName, UID: types.UID(ownerName), Controller: ptr.To(true)},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+// newHarnessPods returns the new Pods of the owner from the index to the index, exclusive.
+func newHarnessPods(ownerKind, ownerName string, from, to int) []*corev1.Pod {
+	pods := make([]*corev1.Pod, 0, to-from)
+	for i := from; i < to; i++ {
+		pods = append(pods, newHarnessPod(ownerKind, ownerName, i))
+	}
+	return pods
+}
+
+// newHarnessGenericPods returns the new Pods of a generic owner from the index to the index, exclusive.
+func newHarnessGenericPods(gvk schema.GroupVersionKind, ownerName string, from, to int) []*corev1.Pod {
+	pods := newHarnessPods(gvk.Kind, ownerName, from, to)
+	for _, pod := range pods {
+		pod.OwnerReferences[0].APIVersion = gvk.GroupVersion().String()
+	}
+	return pods
+}
+
+// withAffinity returns a copy of the Pod which has been labeled with the affinity, as if it was admitted.
+func withAffinity(pod *corev1.Pod, affinity podaffinity.PodAffinitySettingName) *corev1.Pod {
+	pod = pod.DeepCopy()
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[podaffinity.PodAffinityLabelKey] = string(affinity)
+	pod.Status.Phase = corev1.PodRunning
+	return pod
+}
+
+// withServerFields returns a copy of the object with the fields set by the API server and the controllers, such as
+// the managed fields, the last applied configuration, the containers and the conditions, which are stripped.
+func withServerFields(obj runtime.Object) runtime.Object {
+	obj = obj.DeepCopyObject()
+	objectMeta, err := meta.Accessor(obj)
+	if err != nil {
+		panic(err)
+	}
+	objectMeta.SetResourceVersion("1000000")
+	objectMeta.SetCreationTimestamp(metav1.Now())
+	objectMeta.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1",
+			FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: make([]byte, 2048)},
+		},
+		{
+			Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1",
+			FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: make([]byte, 1024)}, Subresource: "status",
+		},
+	})
+	annotations := map[string]string{
+		corev1.LastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"app"},"spec":{"containers":[{"name":"app","image":"registry.example.com/team/app:v1.2.3","ports":[{"containerPort":8080}]}]}}`,
+		"prometheus.io/scrape":             "true",
+	}
+	for key, value := range objectMeta.GetAnnotations() {
+		annotations[key] = value
+	}
+	objectMeta.SetAnnotations(annotations)
+
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  "app",
+				Image: "registry.example.com/team/app:v1.2.3",
+				Ports: []corev1.ContainerPort{{ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+				Env: []corev1.EnvVar{
+					{Name: "LOG_LEVEL", Value: "info"},
+					{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("100m"),
+						corev1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "kube-api-access", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount", ReadOnly: true},
+				},
+			},
+		},
+		Volumes: []corev1.Volume{
+			{Name: "kube-api-access", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: ptr.To[int64](3607)}},
+				},
+			}}},
+		},
+	}
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		o.Spec = podSpec
+		o.Spec.NodeName = "node"
+		o.Status.Conditions = []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+			{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		}
+		o.Status.PodIP = "10.0.0.1"
+		o.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "app", Ready: true, Image: "registry.example.com/team/app:v1.2.3", ContainerID: "containerd://" + string(o.UID)},
+		}
+	case *appsv1.Deployment:
+		o.Spec.Template.Spec = podSpec
+		o.Status.Replicas = ptr.Deref(o.Spec.Replicas, 1)
+	case *appsv1.ReplicaSet:
+		o.Spec.Template.Spec = podSpec
+		o.Status.Replicas = ptr.Deref(o.Spec.Replicas, 1)
+	case *appsv1.StatefulSet:
+		o.Spec.Template.Spec = podSpec
+		o.Status.Replicas = ptr.Deref(o.Spec.Replicas, 1)
+	case *batchv1.Job:
+		o.Spec.Template.Spec = podSpec
+		o.Status.Active = ptr.Deref(o.Spec.Parallelism, 1)
+	}
+	return obj
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/klog/v2"
+)
+
+// IsNamespaceManaged returns true if the objects in the namespace are handled by vmanager, with the current
+// configuration. The namespaces not in the cache yet are assumed to match the namespace selector, since the
+// API server has filtered the admission requests by the namespaceSelector of the webhooks already.
+func (wc *WebhookCache) IsNamespaceManaged(namespace string) bool {
+	activeConfig := wc.config.Get()
+	if activeConfig.IsNamespaceExcluded(namespace) {
+		return false
+	}
+	if activeConfig.NamespaceSelector == nil {
+		return true
+	}
+
+	ns, err := wc.namespaceInformer.Lister().Get(namespace)
+	if err != nil {
+		klog.V(5).Infof("Namespace %s is not in the cache, assume it matches the namespace selector: %v", namespace, err)
+		return true
+	}
+	return activeConfig.MatchesNamespaceSelector(ns.Labels)
+}
+
+// isInManagedNamespace filters the events of the objects in the namespaces which are not handled.
+func (wc *WebhookCache) isInManagedNamespace(obj interface{}) bool {
+	object, err := meta.Accessor(unwrapTombstone(obj))
+	if err != nil {
+		klog.Errorf("Cant get the namespace of %v: %v", obj, err)
+		return false
+	}
+	return wc.IsNamespaceManaged(object.GetNamespace())
+}
+
+// excludeNamespaces returns the tweak of the list options which excludes the objects of the namespaces,
+// the namespaces are fixed once the informers are started.
+func excludeNamespaces(namespaces []string) func(options *metav1.ListOptions) {
+	selectors := make([]fields.Selector, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", namespace))
+	}
+	fieldSelector := fields.AndSelectors(selectors...).String()
+
+	return func(options *metav1.ListOptions) {
+		options.FieldSelector = fieldSelector
+	}
+}
@@ -2,12 +2,23 @@ package cache
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 )
 
+// unwrapTombstone returns the last known state of the object if the informer missed its delete event.
+func unwrapTombstone(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		klog.V(3).Infof("Got the tombstone of %s, the delete event was missed.", tombstone.Key)
+		return tombstone.Obj
+	}
+	return obj
+}
+
 func convertToReplicaSet(obj interface{}) *appsv1.ReplicaSet {
-	replicaSet, ok := obj.(*appsv1.ReplicaSet)
+	replicaSet, ok := unwrapTombstone(obj).(*appsv1.ReplicaSet)
 	if !ok {
 		klog.Errorf("Cannot convert %v to *appsv1.ReplicaSet", obj)
 		return nil
@@ -17,7 +28,7 @@ func convertToReplicaSet(obj interface{}) *appsv1.ReplicaSet {
 }
 
 func convertToDeployment(obj interface{}) *appsv1.Deployment {
-	deployment, ok := obj.(*appsv1.Deployment)
+	deployment, ok := unwrapTombstone(obj).(*appsv1.Deployment)
 	if !ok {
 		klog.Errorf("Cant convert obj to *appsv1.Deployment: %v", obj)
 		return nil
@@ -27,7 +38,7 @@ func convertToDeployment(obj interface{}) *appsv1.Deployment {
 }
 
 func convertToStatefulSet(obj interface{}) *appsv1.StatefulSet {
-	statefulSet, ok := obj.(*appsv1.StatefulSet)
+	statefulSet, ok := unwrapTombstone(obj).(*appsv1.StatefulSet)
 	if !ok {
 		klog.Errorf("Cant convert obj to *appsv1.StatefulSet: %v", obj)
 		return nil
@@ -36,8 +47,18 @@ func convertToStatefulSet(obj interface{}) *appsv1.StatefulSet {
 	return statefulSet
 }
 
+func convertToJob(obj interface{}) *batchv1.Job {
+	job, ok := unwrapTombstone(obj).(*batchv1.Job)
+	if !ok {
+		klog.Errorf("Cant convert obj to *batchv1.Job: %v", obj)
+		return nil
+	}
+
+	return job
+}
+
 func convertToPod(obj interface{}) *corev1.Pod {
-	pod, ok := obj.(*corev1.Pod)
+	pod, ok := unwrapTombstone(obj).(*corev1.Pod)
 	if !ok {
 		klog.Errorf("Cant convert obj to *corev1.Pod: %v", obj)
 		return nil
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+// workloadReference identifies the source workload of the Pods, such as a ReplicaSet or a StatefulSet.
+type workloadReference struct {
+	Type string
+	Key  types.NamespacedName
+}
+
+// BiasToOnDemand places the new Pods of the source workload of the Pod on on-demand instead of spot
+// for the duration, it's used when the spot nodes are not available for the workload. A longer bias
+// of the workload is kept.
+func (wc *WebhookCache) BiasToOnDemand(pod *corev1.Pod, duration time.Duration) {
+	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+	if podSourceWorkloadType == "" || podSourceWorkloadKey == nil {
+		return
+	}
+
+	shard := wc.shardFor(podSourceWorkloadKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	reference := workloadReference{Type: podSourceWorkloadType, Key: *podSourceWorkloadKey}
+	until := wc.clock.Now().Add(duration)
+	if current, ok := shard.onDemandBias[reference]; ok && current.After(until) {
+		return
+	}
+	shard.onDemandBias[reference] = until
+
+	klog.V(2).Infof("Biased the new Pods of %s %v to on-demand until %v.", podSourceWorkloadType,
+		*podSourceWorkloadKey, until.Format(time.RFC3339))
+}
+
+// isBiasedToOnDemand returns true if the new Pods of the workload should be placed on on-demand instead of spot
+// at the time, the expired bias is removed. require mutex locked.
+func (shard *cacheShard) isBiasedToOnDemand(workloadType string, workloadKey types.NamespacedName, now time.Time) bool {
+	reference := workloadReference{Type: workloadType, Key: workloadKey}
+
+	until, ok := shard.onDemandBias[reference]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(shard.onDemandBias, reference)
+		return false
+	}
+	return true
+}
+
+// GetPodOptimizeSchedulingSetting returns a copy of the OptimizeSchedulingSetting of the source workload of the Pod,
+// or nil if the workload is not in the cache. The setting of a generic workload is resolved from its owner.
+func (wc *WebhookCache) GetPodOptimizeSchedulingSetting(ctx context.Context, pod *corev1.Pod) *apis.OptimizeSchedulingSetting {
+	podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+	if podSourceWorkloadType == "" || podSourceWorkloadKey == nil {
+		return nil
+	}
+
+	var genericOwnerRef *metav1.OwnerReference
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		genericOwnerRef = wc.getReplicaSetSourceGenericOwner(*podSourceWorkloadKey)
+	case "StatefulSet", "Job":
+		// The StatefulSets and Jobs are always cached.
+	default:
+		genericOwnerRef = metav1.GetControllerOf(pod)
+	}
+	if genericOwnerRef != nil {
+		return wc.resolveGenericOwnerSetting(ctx, podSourceWorkloadKey.Namespace, *genericOwnerRef)
+	}
+
+	shard := wc.shardFor(podSourceWorkloadKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	var setting *apis.OptimizeSchedulingSetting
+	switch podSourceWorkloadType {
+	case "ReplicaSet":
+		replicaSet, ok := shard.replicaSets[*podSourceWorkloadKey]
+		if !ok {
+			return nil
+		}
+		deploymentKey := utils.GetReplicaSetSourceDeploymentKey(replicaSet)
+		if deploymentKey == nil {
+			if metav1.GetControllerOf(replicaSet) != nil {
+				return nil
+			}
+			// The setting of a standalone ReplicaSet is always a new one.
+			return wc.getStandaloneReplicaSetSetting(replicaSet)
+		}
+		deploymentInfo, ok := shard.deployments[*deploymentKey]
+		if !ok {
+			return nil
+		}
+		setting = deploymentInfo.OptimizeSchedulingSetting
+	case "StatefulSet":
+		statefulSetInfo, ok := shard.statefulSets[*podSourceWorkloadKey]
+		if !ok {
+			return nil
+		}
+		setting = statefulSetInfo.OptimizeSchedulingSetting
+	case "Job":
+		jobInfo, ok := shard.jobs[*podSourceWorkloadKey]
+		if !ok {
+			return nil
+		}
+		setting = jobInfo.OptimizeSchedulingSetting
+	}
+
+	if setting == nil {
+		return nil
+	}
+	settingCopy := *setting
+	return &settingCopy
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+func TestBiasToOnDemandKeepsLongerBias(t *testing.T) {
+	h := newTestHarness(t, nil)
+	statefulSet := newHarnessStatefulSet("db", 2, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	h.add(statefulSet)
+	pod := newHarnessPods("StatefulSet", statefulSet.Name, 0, 1)[0]
+
+	h.cache.BiasToOnDemand(pod, time.Hour)
+	h.cache.BiasToOnDemand(pod, time.Minute)
+	h.clock.Step(2 * time.Minute)
+
+	expectAffinities(t, h.admitAll(pod), onDemand)
+}
+
+func TestBiasToOnDemandExpires(t *testing.T) {
+	h := newTestHarness(t, nil)
+	statefulSet := newHarnessStatefulSet("db", 2, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	h.add(statefulSet)
+	pods := newHarnessPods("StatefulSet", statefulSet.Name, 0, 2)
+
+	h.cache.BiasToOnDemand(pods[0], time.Minute)
+	expectAffinities(t, h.admitAll(pods[0]), onDemand)
+
+	h.clock.Step(2 * time.Minute)
+	expectAffinities(t, h.admitAll(pods[1]), spot)
+}
@@ -0,0 +1,193 @@
+package owner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+const (
+	// maxOwnerDepth limits the walking of the ownerReference chain, in case of a cycle.
+	maxOwnerDepth = 5
+
+	// resolvedOwnerTTL is how long a resolved owner is reused, since the owners of a workload are rarely changed
+	// and many Pods of the same workload are created in a short time.
+	resolvedOwnerTTL = 30 * time.Second
+)
+
+// ScalableOwner is the top-level owner of a Pod or a ReplicaSet, such as an Argo Rollout or an OpenKruise CloneSet.
+type ScalableOwner struct {
+	GroupVersionKind schema.GroupVersionKind
+	Key              types.NamespacedName
+	Labels           map[string]string
+	Annotations      map[string]string
+	// Replicas is read from the scale subresource of the owner, it's 0 if the owner doesn't enable
+	// the optimize scheduling, whose scale is not read.
+	Replicas int
+}
+
+type Resolver interface {
+	// Resolve walks the ownerReference chain from the ownerRef up to the top-level controller,
+	// and reads its replicas from the scale subresource if it enables the optimize scheduling.
+	Resolve(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (*ScalableOwner, error)
+}
+
+// Check if the resolver implements necessary func.
+var _ Resolver = &resolver{}
+
+type resolver struct {
+	metadataClient metadata.Interface
+	dynamicClient  dynamic.Interface
+	restMapper     meta.RESTMapper
+
+	mutex    sync.Mutex
+	resolved map[resolvedOwnerKey]*resolvedOwner
+}
+
+type resolvedOwnerKey struct {
+	namespace string
+	uid       types.UID
+}
+
+type resolvedOwner struct {
+	owner      *ScalableOwner
+	err        error
+	resolvedAt time.Time
+}
+
+func NewResolver(kubeConfig *rest.Config) (Resolver, error) {
+	metadataClient, err := metadata.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResolverForClients(metadataClient, dynamicClient,
+		restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))), nil
+}
+
+func NewResolverForClients(metadataClient metadata.Interface, dynamicClient dynamic.Interface,
+	restMapper meta.RESTMapper) Resolver {
+
+	return &resolver{
+		metadataClient: metadataClient,
+		dynamicClient:  dynamicClient,
+		restMapper:     restMapper,
+		resolved:       map[resolvedOwnerKey]*resolvedOwner{},
+	}
+}
+
+func (r *resolver) Resolve(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (*ScalableOwner, error) {
+	key := resolvedOwnerKey{namespace: namespace, uid: ownerRef.UID}
+
+	r.mutex.Lock()
+	cached, ok := r.resolved[key]
+	r.mutex.Unlock()
+	if ok && time.Since(cached.resolvedAt) < resolvedOwnerTTL {
+		return cached.owner, cached.err
+	}
+
+	owner, err := r.resolve(ctx, namespace, ownerRef)
+	// Don't remember the interrupted lookups, they say nothing about the owner.
+	if ctx.Err() == nil {
+		r.mutex.Lock()
+		r.resolved[key] = &resolvedOwner{owner: owner, err: err, resolvedAt: time.Now()}
+		r.cleanExpired()
+		r.mutex.Unlock()
+	}
+	return owner, err
+}
+
+func (r *resolver) resolve(ctx context.Context, namespace string, ownerRef metav1.OwnerReference) (*ScalableOwner, error) {
+	var mapping *meta.RESTMapping
+	var objectMeta *metav1.PartialObjectMetadata
+
+	// Walk up until the object has no controller.
+	for depth := 0; ; depth++ {
+		if depth >= maxOwnerDepth {
+			return nil, fmt.Errorf("the ownerReference chain of %s/%s is too deep", namespace, ownerRef.Name)
+		}
+
+		gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+		if err != nil {
+			return nil, err
+		}
+		mapping, err = r.restMapper.RESTMapping(gv.WithKind(ownerRef.Kind).GroupKind(), gv.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		objectMeta, err = r.metadataClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx, ownerRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		controllerRef := metav1.GetControllerOf(objectMeta)
+		if controllerRef == nil {
+			break
+		}
+		ownerRef = *controllerRef
+	}
+
+	owner := &ScalableOwner{
+		GroupVersionKind: mapping.GroupVersionKind,
+		Key:              types.NamespacedName{Namespace: namespace, Name: objectMeta.Name},
+		Labels:           objectMeta.Labels,
+		Annotations:      objectMeta.Annotations,
+	}
+	configuration := optimizescheduling.GetOptimizeSchedulingConfiguration(objectMeta.Labels, objectMeta.Annotations)
+	if configuration[optimizescheduling.OptimizeSchedulingKey] != "true" {
+		klog.V(5).Infof("The scalable owner %s %s/%s didnt enable optimize scheduling.", mapping.GroupVersionKind.Kind,
+			namespace, objectMeta.Name)
+		return owner, nil
+	}
+
+	scale, err := r.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx, objectMeta.Name,
+		metav1.GetOptions{}, "scale")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the scale of %s %s/%s: %v", mapping.GroupVersionKind.Kind,
+			namespace, objectMeta.Name, err)
+	}
+	replicas, _, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if err != nil {
+		return nil, err
+	}
+
+	klog.V(5).Infof("Resolved the scalable owner %s %s/%s with %d replicas.", mapping.GroupVersionKind.Kind,
+		namespace, objectMeta.Name, replicas)
+
+	owner.Replicas = int(replicas)
+	return owner, nil
+}
+
+// cleanExpired removes the expired owners, require mutex locked.
+func (r *resolver) cleanExpired() {
+	for key, cached := range r.resolved {
+		if time.Since(cached.resolvedAt) >= resolvedOwnerTTL {
+			delete(r.resolved, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package owner
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+var cloneSetGVK = schema.GroupVersionKind{Group: "apps.kruise.io", Version: "v1alpha1", Kind: "CloneSet"}
+
+// newTestResolver returns the resolver of the CloneSets, and the number of the scale requests.
+func newTestResolver(objs ...runtime.Object) (Resolver, *int) {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(cloneSetGVK, meta.RESTScopeNamespace)
+
+	scaleGets := 0
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("get", "clonesets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		scaleGets++
+		return true, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"spec":       map[string]interface{}{"replicas": int64(4)},
+		}}, nil
+	})
+
+	scheme := metadatafake.NewTestScheme()
+	scheme.AddKnownTypeWithName(cloneSetGVK, &metav1.PartialObjectMetadata{})
+	return NewResolverForClients(metadatafake.NewSimpleMetadataClient(scheme, objs...), dynamicClient, restMapper),
+		&scaleGets
+}
+
+func newTestCloneSet(name string, labels map[string]string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: cloneSetGVK.GroupVersion().String(), Kind: cloneSetGVK.Kind},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name), Labels: labels},
+	}
+}
+
+func newTestOwnerRef(name string) metav1.OwnerReference {
+	return metav1.OwnerReference{APIVersion: cloneSetGVK.GroupVersion().String(), Kind: cloneSetGVK.Kind,
+		Name: name, UID: types.UID("uid-" + name)}
+}
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		expectReplicas  int
+		expectScaleGets int
+	}{
+		{name: "opted in", labels: map[string]string{optimizescheduling.OptimizeSchedulingKey: "true"},
+			expectReplicas: 4, expectScaleGets: 1},
+		{name: "opted out", labels: map[string]string{optimizescheduling.OptimizeSchedulingKey: "false"}},
+		{name: "not labeled"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, scaleGets := newTestResolver(newTestCloneSet("web", tc.labels))
+
+			owner, err := r.Resolve(context.Background(), "default", newTestOwnerRef("web"))
+			if err != nil {
+				t.Fatalf("failed to resolve the owner: %v", err)
+			}
+			if owner.Replicas != tc.expectReplicas || *scaleGets != tc.expectScaleGets {
+				t.Errorf("expect %d replicas by %d scale requests, got %d replicas by %d scale requests",
+					tc.expectReplicas, tc.expectScaleGets, owner.Replicas, *scaleGets)
+			}
+		})
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	r, _ := newTestResolver()
+
+	_, err := r.Resolve(context.Background(), "default", newTestOwnerRef("missing"))
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expect NotFound, got %v", err)
+	}
+}
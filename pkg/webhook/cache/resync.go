@@ -0,0 +1,131 @@
+package cache
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+// resyncWorkloadSchedulingInfo rebuilds the WorkloadSchedulingInfo of all the workloads from the pod lister,
+// so that the counts drifted by the missed or misordered events are corrected. The objects fetched from the API server
+// which the informers never receive are removed before.
+func (wc *WebhookCache) resyncWorkloadSchedulingInfo() {
+	// Hold the mutexes of all the shards while listing, the pod events which are already in the lister
+	// will be handled after the rebuilding, and they are idempotent.
+	wc.lockAllShards()
+	defer wc.unlockAllShards()
+
+	pods, err := wc.podInformer.Lister().List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pods to resync the workload scheduling info: %v", err)
+		return
+	}
+
+	podsByShard := map[*cacheShard][]*corev1.Pod{}
+	for _, pod := range pods {
+		if !wc.IsNamespaceManaged(pod.Namespace) {
+			continue
+		}
+		shard := wc.shardFor(pod.Namespace)
+		podsByShard[shard] = append(podsByShard[shard], pod)
+	}
+
+	replicaSetNum, statefulSetNum, jobNum, genericNum := 0, 0, 0, 0
+	for _, shard := range wc.shards {
+		wc.expireFetchedObjects(shard)
+		shard.resyncWorkloadSchedulingInfo(podsByShard[shard])
+
+		replicaSetNum += len(shard.replicaSetWorkloadSchedulingInfo)
+		statefulSetNum += len(shard.statefulSetWorkloadSchedulingInfo)
+		jobNum += len(shard.jobWorkloadSchedulingInfo)
+		genericNum += len(shard.genericWorkloadSchedulingInfo)
+	}
+
+	klog.V(3).Infof("Resynced the scheduling info of %d ReplicaSets, %d StatefulSets, %d Jobs and %d generic workloads from %d pods.",
+		replicaSetNum, statefulSetNum, jobNum, genericNum, len(pods))
+}
+
+// resyncWorkloadSchedulingInfo rebuilds the WorkloadSchedulingInfo of the shard from its Pods. require mutex locked.
+func (shard *cacheShard) resyncWorkloadSchedulingInfo(pods []*corev1.Pod) {
+	replicaSetWorkloadSchedulingInfo := map[types.NamespacedName]*apis.WorkloadSchedulingInfo{}
+	for replicaSetKey := range shard.replicaSets {
+		replicaSetWorkloadSchedulingInfo[replicaSetKey] = apis.NewWorkloadSchedulingInfo()
+	}
+	statefulSetWorkloadSchedulingInfo := map[types.NamespacedName]*apis.WorkloadSchedulingInfo{}
+	for statefulSetKey := range shard.statefulSets {
+		statefulSetWorkloadSchedulingInfo[statefulSetKey] = apis.NewWorkloadSchedulingInfo()
+	}
+	jobWorkloadSchedulingInfo := map[types.NamespacedName]*apis.WorkloadSchedulingInfo{}
+	for jobKey := range shard.jobs {
+		jobWorkloadSchedulingInfo[jobKey] = apis.NewWorkloadSchedulingInfo()
+	}
+	// The generic workloads are not watched, their info only lives as long as their Pods.
+	genericWorkloadSchedulingInfo := map[workloadReference]*apis.WorkloadSchedulingInfo{}
+
+	for _, pod := range pods {
+		podSourceWorkloadType, podSourceWorkloadKey := utils.GetPodSourceWorkloadTypeAndKey(pod)
+		if podSourceWorkloadType == "" || podSourceWorkloadKey == nil || !utils.IsPodLive(pod) {
+			continue
+		}
+
+		podKey := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+		var workloadSchedulingInfo map[types.NamespacedName]*apis.WorkloadSchedulingInfo
+		switch podSourceWorkloadType {
+		case "ReplicaSet":
+			workloadSchedulingInfo = replicaSetWorkloadSchedulingInfo
+		case "StatefulSet":
+			workloadSchedulingInfo = statefulSetWorkloadSchedulingInfo
+		case "Job":
+			workloadSchedulingInfo = jobWorkloadSchedulingInfo
+		default:
+			reference := workloadReference{Type: podSourceWorkloadType, Key: *podSourceWorkloadKey}
+			if genericWorkloadSchedulingInfo[reference] == nil {
+				genericWorkloadSchedulingInfo[reference] = apis.NewWorkloadSchedulingInfo()
+			}
+			genericWorkloadSchedulingInfo[reference].AddPod(podKey, utils.GetPodAffinitySetting(pod), utils.GetPodDryRunAffinitySetting(pod))
+			continue
+		}
+
+		if workloadSchedulingInfo[*podSourceWorkloadKey] == nil {
+			workloadSchedulingInfo[*podSourceWorkloadKey] = apis.NewWorkloadSchedulingInfo()
+		}
+		workloadSchedulingInfo[*podSourceWorkloadKey].AddPod(podKey, utils.GetPodAffinitySetting(pod), utils.GetPodDryRunAffinitySetting(pod))
+	}
+
+	logSchedulingInfoDrift("ReplicaSet", shard.replicaSetWorkloadSchedulingInfo, replicaSetWorkloadSchedulingInfo)
+	logSchedulingInfoDrift("StatefulSet", shard.statefulSetWorkloadSchedulingInfo, statefulSetWorkloadSchedulingInfo)
+	logSchedulingInfoDrift("Job", shard.jobWorkloadSchedulingInfo, jobWorkloadSchedulingInfo)
+
+	shard.replicaSetWorkloadSchedulingInfo = replicaSetWorkloadSchedulingInfo
+	shard.statefulSetWorkloadSchedulingInfo = statefulSetWorkloadSchedulingInfo
+	shard.jobWorkloadSchedulingInfo = jobWorkloadSchedulingInfo
+	shard.genericWorkloadSchedulingInfo = genericWorkloadSchedulingInfo
+}
+
+// logSchedulingInfoDrift reports the workloads whose counts are different after the rebuilding.
+func logSchedulingInfoDrift(workloadType string, current, rebuilt map[types.NamespacedName]*apis.WorkloadSchedulingInfo) {
+	for workloadKey, rebuiltInfo := range rebuilt {
+		currentInfo := current[workloadKey]
+		if currentInfo == nil {
+			currentInfo = apis.NewWorkloadSchedulingInfo()
+		}
+
+		if currentInfo.OnDemandReplicaCount != rebuiltInfo.OnDemandReplicaCount ||
+			currentInfo.SpotReplicaCount != rebuiltInfo.SpotReplicaCount {
+			klog.Warningf("Corrected the drifted scheduling info of %s %v, on-demand: %d -> %d, spot: %d -> %d.",
+				workloadType, workloadKey, currentInfo.OnDemandReplicaCount, rebuiltInfo.OnDemandReplicaCount,
+				currentInfo.SpotReplicaCount, rebuiltInfo.SpotReplicaCount)
+		}
+	}
+
+	for workloadKey, currentInfo := range current {
+		if _, ok := rebuilt[workloadKey]; !ok && (currentInfo.OnDemandReplicaCount != 0 || currentInfo.SpotReplicaCount != 0) {
+			klog.Warningf("Removed the stale scheduling info of %s %v, on-demand: %d, spot: %d.",
+				workloadType, workloadKey, currentInfo.OnDemandReplicaCount, currentInfo.SpotReplicaCount)
+		}
+	}
+}
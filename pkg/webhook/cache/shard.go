@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// shardNum is the number of the shards of the WebhookCache.
+const shardNum = 64
+
+// cacheShard holds the cached objects of the namespaces hashed to it. A Pod, its source workload and their owners
+// are always in the same namespace, so an event or a lookup only locks one shard, and the ones of the other
+// namespaces don't contend with it.
+type cacheShard struct {
+	mutex sync.Mutex
+
+	replicaSets  map[types.NamespacedName]*appsv1.ReplicaSet
+	deployments  map[types.NamespacedName]*apis.DeploymentInfo
+	statefulSets map[types.NamespacedName]*apis.StatefulSetInfo
+	jobs         map[types.NamespacedName]*apis.JobInfo
+
+	replicaSetWorkloadSchedulingInfo  map[types.NamespacedName]*apis.WorkloadSchedulingInfo
+	statefulSetWorkloadSchedulingInfo map[types.NamespacedName]*apis.WorkloadSchedulingInfo
+	jobWorkloadSchedulingInfo         map[types.NamespacedName]*apis.WorkloadSchedulingInfo
+	// genericWorkloadSchedulingInfo holds the Pods controlled directly by the workloads other than
+	// ReplicaSet and StatefulSet, such as OpenKruise CloneSets.
+	genericWorkloadSchedulingInfo map[workloadReference]*apis.WorkloadSchedulingInfo
+
+	// strategyChanges records since when the strategies of the Deployments and StatefulSets have been changed,
+	// until their existing Pods are rebalanced to the new target.
+	strategyChanges map[workloadReference]time.Time
+
+	// lastDecisionTimes records when the placement of a new Pod of the source workloads was determined last time.
+	lastDecisionTimes map[workloadReference]time.Time
+
+	// onDemandBias records until when the new Pods of the workloads should be placed on on-demand instead of spot.
+	onDemandBias map[workloadReference]time.Time
+
+	// fetchedObjects records when the workloads missing in the cache were fetched from the API server,
+	// until their informers receive them.
+	fetchedObjects map[workloadReference]time.Time
+}
+
+func newCacheShard() *cacheShard {
+	return &cacheShard{
+		replicaSets:  map[types.NamespacedName]*appsv1.ReplicaSet{},
+		deployments:  map[types.NamespacedName]*apis.DeploymentInfo{},
+		statefulSets: map[types.NamespacedName]*apis.StatefulSetInfo{},
+		jobs:         map[types.NamespacedName]*apis.JobInfo{},
+
+		replicaSetWorkloadSchedulingInfo:  map[types.NamespacedName]*apis.WorkloadSchedulingInfo{},
+		statefulSetWorkloadSchedulingInfo: map[types.NamespacedName]*apis.WorkloadSchedulingInfo{},
+		jobWorkloadSchedulingInfo:         map[types.NamespacedName]*apis.WorkloadSchedulingInfo{},
+		genericWorkloadSchedulingInfo:     map[workloadReference]*apis.WorkloadSchedulingInfo{},
+
+		strategyChanges:   map[workloadReference]time.Time{},
+		lastDecisionTimes: map[workloadReference]time.Time{},
+		onDemandBias:      map[workloadReference]time.Time{},
+
+		fetchedObjects: map[workloadReference]time.Time{},
+	}
+}
+
+func newCacheShards(num int) []*cacheShard {
+	shards := make([]*cacheShard, num)
+	for i := range shards {
+		shards[i] = newCacheShard()
+	}
+	return shards
+}
+
+// shardFor returns the shard of the objects in the namespace.
+func (wc *WebhookCache) shardFor(namespace string) *cacheShard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(namespace))
+	return wc.shards[hash.Sum32()%uint32(len(wc.shards))]
+}
+
+// lockAllShards locks all the shards in order, it's only used when the whole cache is rebuilt.
+func (wc *WebhookCache) lockAllShards() {
+	for _, shard := range wc.shards {
+		shard.mutex.Lock()
+	}
+}
+
+func (wc *WebhookCache) unlockAllShards() {
+	for i := len(wc.shards) - 1; i >= 0; i-- {
+		wc.shards[i].mutex.Unlock()
+	}
+}
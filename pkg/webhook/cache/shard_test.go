@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+const (
+	// benchmarkNamespaceNum is the number of the namespaces, each has a Deployment.
+	benchmarkNamespaceNum = 200
+	// benchmarkReplicas is the number of the Pods of each Deployment.
+	benchmarkReplicas = 20
+	// benchmarkChurnWorkers is the number of the goroutines which keep creating, updating and deleting Pods.
+	benchmarkChurnWorkers = 8
+)
+
+// newBenchmarkReplicaSetPod returns a running Pod of the ReplicaSet app-1 in the namespace.
+func newBenchmarkReplicaSetPod(namespace, name string, affinity podaffinity.PodAffinitySettingName) *corev1.Pod {
+	pod := withAffinity(newHarnessPod("ReplicaSet", "app-1", 0), affinity)
+	pod.Namespace, pod.Name = namespace, name
+	return pod
+}
+
+// newBenchmarkCache returns a cache of the shards with a Deployment of running Pods in each namespace.
+func newBenchmarkCache(shards int) *WebhookCache {
+	wc := &WebhookCache{
+		config: config.NewHolder(config.NewDefaultConfiguration()),
+		shards: newCacheShards(shards),
+		clock:  clock.RealClock{},
+	}
+
+	for i := 0; i < benchmarkNamespaceNum; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i)
+		deployment := newHarnessDeployment("app", benchmarkReplicas, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+		deployment.Namespace = namespace
+		replicaSet := newHarnessReplicaSet(deployment, "1", benchmarkReplicas)
+		replicaSet.Namespace = namespace
+		wc.addDeployment(deployment)
+		wc.addReplicaSet(replicaSet)
+		for j := 0; j < benchmarkReplicas; j++ {
+			affinity := podaffinity.PodAffinitySpot
+			if j%2 == 0 {
+				affinity = podaffinity.PodAffinityOnDemand
+			}
+			wc.addPod(newBenchmarkReplicaSetPod(namespace, fmt.Sprintf("app-1-%d", j), affinity))
+		}
+	}
+	return wc
+}
+
+// churnPods creates, updates and deletes the Pods of all the namespaces until the ctx is done.
+func churnPods(ctx context.Context, wc *WebhookCache, worker int) {
+	for i := 0; ctx.Err() == nil; i++ {
+		namespace := fmt.Sprintf("namespace-%d", (i*benchmarkChurnWorkers+worker)%benchmarkNamespaceNum)
+		pod := newBenchmarkReplicaSetPod(namespace, fmt.Sprintf("churn-%d-%d", worker, i), podaffinity.PodAffinitySpot)
+		wc.addPod(pod)
+
+		terminatingPod := pod.DeepCopy()
+		terminatingPod.DeletionTimestamp = ptr.To(metav1.Now())
+		wc.updatePod(pod, terminatingPod)
+		wc.deletePod(terminatingPod)
+	}
+}
+
+func benchmarkAdmissionUnderPodChurn(b *testing.B, shards int) {
+	wc := newBenchmarkCache(shards)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var churn sync.WaitGroup
+	for worker := 0; worker < benchmarkChurnWorkers; worker++ {
+		churn.Add(1)
+		go func(worker int) {
+			defer churn.Done()
+			churnPods(ctx, wc, worker)
+		}(worker)
+	}
+
+	var mutex sync.Mutex
+	var latencies []time.Duration
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var local []time.Duration
+		for i := 0; pb.Next(); i++ {
+			namespace := fmt.Sprintf("namespace-%d", i%benchmarkNamespaceNum)
+			pod := newBenchmarkReplicaSetPod(namespace, "", podaffinity.PodAffinityUnset)
+
+			start := time.Now()
+			if affinity, _ := wc.DetermineNewPodAffinityPreference(context.Background(), pod); affinity == podaffinity.PodAffinityUnset {
+				b.Error("expect the new pod to be placed")
+			}
+			local = append(local, time.Since(start))
+		}
+
+		mutex.Lock()
+		latencies = append(latencies, local...)
+		mutex.Unlock()
+	})
+	b.StopTimer()
+
+	cancel()
+	churn.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
+
+// BenchmarkAdmissionUnderPodChurn measures the latency of the admission lookups while the Pods of all the
+// namespaces keep changing, with a single shard like a global mutex, and with the default shards, run it by
+// go test ./pkg/webhook/cache -run '^$' -bench AdmissionUnderPodChurn -cpu 8
+func BenchmarkAdmissionUnderPodChurn(b *testing.B) {
+	for _, shards := range []int{1, shardNum} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkAdmissionUnderPodChurn(b, shards)
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package cache
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+const (
+	onDemand = podaffinity.PodAffinityOnDemand
+	spot     = podaffinity.PodAffinitySpot
+	unset    = podaffinity.PodAffinityUnset
+)
+
+// strategyTestCase is the expected placement of 5 replicas by a strategy.
+type strategyTestCase struct {
+	customOnDemand int
+	onDemand       int
+	spot           int
+}
+
+var strategyTestCases = map[string]strategyTestCase{
+	optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand:      {onDemand: 5, spot: 0},
+	optimizescheduling.OptimizeSchedulingStrategyAllInSpot:          {onDemand: 0, spot: 5},
+	optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand: {onDemand: 3, spot: 2},
+	optimizescheduling.OptimizeSchedulingStrategyCustom:             {customOnDemand: 2, onDemand: 2, spot: 3},
+}
+
+// expectedPlacement returns the affinities of the Pods admitted in order, the on-demand ones come first,
+// and the Pods beyond the target are unset.
+func expectedPlacement(onDemandNum, spotNum, extraNum int) []podaffinity.PodAffinitySettingName {
+	expect := repeatAffinity(onDemand, onDemandNum)
+	expect = append(expect, repeatAffinity(spot, spotNum)...)
+	return append(expect, repeatAffinity(unset, extraNum)...)
+}
+
+func TestStrategies(t *testing.T) {
+	for _, strategy := range optimizescheduling.OptimizeSchedulingStrategies.List() {
+		tc, ok := strategyTestCases[strategy]
+		if !ok {
+			t.Errorf("strategy %s has no test case", strategy)
+			continue
+		}
+
+		t.Run(strategy+"/Deployment", func(t *testing.T) {
+			h := newTestHarness(t, nil)
+			deployment := newHarnessDeployment("web", 5, strategy, tc.customOnDemand)
+			replicaSet := newHarnessReplicaSet(deployment, "v1", 5)
+			h.add(deployment)
+			h.add(replicaSet)
+
+			got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 6)...)
+			expectAffinities(t, got, expectedPlacement(tc.onDemand, tc.spot, 1)...)
+			h.expectObserved("Deployment", deployment.Name, tc.onDemand, tc.spot)
+		})
+
+		t.Run(strategy+"/StatefulSet", func(t *testing.T) {
+			h := newTestHarness(t, nil)
+			statefulSet := newHarnessStatefulSet("db", 5, strategy, tc.customOnDemand)
+			h.add(statefulSet)
+
+			got := h.admitAll(newHarnessPods("StatefulSet", statefulSet.Name, 0, 6)...)
+			expectAffinities(t, got, expectedPlacement(tc.onDemand, tc.spot, 1)...)
+			h.expectObserved("StatefulSet", statefulSet.Name, tc.onDemand, tc.spot)
+		})
+
+		t.Run(strategy+"/Job", func(t *testing.T) {
+			h := newTestHarness(t, nil)
+			job := newHarnessJob("batch", 5, strategy, tc.customOnDemand)
+			h.add(job)
+
+			got := h.admitAll(newHarnessPods("Job", job.Name, 0, 6)...)
+			expectAffinities(t, got, expectedPlacement(tc.onDemand, tc.spot, 1)...)
+			h.expectObserved("Job", job.Name, tc.onDemand, tc.spot)
+		})
+
+		// The StatefulSets of the other groups are resolved as generic owners instead of waiting for the cache.
+		t.Run(strategy+"/KruiseStatefulSet", func(t *testing.T) {
+			h := newTestHarness(t, nil)
+			h.setGenericOwner(kruiseStatefulSetGVK, newHarnessWorkloadMeta("db", strategy, tc.customOnDemand), 5)
+
+			got := h.admitAll(newHarnessGenericPods(kruiseStatefulSetGVK, "db", 0, 6)...)
+			expectAffinities(t, got, expectedPlacement(tc.onDemand, tc.spot, 1)...)
+		})
+	}
+}
+
+func TestDisabledWorkload(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 3, "", 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	h.add(deployment)
+	h.add(replicaSet)
+
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 3)...)
+	expectAffinities(t, got, unset, unset, unset)
+}
+
+func TestScaleUp(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 4, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 4)
+	h.add(deployment)
+	h.add(replicaSet)
+
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 4)...)
+	expectAffinities(t, got, onDemand, onDemand, onDemand, spot)
+
+	// The target of 8 replicas is 5 on-demand and 3 spot.
+	deployment.Spec.Replicas = ptr.To[int32](8)
+	replicaSet.Spec.Replicas = ptr.To[int32](8)
+	h.update(deployment)
+	h.update(replicaSet)
+
+	got = h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 4, 8)...)
+	expectAffinities(t, got, onDemand, onDemand, spot, spot)
+	h.expectObserved("Deployment", deployment.Name, 5, 3)
+}
+
+func TestScaleDown(t *testing.T) {
+	h := newTestHarness(t, nil)
+	statefulSet := newHarnessStatefulSet("db", 6, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	h.add(statefulSet)
+	pods := newHarnessPods("StatefulSet", statefulSet.Name, 0, 6)
+	h.admitAll(pods...)
+
+	statefulSet.Spec.Replicas = ptr.To[int32](3)
+	h.update(statefulSet)
+	for _, pod := range pods[3:] {
+		h.delete(h.pod(pod.Name))
+	}
+	h.expectObserved("StatefulSet", statefulSet.Name, 0, 3)
+
+	// The workload is at its target, a Pod beyond the replicas is not placed.
+	got := h.admitAll(newHarnessPods("StatefulSet", statefulSet.Name, 3, 4)...)
+	expectAffinities(t, got, unset)
+}
+
+func TestReplaceDeletedPod(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 5, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 5)
+	h.add(deployment)
+	h.add(replicaSet)
+	pods := newHarnessPods("ReplicaSet", replicaSet.Name, 0, 5)
+	h.admitAll(pods...)
+
+	// A deleted spot Pod is replaced on spot.
+	h.delete(h.pod(pods[4].Name))
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 5, 6)...)
+	expectAffinities(t, got, spot)
+
+	// A terminating on-demand Pod is not counted, its replacement is on-demand.
+	terminating := h.pod(pods[0].Name).DeepCopy()
+	terminating.DeletionTimestamp = ptr.To(metav1.Now())
+	h.update(terminating)
+	got = h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 6, 7)...)
+	expectAffinities(t, got, onDemand)
+
+	h.delete(terminating)
+	h.expectObserved("Deployment", deployment.Name, 3, 2)
+}
+
+func TestReplaceAllDeletedPods(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 2, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 2)
+	h.add(deployment)
+	h.add(replicaSet)
+	statefulSet := newHarnessStatefulSet("db", 2, optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand, 0)
+	h.add(statefulSet)
+	replicaSetPods := newHarnessPods("ReplicaSet", replicaSet.Name, 0, 2)
+	statefulSetPods := newHarnessPods("StatefulSet", statefulSet.Name, 0, 2)
+	h.admitAll(append(replicaSetPods, statefulSetPods...)...)
+
+	// The workloads are still cached after all their Pods are deleted, the replacements are placed.
+	for _, pod := range append(replicaSetPods, statefulSetPods...) {
+		h.delete(h.pod(pod.Name))
+	}
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 2, 4)...)
+	expectAffinities(t, got, spot, spot)
+	got = h.admitAll(statefulSetPods...)
+	expectAffinities(t, got, onDemand, onDemand)
+
+	// The scheduling info is cleared once both the workload and its Pods are deleted.
+	for _, pod := range newHarnessPods("ReplicaSet", replicaSet.Name, 2, 4) {
+		h.delete(h.pod(pod.Name))
+	}
+	h.delete(replicaSet)
+	shard := h.cache.shardFor(harnessNamespace)
+	if _, ok := shard.replicaSetWorkloadSchedulingInfo[types.NamespacedName{Namespace: harnessNamespace, Name: replicaSet.Name}]; ok {
+		t.Errorf("expect the scheduling info of the deleted ReplicaSet to be cleared")
+	}
+}
+
+func TestDeploymentRollout(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 4, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	oldReplicaSet := newHarnessReplicaSet(deployment, "v1", 4)
+	h.add(deployment)
+	h.add(oldReplicaSet)
+	oldPods := newHarnessPods("ReplicaSet", oldReplicaSet.Name, 0, 4)
+	h.admitAll(oldPods...)
+
+	// Roll out one Pod at a time, the Pods of the new ReplicaSet are placed by its own Pods against the target
+	// of the Deployment, so the new ReplicaSet ends with the same placement.
+	newReplicaSet := newHarnessReplicaSet(deployment, "v2", 0)
+	h.add(newReplicaSet)
+	newPods := newHarnessPods("ReplicaSet", newReplicaSet.Name, 0, 4)
+
+	var got []podaffinity.PodAffinitySettingName
+	for i := range newPods {
+		newReplicaSet.Spec.Replicas = ptr.To(int32(i + 1))
+		h.update(newReplicaSet)
+		got = append(got, h.admit(newPods[i]))
+
+		oldReplicaSet.Spec.Replicas = ptr.To(int32(3 - i))
+		h.update(oldReplicaSet)
+		h.delete(h.pod(oldPods[len(oldPods)-1-i].Name))
+	}
+	expectAffinities(t, got, onDemand, onDemand, onDemand, spot)
+	h.expectObserved("Deployment", deployment.Name, 3, 1)
+
+	h.delete(oldReplicaSet)
+	h.expectObserved("Deployment", deployment.Name, 3, 1)
+}
+
+func TestStatefulSetRollout(t *testing.T) {
+	h := newTestHarness(t, nil)
+	statefulSet := newHarnessStatefulSet("db", 4, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	h.add(statefulSet)
+	pods := newHarnessPods("StatefulSet", statefulSet.Name, 0, 4)
+	got := h.admitAll(pods...)
+	expectAffinities(t, got, onDemand, onDemand, onDemand, spot)
+
+	// The StatefulSet controller recreates the Pods from the highest ordinal, each one takes the slot of the
+	// Pod it replaces.
+	got = nil
+	for i := len(pods) - 1; i >= 0; i-- {
+		h.delete(h.pod(pods[i].Name))
+		got = append(got, h.admit(pods[i]))
+	}
+	expectAffinities(t, got, spot, onDemand, onDemand, onDemand)
+	h.expectObserved("StatefulSet", statefulSet.Name, 3, 1)
+}
+
+func TestStrategyChange(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	oldReplicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	h.add(deployment)
+	h.add(oldReplicaSet)
+	oldPods := newHarnessPods("ReplicaSet", oldReplicaSet.Name, 0, 3)
+	h.admitAll(oldPods...)
+
+	deployment.Labels[optimizescheduling.OptimizeSchedulingStrategyKey] = optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand
+	h.update(deployment)
+
+	deviations := h.cache.ListStrategyChangeDeviations()
+	if len(deviations) != 1 || deviations[0].OnDemandDelta() != 3 || deviations[0].SpotDelta() != -3 {
+		t.Fatalf("expect the Deployment to miss 3 on-demand Pods and have 3 extra spot Pods, got %+v", deviations)
+	}
+
+	// The strategy change rolls out a new ReplicaSet.
+	newReplicaSet := newHarnessReplicaSet(deployment, "v2", 3)
+	h.add(newReplicaSet)
+	got := h.admitAll(newHarnessPods("ReplicaSet", newReplicaSet.Name, 0, 3)...)
+	expectAffinities(t, got, onDemand, onDemand, onDemand)
+	for _, pod := range oldPods {
+		h.delete(h.pod(pod.Name))
+	}
+	h.delete(oldReplicaSet)
+
+	// The satisfied deviation is reported until it's forgotten.
+	deviations = h.cache.ListStrategyChangeDeviations()
+	if len(deviations) != 1 || !deviations[0].Satisfied() {
+		t.Fatalf("expect the Deployment to be satisfied, got %+v", deviations)
+	}
+	h.cache.ForgetStrategyChange("Deployment", deviations[0].WorkloadKey)
+	if deviations = h.cache.ListStrategyChangeDeviations(); len(deviations) != 0 {
+		t.Fatalf("expect no deviations, got %+v", deviations)
+	}
+}
+
+// expectStrategyChanges checks the satisfied state of the listed deviations by the names of the workloads.
+func expectStrategyChanges(t *testing.T, h *testHarness, expect map[string]bool) {
+	t.Helper()
+
+	got := map[string]bool{}
+	for _, deviation := range h.cache.ListStrategyChangeDeviations() {
+		got[deviation.WorkloadKey.Name] = deviation.Satisfied()
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("expect strategy changes %v, got %v", expect, got)
+	}
+	for name, satisfied := range expect {
+		if gotSatisfied, ok := got[name]; !ok || gotSatisfied != satisfied {
+			t.Errorf("expect strategy changes %v, got %v", expect, got)
+		}
+	}
+}
+
+func TestStrategyChangeKeptUntilSatisfied(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	h.add(deployment)
+	h.add(replicaSet)
+	h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 3)...)
+	expectStrategyChanges(t, h, map[string]bool{})
+
+	changed := deployment.DeepCopy()
+	changed.Labels[optimizescheduling.OptimizeSchedulingStrategyKey] = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	h.update(changed)
+	expectStrategyChanges(t, h, map[string]bool{"web": false})
+
+	// The deviating workload is kept.
+	deploymentKey := types.NamespacedName{Namespace: harnessNamespace, Name: "web"}
+	h.cache.ForgetStrategyChange("Deployment", deploymentKey)
+	expectStrategyChanges(t, h, map[string]bool{"web": false})
+
+	h.update(deployment)
+	expectStrategyChanges(t, h, map[string]bool{"web": true})
+	h.cache.ForgetStrategyChange("Deployment", deploymentKey)
+	expectStrategyChanges(t, h, map[string]bool{})
+}
+
+func TestStrategyChangeOfDeletedWorkload(t *testing.T) {
+	h := newTestHarness(t, nil)
+	statefulSet := newHarnessStatefulSet("db", 3, optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand, 0)
+	h.add(statefulSet)
+	h.admitAll(newHarnessPods("StatefulSet", statefulSet.Name, 0, 3)...)
+
+	changed := statefulSet.DeepCopy()
+	changed.Labels[optimizescheduling.OptimizeSchedulingStrategyKey] = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	h.update(changed)
+	expectStrategyChanges(t, h, map[string]bool{"db": false})
+
+	h.delete(changed)
+	expectStrategyChanges(t, h, map[string]bool{})
+}
+
+func TestPendingStrategyChangeRecovered(t *testing.T) {
+	h := newTestHarness(t, nil)
+	deployment := newHarnessDeployment("web", 3, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	deployment.Annotations = map[string]string{optimizescheduling.OptimizeSchedulingPendingDeviationKey: "on-demand: -3, spot: +3"}
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 3)
+	statefulSet := newHarnessStatefulSet("db", 3, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+
+	// The workloads annotated before the restart are listed as soon as they are cached.
+	h.add(deployment)
+	h.add(replicaSet)
+	h.add(statefulSet)
+	expectStrategyChanges(t, h, map[string]bool{"web": false})
+
+	h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 3)...)
+	expectStrategyChanges(t, h, map[string]bool{"web": true})
+}
+
+func TestAPIFallback(t *testing.T) {
+	h := newTestHarness(t, nil)
+
+	// The objects are only in the API server, their events have not been received yet.
+	deployment := newHarnessDeployment("web", 2, optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 2)
+	statefulSet := newHarnessStatefulSet("db", 2, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	h.create(deployment)
+	h.create(replicaSet)
+	h.create(statefulSet)
+
+	got := h.admitAll(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 2)...)
+	expectAffinities(t, got, onDemand, onDemand)
+	got = h.admitAll(newHarnessPods("StatefulSet", statefulSet.Name, 0, 2)...)
+	expectAffinities(t, got, spot, spot)
+
+	// The Pods of a workload which doesn't exist are unset after the backoff.
+	got = h.admitAll(newHarnessPods("StatefulSet", "missing", 0, 1)...)
+	expectAffinities(t, got, unset)
+}
+
+func TestAPIFallbackExpiration(t *testing.T) {
+	h := newTestHarness(t, nil)
+
+	// The ReplicaSet and its Deployment are deleted before the informers see them, the StatefulSet is received
+	// by its informer later.
+	deployment := newHarnessDeployment("web", 2, optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand, 0)
+	replicaSet := newHarnessReplicaSet(deployment, "v1", 2)
+	statefulSet := newHarnessStatefulSet("db", 2, optimizescheduling.OptimizeSchedulingStrategyAllInSpot, 0)
+	h.create(deployment)
+	h.create(replicaSet)
+	h.create(statefulSet)
+	h.determine(newHarnessPods("ReplicaSet", replicaSet.Name, 0, 1)[0])
+	h.determine(newHarnessPods("StatefulSet", statefulSet.Name, 0, 1)[0])
+	if err := h.cache.statefulSetInformer.Informer().GetIndexer().Add(statefulSet); err != nil {
+		t.Fatal(err)
+	}
+
+	expectCached := func(replicaSetCached, deploymentCached, statefulSetCached bool) {
+		t.Helper()
+
+		shard := h.cache.shardFor(harnessNamespace)
+		shard.mutex.Lock()
+		defer shard.mutex.Unlock()
+		for _, c := range []struct {
+			reference workloadReference
+			expect    bool
+		}{
+			{workloadReference{Type: "ReplicaSet", Key: types.NamespacedName{Namespace: harnessNamespace, Name: replicaSet.Name}}, replicaSetCached},
+			{workloadReference{Type: "Deployment", Key: types.NamespacedName{Namespace: harnessNamespace, Name: deployment.Name}}, deploymentCached},
+			{workloadReference{Type: "StatefulSet", Key: types.NamespacedName{Namespace: harnessNamespace, Name: statefulSet.Name}}, statefulSetCached},
+		} {
+			if cached := shard.hasObject(c.reference); cached != c.expect {
+				t.Errorf("expect %s %v cached %v, got %v", c.reference.Type, c.reference.Key, c.expect, cached)
+			}
+		}
+	}
+
+	// The informers may still receive the objects before the expiration.
+	h.cache.resyncWorkloadSchedulingInfo()
+	expectCached(true, true, true)
+
+	h.clock.Step(fetchedObjectExpiration)
+	h.cache.resyncWorkloadSchedulingInfo()
+	expectCached(false, false, true)
+}
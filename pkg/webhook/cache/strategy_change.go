@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+	"vacant.sh/vmanager/pkg/webhook/cache/utils"
+)
+
+// recordStrategyChange remembers the workload if its strategy has been changed, the existing Pods stay
+// where they are, so the workload deviates from the new target until its Pods are recreated.
+func (wc *WebhookCache) recordStrategyChange(workloadType string, workloadKey types.NamespacedName,
+	oldSetting, newSetting *apis.OptimizeSchedulingSetting) {
+
+	if oldSetting.Enable == newSetting.Enable && oldSetting.Strategy == newSetting.Strategy &&
+		oldSetting.CustomOnDemand == newSetting.CustomOnDemand {
+		// Only the replicas are changed, the scaled Pods follow the new target.
+		return
+	}
+
+	shard := wc.shardFor(workloadKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	reference := workloadReference{Type: workloadType, Key: workloadKey}
+	if _, ok := shard.strategyChanges[reference]; !ok {
+		shard.strategyChanges[reference] = wc.clock.Now()
+	}
+
+	klog.Infof("The optimize scheduling strategy of %s %v is changed from %s (enable: %v) to %s (enable: %v), "+
+		"the existing Pods will be checked against the new target.", workloadType, workloadKey,
+		oldSetting.Strategy, oldSetting.Enable, newSetting.Strategy, newSetting.Enable)
+}
+
+// recordPendingStrategyChange remembers the workload which has been annotated with its pending deviation,
+// such as before vmanager restarts, so that its annotation is still updated and removed. require mutex locked.
+func (shard *cacheShard) recordPendingStrategyChange(reference workloadReference, annotations map[string]string,
+	now time.Time) {
+
+	if _, ok := annotations[optimizescheduling.OptimizeSchedulingPendingDeviationKey]; !ok {
+		return
+	}
+	if _, ok := shard.strategyChanges[reference]; !ok {
+		shard.strategyChanges[reference] = now
+	}
+}
+
+// ListStrategyChangeDeviations returns the deviations of the workloads whose strategies have been changed.
+// The satisfied ones, including the disabled workloads, are returned until they are forgotten by
+// ForgetStrategyChange, while the deleted workloads are forgotten at once.
+func (wc *WebhookCache) ListStrategyChangeDeviations() []*apis.WorkloadDeviation {
+	var deviations []*apis.WorkloadDeviation
+	for _, shard := range wc.shards {
+		deviations = append(deviations, shard.listStrategyChangeDeviations()...)
+	}
+	return deviations
+}
+
+func (shard *cacheShard) listStrategyChangeDeviations() []*apis.WorkloadDeviation {
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if len(shard.strategyChanges) == 0 {
+		return nil
+	}
+
+	replicaSetsByDeployment := shard.groupReplicaSetsByDeployment()
+	var deviations []*apis.WorkloadDeviation
+	for reference := range shard.strategyChanges {
+		deviation, exists := shard.getWorkloadDeviation(reference, replicaSetsByDeployment)
+		if !exists {
+			delete(shard.strategyChanges, reference)
+			continue
+		}
+		deviations = append(deviations, deviation)
+	}
+	return deviations
+}
+
+// ForgetStrategyChange forgets the workload once its satisfied deviation has been handled, such as its annotation
+// has been removed. The workload is kept if it deviates again, such as its strategy is changed in the meantime.
+func (wc *WebhookCache) ForgetStrategyChange(workloadType string, workloadKey types.NamespacedName) {
+	shard := wc.shardFor(workloadKey.Namespace)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	reference := workloadReference{Type: workloadType, Key: workloadKey}
+	changedAt, ok := shard.strategyChanges[reference]
+	if !ok {
+		return
+	}
+	deviation, exists := shard.getWorkloadDeviation(reference, shard.groupReplicaSetsByDeployment())
+	if exists && !deviation.Satisfied() {
+		return
+	}
+
+	klog.V(2).Infof("%s %v has been rebalanced since its strategy was changed at %v.", workloadType, workloadKey,
+		changedAt.Format(time.RFC3339))
+	delete(shard.strategyChanges, reference)
+}
+
+// groupReplicaSetsByDeployment returns the keys of the cached ReplicaSets of each Deployment. require mutex locked.
+func (shard *cacheShard) groupReplicaSetsByDeployment() map[types.NamespacedName][]types.NamespacedName {
+	replicaSetsByDeployment := map[types.NamespacedName][]types.NamespacedName{}
+	for replicaSetKey, replicaSet := range shard.replicaSets {
+		if deploymentKey := utils.GetReplicaSetSourceDeploymentKey(replicaSet); deploymentKey != nil {
+			replicaSetsByDeployment[*deploymentKey] = append(replicaSetsByDeployment[*deploymentKey], replicaSetKey)
+		}
+	}
+	return replicaSetsByDeployment
+}
+
+// getWorkloadDeviation compares the target of a Deployment or StatefulSet with its cached Pods,
+// it returns false if the workload is not in the cache. require mutex locked.
+func (shard *cacheShard) getWorkloadDeviation(reference workloadReference,
+	replicaSetsByDeployment map[types.NamespacedName][]types.NamespacedName) (*apis.WorkloadDeviation, bool) {
+	deviation := &apis.WorkloadDeviation{WorkloadType: reference.Type, WorkloadKey: reference.Key}
+
+	var setting *apis.OptimizeSchedulingSetting
+	var schedulingInfos []*apis.WorkloadSchedulingInfo
+	switch reference.Type {
+	case "Deployment":
+		deploymentInfo, ok := shard.deployments[reference.Key]
+		if !ok {
+			return nil, false
+		}
+		setting = deploymentInfo.OptimizeSchedulingSetting
+
+		// The Pods of all the revisions count, the old ones are replaced during a rollout.
+		for _, replicaSetKey := range replicaSetsByDeployment[reference.Key] {
+			schedulingInfos = append(schedulingInfos, shard.replicaSetWorkloadSchedulingInfo[replicaSetKey])
+		}
+	case "StatefulSet":
+		statefulSetInfo, ok := shard.statefulSets[reference.Key]
+		if !ok {
+			return nil, false
+		}
+		setting = statefulSetInfo.OptimizeSchedulingSetting
+		schedulingInfos = append(schedulingInfos, shard.statefulSetWorkloadSchedulingInfo[reference.Key])
+	default:
+		return nil, false
+	}
+
+	if !setting.Enable {
+		// Nothing to rebalance for a disabled workload, report it as satisfied.
+		return deviation, true
+	}
+
+	deviation.TargetOnDemandNum, deviation.TargetOnSpotNum = setting.TargetOnDemandNum, setting.TargetOnSpotNum
+	for _, wsi := range schedulingInfos {
+		if wsi == nil {
+			continue
+		}
+		deviation.ObservedOnDemandNum += wsi.OnDemandReplicaCount
+		deviation.ObservedOnSpotNum += wsi.SpotReplicaCount
+	}
+	return deviation, true
+}
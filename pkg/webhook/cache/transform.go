@@ -0,0 +1,105 @@
+package cache
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// transformObject strips the objects down to the fields used by the WebhookCache before they are stored
+// by the informers, since the whole cluster is cached. The new object is returned and the given one is
+// not modified, and it's idempotent as the informers may transform the stored objects again.
+func transformObject(obj interface{}) (interface{}, error) {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return stripPod(o), nil
+	case *appsv1.ReplicaSet:
+		return stripReplicaSet(o), nil
+	case *appsv1.Deployment:
+		return stripDeployment(o), nil
+	case *appsv1.StatefulSet:
+		return stripStatefulSet(o), nil
+	case *batchv1.Job:
+		return stripJob(o), nil
+	case *corev1.Namespace:
+		return stripNamespace(o), nil
+	}
+	return obj, nil
+}
+
+// stripObjectMeta keeps the identity, the owners and the optimize scheduling configuration of the object.
+func stripObjectMeta(objectMeta *metav1.ObjectMeta) metav1.ObjectMeta {
+	stripped := metav1.ObjectMeta{
+		Name:              objectMeta.Name,
+		Namespace:         objectMeta.Namespace,
+		UID:               objectMeta.UID,
+		ResourceVersion:   objectMeta.ResourceVersion,
+		CreationTimestamp: objectMeta.CreationTimestamp,
+		DeletionTimestamp: objectMeta.DeletionTimestamp,
+		Labels:            objectMeta.Labels,
+		OwnerReferences:   objectMeta.OwnerReferences,
+	}
+
+	// The last applied configuration is usually the largest annotation, it copies the whole object.
+	if _, ok := objectMeta.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		stripped.Annotations = make(map[string]string, len(objectMeta.Annotations)-1)
+		for key, value := range objectMeta.Annotations {
+			if key != corev1.LastAppliedConfigAnnotation {
+				stripped.Annotations[key] = value
+			}
+		}
+	} else {
+		stripped.Annotations = objectMeta.Annotations
+	}
+	return stripped
+}
+
+// stripPod keeps the phase of the Pod, which tells whether it's live.
+func stripPod(pod *corev1.Pod) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   pod.TypeMeta,
+		ObjectMeta: stripObjectMeta(&pod.ObjectMeta),
+		Status:     corev1.PodStatus{Phase: pod.Status.Phase},
+	}
+}
+
+func stripReplicaSet(replicaSet *appsv1.ReplicaSet) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		TypeMeta:   replicaSet.TypeMeta,
+		ObjectMeta: stripObjectMeta(&replicaSet.ObjectMeta),
+		Spec:       appsv1.ReplicaSetSpec{Replicas: replicaSet.Spec.Replicas},
+	}
+}
+
+func stripDeployment(deployment *appsv1.Deployment) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   deployment.TypeMeta,
+		ObjectMeta: stripObjectMeta(&deployment.ObjectMeta),
+		Spec:       appsv1.DeploymentSpec{Replicas: deployment.Spec.Replicas},
+	}
+}
+
+func stripStatefulSet(statefulSet *appsv1.StatefulSet) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		TypeMeta:   statefulSet.TypeMeta,
+		ObjectMeta: stripObjectMeta(&statefulSet.ObjectMeta),
+		Spec:       appsv1.StatefulSetSpec{Replicas: statefulSet.Spec.Replicas},
+	}
+}
+
+func stripJob(job *batchv1.Job) *batchv1.Job {
+	return &batchv1.Job{
+		TypeMeta:   job.TypeMeta,
+		ObjectMeta: stripObjectMeta(&job.ObjectMeta),
+		Spec:       batchv1.JobSpec{Parallelism: job.Spec.Parallelism, Completions: job.Spec.Completions},
+	}
+}
+
+// stripNamespace keeps the labels of the Namespace, which are matched by the namespace selector.
+func stripNamespace(namespace *corev1.Namespace) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta:   namespace.TypeMeta,
+		ObjectMeta: stripObjectMeta(&namespace.ObjectMeta),
+	}
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	pkgruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// benchmarkPodNum is the number of the Pods of the synthetic cluster.
+const benchmarkPodNum = 100000
+
+// newBenchmarkPod returns a running Pod of a ReplicaSet like the ones returned by the API server, the Pods are spread
+// over the namespaces and the nodes.
+func newBenchmarkPod(i int) *corev1.Pod {
+	pod := withAffinity(newHarnessPod("ReplicaSet", fmt.Sprintf("app-%d-5d8f7c9b6", i/10), i), podaffinity.PodAffinitySpot)
+	pod.Namespace = fmt.Sprintf("namespace-%d", i/1000)
+	pod.Labels["app"] = fmt.Sprintf("app-%d", i/10)
+	pod = withServerFields(pod).(*corev1.Pod)
+	pod.Spec.NodeName = fmt.Sprintf("node-%d", i%500)
+	pod.Status.PodIP = fmt.Sprintf("10.0.%d.%d", i/256%256, i%256)
+	return pod
+}
+
+// benchmarkInformerMemory syncs a Pod informer from a list of the synthetic cluster, and reports the heap retained by it.
+func benchmarkInformerMemory(b *testing.B, transform cache.TransformFunc) {
+	for n := 0; n < b.N; n++ {
+		listWatch := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (pkgruntime.Object, error) {
+				podList := &corev1.PodList{Items: make([]corev1.Pod, 0, benchmarkPodNum)}
+				for i := 0; i < benchmarkPodNum; i++ {
+					podList.Items = append(podList.Items, *newBenchmarkPod(i))
+				}
+				return podList, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return watch.NewFake(), nil
+			},
+		}
+
+		runtime.GC()
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		informer := cache.NewSharedIndexInformer(listWatch, &corev1.Pod{}, 0, cache.Indexers{})
+		if transform != nil {
+			if err := informer.SetTransform(transform); err != nil {
+				b.Fatal(err)
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		go informer.Run(ctx.Done())
+		if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			cancel()
+			b.Fatal("the informer failed to sync")
+		}
+
+		runtime.GC()
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		if len(informer.GetStore().ListKeys()) != benchmarkPodNum {
+			b.Fatalf("the informer has %d pods, expect %d", len(informer.GetStore().ListKeys()), benchmarkPodNum)
+		}
+		cancel()
+
+		retained := float64(after.HeapAlloc) - float64(before.HeapAlloc)
+		b.ReportMetric(retained/(1<<20), "MiB")
+		b.ReportMetric(retained/benchmarkPodNum, "B/pod")
+	}
+}
+
+// BenchmarkInformerMemory compares the memory of caching the full Pods and the stripped ones, run it by
+// go test ./pkg/webhook/cache -run '^$' -bench InformerMemory -benchtime 1x
+func BenchmarkInformerMemory(b *testing.B) {
+	b.Run("full", func(b *testing.B) {
+		benchmarkInformerMemory(b, nil)
+	})
+	b.Run("transformed", func(b *testing.B) {
+		benchmarkInformerMemory(b, transformObject)
+	})
+}
+
+// runTransformScenario drives the cache through the placement, the rollout and the strategy change of the full
+// objects, and returns the decided affinities and the statuses of the workloads.
+func runTransformScenario(h *testHarness) ([]podaffinity.PodAffinitySettingName, []*apis.WorkloadStatus) {
+	full := func(obj pkgruntime.Object) pkgruntime.Object {
+		return withServerFields(obj)
+	}
+	admit := func(pods []*corev1.Pod) []podaffinity.PodAffinitySettingName {
+		var affinities []podaffinity.PodAffinitySettingName
+		for _, pod := range pods {
+			affinities = append(affinities, h.admit(full(pod).(*corev1.Pod)))
+		}
+		return affinities
+	}
+
+	deployment := newHarnessDeployment("web", 5, optimizescheduling.OptimizeSchedulingStrategyMajorityInOnDemand, 0)
+	oldReplicaSet := newHarnessReplicaSet(deployment, "v1", 5)
+	statefulSet := newHarnessStatefulSet("db", 4, optimizescheduling.OptimizeSchedulingStrategyCustom, 1)
+	h.add(full(deployment))
+	h.add(full(oldReplicaSet))
+	h.add(full(statefulSet))
+
+	affinities := admit(newHarnessPods("ReplicaSet", oldReplicaSet.Name, 0, 5))
+	affinities = append(affinities, admit(newHarnessPods("StatefulSet", statefulSet.Name, 0, 4))...)
+
+	// A terminating Pod and a terminal Pod are replaced.
+	terminating := h.pod(oldReplicaSet.Name + "-0").DeepCopy()
+	terminating.DeletionTimestamp = ptr.To(metav1.Now())
+	h.update(terminating)
+	succeeded := h.pod(statefulSet.Name + "-3").DeepCopy()
+	succeeded.Status.Phase = corev1.PodSucceeded
+	h.update(succeeded)
+	affinities = append(affinities, admit(newHarnessPods("ReplicaSet", oldReplicaSet.Name, 5, 6))...)
+	h.delete(succeeded)
+	affinities = append(affinities, admit(newHarnessPods("StatefulSet", statefulSet.Name, 3, 4))...)
+
+	// The strategy change rolls out a new ReplicaSet.
+	deployment.Labels[optimizescheduling.OptimizeSchedulingStrategyKey] = optimizescheduling.OptimizeSchedulingStrategyAllInSpot
+	h.update(full(deployment))
+	newReplicaSet := newHarnessReplicaSet(deployment, "v2", 5)
+	h.add(full(newReplicaSet))
+	affinities = append(affinities, admit(newHarnessPods("ReplicaSet", newReplicaSet.Name, 0, 3))...)
+
+	statuses := h.cache.ListWorkloadStatuses()
+	for _, status := range statuses {
+		// The stripped annotations and the decision times are not compared.
+		status.Annotations, status.LastDecisionTime = nil, time.Time{}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].WorkloadKey.Name < statuses[j].WorkloadKey.Name
+	})
+	return affinities, statuses
+}
+
+func TestTransformKeepsDecisions(t *testing.T) {
+	stripped := newTestHarness(t, nil)
+	strippedAffinities, strippedStatuses := runTransformScenario(stripped)
+
+	unstripped := newTestHarness(t, nil)
+	unstripped.transform = func(obj interface{}) (interface{}, error) {
+		return obj, nil
+	}
+	fullAffinities, fullStatuses := runTransformScenario(unstripped)
+
+	expectAffinities(t, strippedAffinities, fullAffinities...)
+	if !reflect.DeepEqual(strippedStatuses, fullStatuses) {
+		for i := range fullStatuses {
+			t.Logf("full %+v", *fullStatuses[i])
+		}
+		for i := range strippedStatuses {
+			t.Logf("stripped %+v", *strippedStatuses[i])
+		}
+		t.Errorf("expect the same statuses from the stripped objects as the full objects")
+	}
+	if len(fullStatuses) != 2 {
+		t.Errorf("expect the statuses of 2 workloads, got %d", len(fullStatuses))
+	}
+}
@@ -3,36 +3,95 @@ package utils
 import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 
 	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
 )
 
-// GetPodSourceWorkloadTypeAndKey retrieves the type and name of the source workload of a Pod, returning either
-// ReplicaSet or StatefulSet. In other cases, it returns "".
+// GetPodSourceWorkloadTypeAndKey retrieves the type and name of the source workload of a Pod, returning
+// ReplicaSet, StatefulSet or Job. For the other controllers of the Pod, including the kinds of the same name
+// in the other groups, the type is built by GenericWorkloadType, such as CloneSet.apps.kruise.io or
+// StatefulSet.apps.kruise.io. In other cases, it returns "".
 func GetPodSourceWorkloadTypeAndKey(pod *corev1.Pod) (string, *types.NamespacedName) {
 	if pod == nil {
 		return "", nil
 	}
 
 	for _, ownerRef := range pod.OwnerReferences {
-		if ownerRef.Kind == "ReplicaSet" || ownerRef.Kind == "StatefulSet" {
+		if isAppsOwner(ownerRef, "ReplicaSet") || isAppsOwner(ownerRef, "StatefulSet") || isJobOwner(ownerRef) {
 			return ownerRef.Kind, &types.NamespacedName{
 				Namespace: pod.Namespace,
 				Name:      ownerRef.Name,
 			}
 		}
 	}
+
+	if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil && IsGenericWorkloadOwner(*controllerRef) {
+		return GenericWorkloadType(*controllerRef), &types.NamespacedName{
+			Namespace: pod.Namespace,
+			Name:      controllerRef.Name,
+		}
+	}
 	return "", nil
 }
 
+// isAppsOwner returns true if the owner is the kind of the apps group, the kinds of the same name in other groups,
+// such as the Advanced StatefulSet of OpenKruise, are generic workloads.
+func isAppsOwner(ownerRef metav1.OwnerReference, kind string) bool {
+	return ownerRef.Kind == kind && schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind).Group == appsv1.GroupName
+}
+
+// isJobOwner returns true if the owner is a batch Job, the Jobs of other groups are generic workloads.
+func isJobOwner(ownerRef metav1.OwnerReference) bool {
+	gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+	return err == nil && gv.Group == "batch" && ownerRef.Kind == "Job"
+}
+
+// GenericWorkloadType returns the workload type of an owner which is not a ReplicaSet, StatefulSet or Job,
+// in the format of Kind.group, such as CloneSet.apps.kruise.io.
+func GenericWorkloadType(ownerRef metav1.OwnerReference) string {
+	gv, _ := schema.ParseGroupVersion(ownerRef.APIVersion)
+	return schema.GroupKind{Group: gv.Group, Kind: ownerRef.Kind}.String()
+}
+
+// IsGenericWorkloadOwner returns false for the owners which never scale their Pods by replicas,
+// such as the Node of a mirror Pod and the DaemonSet.
+func IsGenericWorkloadOwner(ownerRef metav1.OwnerReference) bool {
+	gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+	if err != nil {
+		return false
+	}
+
+	switch (schema.GroupKind{Group: gv.Group, Kind: ownerRef.Kind}) {
+	case schema.GroupKind{Kind: "Node"}, schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return false
+	}
+	return true
+}
+
+// GetReplicaSetSourceGenericOwner returns the controller of the ReplicaSet if it's not a Deployment,
+// such as an Argo Rollout.
+func GetReplicaSetSourceGenericOwner(replicaSet *appsv1.ReplicaSet) *metav1.OwnerReference {
+	if replicaSet == nil || GetReplicaSetSourceDeploymentKey(replicaSet) != nil {
+		return nil
+	}
+
+	controllerRef := metav1.GetControllerOf(replicaSet)
+	if controllerRef == nil || !IsGenericWorkloadOwner(*controllerRef) {
+		return nil
+	}
+	return controllerRef
+}
+
 func GetReplicaSetSourceDeploymentKey(replicaSet *appsv1.ReplicaSet) *types.NamespacedName {
 	if replicaSet == nil {
 		return nil
 	}
 
 	for _, ownerRef := range replicaSet.OwnerReferences {
-		if ownerRef.Kind == "Deployment" {
+		if isAppsOwner(ownerRef, "Deployment") {
 			return &types.NamespacedName{
 				Namespace: replicaSet.Namespace,
 				Name:      ownerRef.Name,
@@ -45,11 +104,23 @@ func GetReplicaSetSourceDeploymentKey(replicaSet *appsv1.ReplicaSet) *types.Name
 // GetPodAffinitySetting determines if the Pod has been modified by our webhook to have the required affinity
 // based on a preset Label. If not, it returns "unset".
 func GetPodAffinitySetting(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
-	if pod == nil || pod.Labels == nil || pod.Labels[podaffinity.PodAffinityLabelKey] == "" {
+	if pod == nil {
 		return podaffinity.PodAffinityUnset
 	}
+	return toPodAffinitySetting(pod.Labels[podaffinity.PodAffinityLabelKey])
+}
 
-	switch podaffinity.PodAffinitySettingName(pod.Labels[podaffinity.PodAffinityLabelKey]) {
+// GetPodDryRunAffinitySetting returns the affinity setting recorded for the Pod in the dry-run mode,
+// or "unset" if it's not recorded. The Pod is not placed by it.
+func GetPodDryRunAffinitySetting(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
+	if pod == nil {
+		return podaffinity.PodAffinityUnset
+	}
+	return toPodAffinitySetting(pod.Annotations[podaffinity.PodAffinityDryRunAnnotationKey])
+}
+
+func toPodAffinitySetting(setting string) podaffinity.PodAffinitySettingName {
+	switch podaffinity.PodAffinitySettingName(setting) {
 	case podaffinity.PodAffinityOnDemand:
 		return podaffinity.PodAffinityOnDemand
 	case podaffinity.PodAffinitySpot:
@@ -58,3 +129,11 @@ func GetPodAffinitySetting(pod *corev1.Pod) podaffinity.PodAffinitySettingName {
 		return podaffinity.PodAffinityUnset
 	}
 }
+
+// IsPodLive returns false if the Pod is terminal or terminating, which will be replaced by a new Pod soon.
+func IsPodLive(pod *corev1.Pod) bool {
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return false
+	}
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
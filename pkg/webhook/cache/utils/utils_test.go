@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetPodSourceWorkloadTypeAndKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		expectType string
+	}{
+		{name: "replicaset", apiVersion: "apps/v1", kind: "ReplicaSet", expectType: "ReplicaSet"},
+		{name: "statefulset", apiVersion: "apps/v1", kind: "StatefulSet", expectType: "StatefulSet"},
+		{name: "job", apiVersion: "batch/v1", kind: "Job", expectType: "Job"},
+		{name: "kruise cloneset", apiVersion: "apps.kruise.io/v1alpha1", kind: "CloneSet", expectType: "CloneSet.apps.kruise.io"},
+		{name: "kruise advanced statefulset", apiVersion: "apps.kruise.io/v1beta1", kind: "StatefulSet",
+			expectType: "StatefulSet.apps.kruise.io"},
+		{name: "custom replicaset", apiVersion: "example.com/v1", kind: "ReplicaSet", expectType: "ReplicaSet.example.com"},
+		{name: "custom job", apiVersion: "example.com/v1", kind: "Job", expectType: "Job.example.com"},
+		{name: "daemonset", apiVersion: "apps/v1", kind: "DaemonSet", expectType: ""},
+		{name: "node", apiVersion: "v1", kind: "Node", expectType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "web-0",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: tt.apiVersion, Kind: tt.kind, Name: "web", UID: types.UID("web"), Controller: ptr.To(true)},
+				},
+			}}
+
+			workloadType, workloadKey := GetPodSourceWorkloadTypeAndKey(pod)
+			if workloadType != tt.expectType {
+				t.Errorf("expect the workload type %q, got %q", tt.expectType, workloadType)
+			}
+			if expectKey := (types.NamespacedName{Namespace: "default", Name: "web"}); tt.expectType != "" &&
+				(workloadKey == nil || *workloadKey != expectKey) {
+				t.Errorf("expect the workload key %v, got %v", expectKey, workloadKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+
+	"vacant.sh/vmanager/pkg/webhook/cache/apis"
+)
+
+// ListWorkloadStatuses returns the placement of the Pods of all the cached Deployments and StatefulSets.
+func (wc *WebhookCache) ListWorkloadStatuses() []*apis.WorkloadStatus {
+	var statuses []*apis.WorkloadStatus
+	for _, shard := range wc.shards {
+		statuses = append(statuses, shard.listWorkloadStatuses()...)
+	}
+	return statuses
+}
+
+func (shard *cacheShard) listWorkloadStatuses() []*apis.WorkloadStatus {
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	replicaSetsByDeployment := shard.groupReplicaSetsByDeployment()
+
+	statuses := make([]*apis.WorkloadStatus, 0, len(shard.deployments)+len(shard.statefulSets))
+	for deploymentKey, deploymentInfo := range shard.deployments {
+		status := shard.getWorkloadStatus(workloadReference{Type: "Deployment", Key: deploymentKey},
+			deploymentInfo.OptimizeSchedulingSetting, deploymentInfo.Deployment.Annotations, replicaSetsByDeployment)
+		// The decisions are recorded for the ReplicaSets, the latest one of all the revisions is reported.
+		for _, replicaSetKey := range replicaSetsByDeployment[deploymentKey] {
+			decisionTime := shard.lastDecisionTimes[workloadReference{Type: "ReplicaSet", Key: replicaSetKey}]
+			if decisionTime.After(status.LastDecisionTime) {
+				status.LastDecisionTime = decisionTime
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	for statefulSetKey, statefulSetInfo := range shard.statefulSets {
+		reference := workloadReference{Type: "StatefulSet", Key: statefulSetKey}
+		status := shard.getWorkloadStatus(reference, statefulSetInfo.OptimizeSchedulingSetting,
+			statefulSetInfo.StatefulSet.Annotations, replicaSetsByDeployment)
+		status.LastDecisionTime = shard.lastDecisionTimes[reference]
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// getWorkloadStatus builds the status of a cached workload. require mutex locked.
+func (shard *cacheShard) getWorkloadStatus(reference workloadReference, setting *apis.OptimizeSchedulingSetting,
+	annotations map[string]string, replicaSetsByDeployment map[types.NamespacedName][]types.NamespacedName) *apis.WorkloadStatus {
+
+	status := &apis.WorkloadStatus{
+		Enable:      setting.Enable,
+		Strategy:    setting.Strategy,
+		Annotations: annotations,
+	}
+	if deviation, ok := shard.getWorkloadDeviation(reference, replicaSetsByDeployment); ok {
+		status.WorkloadDeviation = *deviation
+	}
+	return status
+}
@@ -0,0 +1,151 @@
+package configuration
+
+import (
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"vacant.sh/vmanager/pkg/config"
+)
+
+// ServiceReference is the Service in front of the webhook-manager.
+type ServiceReference struct {
+	Namespace string
+	Name      string
+	Port      int32
+}
+
+// DefaultService is the Service of deploy/webhook-manager.yaml.
+var DefaultService = ServiceReference{Namespace: "vmanager", Name: "vmanager-webhook", Port: 443}
+
+// webhook describes one of the webhooks served by the webhook-manager.
+type webhook struct {
+	name       string
+	path       string
+	mutating   bool
+	operations []admissionregistrationv1.OperationType
+	apiGroup   string
+	resource   string
+}
+
+var webhooks = []webhook{
+	{
+		name: "mutate.pod.vacant.sh", path: "/mutate-pod", mutating: true,
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		apiGroup:   "", resource: "pods",
+	},
+	{
+		name: "validate.pod.vacant.sh", path: "/validate-pod",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+		apiGroup:   "", resource: "pods",
+	},
+	{
+		name: "validate.deployment.vacant.sh", path: "/validate-deployment",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		apiGroup:   "apps", resource: "deployments",
+	},
+	{
+		name: "validate.replicaset.vacant.sh", path: "/validate-replicaset",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		apiGroup:   "apps", resource: "replicasets",
+	},
+	{
+		name: "validate.statefulset.vacant.sh", path: "/validate-statefulset",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		apiGroup:   "apps", resource: "statefulsets",
+	},
+	{
+		name: "validate.job.vacant.sh", path: "/validate-job",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		apiGroup:   "batch", resource: "jobs",
+	},
+	{
+		name: "validate.cronjob.vacant.sh", path: "/validate-cronjob",
+		operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		apiGroup:   "batch", resource: "cronjobs",
+	},
+}
+
+// configurationName returns the name of the configuration object, such as vmanager-validate-deployment.
+func (w *webhook) configurationName() string {
+	return "vmanager-" + strings.TrimPrefix(w.path, "/")
+}
+
+func (w *webhook) clientConfig(service ServiceReference, caBundle []byte) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: service.Namespace,
+			Name:      service.Name,
+			Path:      ptr.To(w.path),
+			Port:      ptr.To(service.Port),
+		},
+		CABundle: caBundle,
+	}
+}
+
+func (w *webhook) rules() []admissionregistrationv1.RuleWithOperations {
+	return []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: w.operations,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{w.apiGroup},
+				APIVersions: []string{"v1"},
+				Resources:   []string{w.resource},
+				Scope:       ptr.To(admissionregistrationv1.NamespacedScope),
+			},
+		},
+	}
+}
+
+// BuildWebhookConfigurations returns the MutatingWebhookConfigurations and ValidatingWebhookConfigurations of
+// the webhook-manager, whose namespaceSelector selects the namespaces handled with the configuration.
+func BuildWebhookConfigurations(c *config.WebhookManagerConfiguration, service ServiceReference,
+	caBundle []byte) ([]*admissionregistrationv1.MutatingWebhookConfiguration, []*admissionregistrationv1.ValidatingWebhookConfiguration) {
+	namespaceSelector := c.WebhookNamespaceSelector()
+
+	var mutatingConfigurations []*admissionregistrationv1.MutatingWebhookConfiguration
+	var validatingConfigurations []*admissionregistrationv1.ValidatingWebhookConfiguration
+	for i := range webhooks {
+		w := &webhooks[i]
+		if w.mutating {
+			mutatingConfigurations = append(mutatingConfigurations, &admissionregistrationv1.MutatingWebhookConfiguration{
+				TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "MutatingWebhookConfiguration"},
+				ObjectMeta: metav1.ObjectMeta{Name: w.configurationName()},
+				Webhooks: []admissionregistrationv1.MutatingWebhook{
+					{
+						Name:                    w.name,
+						AdmissionReviewVersions: []string{"v1"},
+						ClientConfig:            w.clientConfig(service, caBundle),
+						Rules:                   w.rules(),
+						FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+						MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+						NamespaceSelector:       namespaceSelector.DeepCopy(),
+						SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+						TimeoutSeconds:          ptr.To[int32](3),
+					},
+				},
+			})
+			continue
+		}
+
+		validatingConfigurations = append(validatingConfigurations, &admissionregistrationv1.ValidatingWebhookConfiguration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "ValidatingWebhookConfiguration"},
+			ObjectMeta: metav1.ObjectMeta{Name: w.configurationName()},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name:                    w.name,
+					AdmissionReviewVersions: []string{"v1"},
+					ClientConfig:            w.clientConfig(service, caBundle),
+					Rules:                   w.rules(),
+					FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+					NamespaceSelector:       namespaceSelector.DeepCopy(),
+					SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+					TimeoutSeconds:          ptr.To[int32](3),
+				},
+			},
+		})
+	}
+	return mutatingConfigurations, validatingConfigurations
+}
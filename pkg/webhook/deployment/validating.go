@@ -5,38 +5,53 @@ import (
 	"net/http"
 
 	admissionv1 "k8s.io/api/admission/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/utils"
 )
 
 type Validating struct {
 	Decoder admission.Decoder
+	// NamespaceFilter skips the objects in the namespaces which are not handled, nil handles all.
+	NamespaceFilter utils.NamespaceFilter
+	// KubeClient is used to find the HorizontalPodAutoscalers targeting the Deployment.
+	KubeClient kubernetes.Interface
 }
 
 // Check if Validating implements necessary func.
 var _ admission.Handler = &Validating{}
 
-func (v *Validating) Handle(_ context.Context, req admission.Request) admission.Response {
+func (v *Validating) Handle(ctx context.Context, req admission.Request) admission.Response {
 	if req.Operation != admissionv1.Update && req.Operation != admissionv1.Create {
 		// This should never happen, we only care the CREATE and UPDATE operation.
 		return admission.Allowed("")
 	}
+	if utils.IsNamespaceSkipped(v.NamespaceFilter, req.Namespace) {
+		klog.V(5).Infof("Skip the %s in unmanaged namespace %s", req.Kind.Kind, req.Namespace)
+		return admission.Allowed("")
+	}
 
-	// Parse the uncertain type resource object, we don't need care the oldObject here.
-	obj := &unstructured.Unstructured{}
-	if err := v.Decoder.DecodeRaw(req.Object, obj); err != nil {
+	deployment := &appsv1.Deployment{}
+	if err := v.Decoder.DecodeRaw(req.Object, deployment); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
-	klog.V(3).Infof("Validating Deployment %s/%s", obj.GetNamespace(), obj.GetName())
+	klog.V(3).Infof("Validating Deployment %s/%s", deployment.Namespace, deployment.Name)
 
-	errList := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels())
-	if len(errList) > 0 {
-		return admission.Denied(errList.ToAggregate().Error())
+	// The old object is only decoded for the UPDATE operation, it's nil on creation.
+	var oldObj metav1.Object
+	if req.Operation == admissionv1.Update {
+		oldDeployment := &appsv1.Deployment{}
+		if err := v.Decoder.DecodeRaw(req.OldObject, oldDeployment); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldObj = oldDeployment
 	}
 
-	return admission.Allowed("")
+	return utils.ValidateScalableWorkload(ctx, v.KubeClient, appsv1.SchemeGroupVersion.WithKind("Deployment"),
+		oldObj, deployment, deployment.Spec.Replicas)
 }
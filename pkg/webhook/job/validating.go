@@ -0,0 +1,53 @@
+package job
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/utils"
+)
+
+type Validating struct {
+	Decoder admission.Decoder
+	// NamespaceFilter skips the objects in the namespaces which are not handled, nil handles all.
+	NamespaceFilter utils.NamespaceFilter
+}
+
+// Check if Validating implements necessary func.
+var _ admission.Handler = &Validating{}
+
+func (v *Validating) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update && req.Operation != admissionv1.Create {
+		// This should never happen, we only care the CREATE and UPDATE operation.
+		return admission.Allowed("")
+	}
+	if utils.IsNamespaceSkipped(v.NamespaceFilter, req.Namespace) {
+		klog.V(5).Infof("Skip the %s in unmanaged namespace %s", req.Kind.Kind, req.Namespace)
+		return admission.Allowed("")
+	}
+
+	// Parse the uncertain type resource object, we don't need care the oldObject here.
+	obj := &unstructured.Unstructured{}
+	if err := v.Decoder.DecodeRaw(req.Object, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	klog.V(3).Infof("Validating Job %s/%s", obj.GetNamespace(), obj.GetName())
+
+	errList := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(),
+		field.NewPath("metadata"))
+	if len(errList) > 0 {
+		return admission.Denied(errList.ToAggregate().Error())
+	}
+
+	// Tell the users about the configurations which have no effect without blocking them.
+	return admission.Allowed("").WithWarnings(optimizescheduling.WarnOptimizeSchedulingConfiguration(obj.GetLabels(),
+		obj.GetAnnotations(), field.NewPath("metadata"))...)
+}
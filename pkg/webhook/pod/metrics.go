@@ -0,0 +1,22 @@
+package pod
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	modeEnforce = "enforce"
+	modeDryRun  = "dry-run"
+)
+
+// podAffinityDecisions counts the placement decided for the new Pods, the decisions in the dry-run mode are counted
+// separately, so that the would-be placement can be compared with the enforced one.
+var podAffinityDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "vmanager_pod_affinity_decisions_total",
+	Help: "Number of the placement decided for the new Pods by affinity and mode (enforce or dry-run).",
+}, []string{"affinity", "mode"})
+
+func init() {
+	metrics.Registry.MustRegister(podAffinityDecisions)
+}
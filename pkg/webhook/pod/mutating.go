@@ -10,7 +10,7 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	nodetype "vacant.sh/vmanager/pkg/definitions/node-type"
+	"vacant.sh/vmanager/pkg/config"
 	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
 	"vacant.sh/vmanager/pkg/webhook/cache"
 )
@@ -18,37 +18,13 @@ import (
 type Mutating struct {
 	Decoder admission.Decoder
 	Cache   cache.Interface
+	Config  *config.Holder
 }
 
 // Check if Mutating implements necessary func.
 var _ admission.Handler = &Mutating{}
 
-// PodPreferSpotAffinity the default configuration for let pod prefers to spot node.
-var podPreferSpotAffinity = corev1.PreferredSchedulingTerm{
-	Weight: 10,
-	Preference: corev1.NodeSelectorTerm{
-		MatchExpressions: []corev1.NodeSelectorRequirement{
-			{
-				Key:      nodetype.NodeTypeLabelKey,
-				Operator: corev1.NodeSelectorOpIn,
-				Values:   []string{string(nodetype.NodeTypeSpot)},
-			},
-		},
-	},
-}
-
-// PodRequireOnDemandAffinity the default configuration for restrict a pod must on on-demand node.
-var podRequireOnDemandAffinity = corev1.NodeSelectorTerm{
-	MatchExpressions: []corev1.NodeSelectorRequirement{
-		{
-			Key:      nodetype.NodeTypeLabelKey,
-			Operator: corev1.NodeSelectorOpIn,
-			Values:   []string{string(nodetype.NodeTypeOnDemand)},
-		},
-	},
-}
-
-func (m *Mutating) Handle(_ context.Context, req admission.Request) admission.Response {
+func (m *Mutating) Handle(ctx context.Context, req admission.Request) admission.Response {
 	if req.Operation != admissionv1.Create {
 		// This should never happen, we only care the CREATE operation in validating.
 		return admission.Allowed("")
@@ -60,14 +36,36 @@ func (m *Mutating) Handle(_ context.Context, req admission.Request) admission.Re
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
-	targetAffinitySettingName := m.Cache.DetermineNewPodAffinityPreference(pod)
+	if !m.Cache.IsNamespaceManaged(req.Namespace) {
+		klog.V(5).Infof("Skip the pod in unmanaged namespace %s", req.Namespace)
+		return admission.Allowed("")
+	}
+
+	// The dry-run mode is resolved with the same setting as the decision.
+	targetAffinitySettingName, dryRun := m.Cache.DetermineNewPodAffinityPreference(ctx, pod)
 
 	klog.V(3).Infof("Determine new pod %s/%s affinity setting %s", pod.Namespace, pod.GenerateName, targetAffinitySettingName)
 
+	mode := modeEnforce
+	if dryRun {
+		mode = modeDryRun
+	}
+	podAffinityDecisions.WithLabelValues(string(targetAffinitySettingName), mode).Inc()
+
 	if targetAffinitySettingName == podaffinity.PodAffinityUnset {
 		return admission.Allowed("")
 	}
 
+	if dryRun {
+		// Only record the decision, the cache counts the Pod by the annotation apart from the placed Pods.
+		klog.V(2).Infof("Dry run: pod %s/%s would be placed on %s", pod.Namespace, pod.GenerateName, targetAffinitySettingName)
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[podaffinity.PodAffinityDryRunAnnotationKey] = string(targetAffinitySettingName)
+		return patchResponse(req, pod)
+	}
+
 	// Prepare the label and affinity struct, then patch the pod.
 	pod.Labels[podaffinity.PodAffinityLabelKey] = string(targetAffinitySettingName)
 
@@ -79,6 +77,12 @@ func (m *Mutating) Handle(_ context.Context, req admission.Request) admission.Re
 	}
 
 	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	// The affinity templates come from the configuration, copy them to avoid sharing with the pod. Both templates
+	// are taken from the same configuration, while the cache reads the configuration on its own, so a reload
+	// in the meantime may decide the Pod by the previous configuration.
+	activeConfig := m.Config.Get()
+	podRequireOnDemandAffinity := *activeConfig.Affinity.OnDemand.DeepCopy()
+	podPreferSpotAffinity := *activeConfig.Affinity.Spot.DeepCopy()
 
 	if targetAffinitySettingName == podaffinity.PodAffinityOnDemand {
 		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
@@ -103,6 +107,10 @@ func (m *Mutating) Handle(_ context.Context, req admission.Request) admission.Re
 		}
 	}
 
+	return patchResponse(req, pod)
+}
+
+func patchResponse(req admission.Request, pod *corev1.Pod) admission.Response {
 	marshaledBytes, err := json.Marshal(pod)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
@@ -0,0 +1,99 @@
+package pod
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"vacant.sh/vmanager/pkg/config"
+	"vacant.sh/vmanager/pkg/webhook/cache"
+	"vacant.sh/vmanager/pkg/webhook/utils"
+)
+
+// TestMutatingHonorsRequestTimeout sends a Pod whose ReplicaSet never shows up, the lookups must stop
+// by the timeout of the API server minus the deadline margin instead of the much longer backoff timeout.
+func TestMutatingHonorsRequestTimeout(t *testing.T) {
+	const requestTimeout, deadlineMargin = 2 * time.Second, time.Second
+
+	c := config.NewDefaultConfiguration()
+	c.Backoff = config.BackoffConfiguration{
+		Duration:       metav1.Duration{Duration: 10 * time.Millisecond},
+		Factor:         1,
+		Jitter:         ptr.To(0.0),
+		Steps:          1000,
+		Timeout:        metav1.Duration{Duration: 30 * time.Second},
+		DeadlineMargin: metav1.Duration{Duration: deadlineMargin},
+	}
+	configHolder := config.NewHolder(c)
+
+	wc, err := cache.NewWebhookCacheForClients(fake.NewSimpleClientset(), nil, configHolder, clock.RealClock{})
+	if err != nil {
+		t.Fatalf("Failed to build the cache: %v", err)
+	}
+
+	server := httptest.NewServer(utils.WithRequestDeadline(&webhook.Admission{
+		Handler: &Mutating{Decoder: admission.NewDecoder(scheme.Scheme), Cache: wc, Config: configHolder},
+	}))
+	defer server.Close()
+
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "web-5d8f-", Labels: map[string]string{},
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d8f",
+				UID: "uid-web-5d8f", Controller: ptr.To(true)}}},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("request-1"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "default",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := http.Post(server.URL+"/mutate-pod?timeout="+requestTimeout.String(), "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to send the admission request: %v", err)
+	}
+	defer resp.Body.Close()
+	elapsed := time.Since(start)
+
+	// The lookups stop at the deadline minus the margin, allow half of the margin for the response.
+	if limit := requestTimeout - deadlineMargin/2; elapsed > limit {
+		t.Errorf("Expect the response within %v, got %v", limit, elapsed)
+	}
+
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(review); err != nil {
+		t.Fatalf("Failed to decode the response: %v", err)
+	}
+	if review.Response == nil || !review.Response.Allowed || len(review.Response.Patch) != 0 {
+		t.Errorf("Expect the Pod allowed without patch, got %+v", review.Response)
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/utils"
 )
 
 type Validating struct {
 	Decoder admission.Decoder
+	// NamespaceFilter skips the objects in the namespaces which are not handled, nil handles all.
+	NamespaceFilter utils.NamespaceFilter
 }
 
 // Check if Validating implements necessary func.
@@ -25,12 +28,16 @@ func (v *Validating) Handle(_ context.Context, req admission.Request) admission.
 		// This should never happen, we only care the UPDATE operation in validating.
 		return admission.Allowed("")
 	}
+	if utils.IsNamespaceSkipped(v.NamespaceFilter, req.Namespace) {
+		klog.V(5).Infof("Skip the %s in unmanaged namespace %s", req.Kind.Kind, req.Namespace)
+		return admission.Allowed("")
+	}
 
-	var oldPod, newPod *corev1.Pod
-	if err := v.Decoder.DecodeRaw(req.OldObject, oldPod); err != nil || oldPod == nil {
+	oldPod, newPod := &corev1.Pod{}, &corev1.Pod{}
+	if err := v.Decoder.DecodeRaw(req.OldObject, oldPod); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	if err := v.Decoder.DecodeRaw(req.Object, newPod); err != nil || newPod == nil {
+	if err := v.Decoder.DecodeRaw(req.Object, newPod); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
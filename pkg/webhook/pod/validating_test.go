@@ -0,0 +1,108 @@
+package pod
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+)
+
+func newValidatingTestPod(labels map[string]string) runtime.RawExtension {
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0", Labels: labels},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		panic(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestValidating(t *testing.T) {
+	onDemandLabels := map[string]string{"app": "web", podaffinity.PodAffinityLabelKey: string(podaffinity.PodAffinityOnDemand)}
+	spotLabels := map[string]string{"app": "web", podaffinity.PodAffinityLabelKey: string(podaffinity.PodAffinitySpot)}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		oldObject runtime.RawExtension
+		object    runtime.RawExtension
+		allowed   bool
+		code      int32
+	}{
+		{
+			name:      "unchanged label",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestPod(onDemandLabels),
+			object:    newValidatingTestPod(map[string]string{"app": "db", podaffinity.PodAffinityLabelKey: string(podaffinity.PodAffinityOnDemand)}),
+			allowed:   true,
+			code:      http.StatusOK,
+		},
+		{
+			name:      "unlabeled pod",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestPod(nil),
+			object:    newValidatingTestPod(map[string]string{"app": "web"}),
+			allowed:   true,
+			code:      http.StatusOK,
+		},
+		{
+			name:      "changed label",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestPod(onDemandLabels),
+			object:    newValidatingTestPod(spotLabels),
+			allowed:   false,
+			code:      http.StatusForbidden,
+		},
+		{
+			name:      "removed label",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestPod(onDemandLabels),
+			object:    newValidatingTestPod(map[string]string{"app": "web"}),
+			allowed:   false,
+			code:      http.StatusForbidden,
+		},
+		{
+			name:      "invalid object",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestPod(onDemandLabels),
+			object:    runtime.RawExtension{Raw: []byte("{")},
+			allowed:   false,
+			code:      http.StatusBadRequest,
+		},
+		{
+			name:      "create",
+			operation: admissionv1.Create,
+			object:    newValidatingTestPod(onDemandLabels),
+			allowed:   true,
+			code:      http.StatusOK,
+		},
+	}
+
+	v := &Validating{Decoder: admission.NewDecoder(scheme.Scheme)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Namespace: "default",
+				Operation: tt.operation,
+				OldObject: tt.oldObject,
+				Object:    tt.object,
+			}})
+			if resp.Allowed != tt.allowed || resp.Result.Code != tt.code {
+				t.Errorf("expect allowed %v code %d, got allowed %v code %d: %s", tt.allowed, tt.code,
+					resp.Allowed, resp.Result.Code, resp.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package replicaset
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	"vacant.sh/vmanager/pkg/webhook/utils"
+)
+
+type Validating struct {
+	Decoder admission.Decoder
+	// NamespaceFilter skips the objects in the namespaces which are not handled, nil handles all.
+	NamespaceFilter utils.NamespaceFilter
+	// KubeClient is used to find the HorizontalPodAutoscalers targeting the ReplicaSet.
+	KubeClient kubernetes.Interface
+}
+
+// Check if Validating implements necessary func.
+var _ admission.Handler = &Validating{}
+
+func (v *Validating) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update && req.Operation != admissionv1.Create {
+		// This should never happen, we only care the CREATE and UPDATE operation.
+		return admission.Allowed("")
+	}
+	if utils.IsNamespaceSkipped(v.NamespaceFilter, req.Namespace) {
+		klog.V(5).Infof("Skip the %s in unmanaged namespace %s", req.Kind.Kind, req.Namespace)
+		return admission.Allowed("")
+	}
+
+	replicaSet := &appsv1.ReplicaSet{}
+	if err := v.Decoder.DecodeRaw(req.Object, replicaSet); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	klog.V(3).Infof("Validating ReplicaSet %s/%s", replicaSet.Namespace, replicaSet.Name)
+
+	// The ReplicaSets controlled by a Deployment or another workload copy its metadata, and are scaled during
+	// the rollouts, the owner is validated instead.
+	if metav1.GetControllerOf(replicaSet) != nil {
+		metadataPath := field.NewPath("metadata")
+		errList := optimizescheduling.ValidateOptimizeSchedulingConfiguration(replicaSet.Labels, replicaSet.Annotations,
+			metadataPath)
+		if len(errList) > 0 {
+			return admission.Denied(errList.ToAggregate().Error())
+		}
+		return admission.Allowed("").WithWarnings(optimizescheduling.WarnOptimizeSchedulingConfiguration(
+			replicaSet.Labels, replicaSet.Annotations, metadataPath)...)
+	}
+
+	// The old object is only decoded for the UPDATE operation, it's nil on creation.
+	var oldObj metav1.Object
+	if req.Operation == admissionv1.Update {
+		oldReplicaSet := &appsv1.ReplicaSet{}
+		if err := v.Decoder.DecodeRaw(req.OldObject, oldReplicaSet); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldObj = oldReplicaSet
+	}
+
+	return utils.ValidateScalableWorkload(ctx, v.KubeClient, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"),
+		oldObj, replicaSet, replicaSet.Spec.Replicas)
+}
@@ -0,0 +1,116 @@
+package replicaset
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+// newValidatingTestReplicaSet returns a ReplicaSet with the custom strategy, which is controlled by a Deployment
+// if the controlled is true.
+func newValidatingTestReplicaSet(replicas int32, customOnDemand string, controlled bool) runtime.RawExtension {
+	replicaSet := &appsv1.ReplicaSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web",
+			Labels:    map[string]string{optimizescheduling.OptimizeSchedulingKey: "true"},
+			Annotations: map[string]string{
+				optimizescheduling.OptimizeSchedulingStrategyKey:               optimizescheduling.OptimizeSchedulingStrategyCustom,
+				optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey: customOnDemand,
+			},
+		},
+		Spec: appsv1.ReplicaSetSpec{Replicas: ptr.To(replicas)},
+	}
+	if controlled {
+		replicaSet.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "uid-web", Controller: ptr.To(true)},
+		}
+	}
+	raw, err := json.Marshal(replicaSet)
+	if err != nil {
+		panic(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestValidating(t *testing.T) {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web"},
+			MinReplicas:    ptr.To[int32](2),
+			MaxReplicas:    5,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		oldObject runtime.RawExtension
+		object    runtime.RawExtension
+		objects   []runtime.Object
+		allowed   bool
+	}{
+		{
+			name:      "standalone within the replicas",
+			operation: admissionv1.Create,
+			object:    newValidatingTestReplicaSet(3, "2", false),
+			allowed:   true,
+		},
+		{
+			name:      "standalone more than the replicas",
+			operation: admissionv1.Create,
+			object:    newValidatingTestReplicaSet(3, "4", false),
+			allowed:   false,
+		},
+		{
+			name:      "standalone within the maxReplicas of the hpa",
+			operation: admissionv1.Create,
+			object:    newValidatingTestReplicaSet(3, "4", false),
+			objects:   []runtime.Object{hpa},
+			allowed:   true,
+		},
+		{
+			name:      "standalone count raised on update",
+			operation: admissionv1.Update,
+			oldObject: newValidatingTestReplicaSet(3, "2", false),
+			object:    newValidatingTestReplicaSet(3, "4", false),
+			allowed:   false,
+		},
+		{
+			name:      "controlled by a deployment during the rollout",
+			operation: admissionv1.Create,
+			object:    newValidatingTestReplicaSet(1, "4", true),
+			allowed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validating{Decoder: admission.NewDecoder(scheme.Scheme), KubeClient: fake.NewSimpleClientset(tt.objects...)}
+			resp := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+				Namespace: "default",
+				Operation: tt.operation,
+				OldObject: tt.oldObject,
+				Object:    tt.object,
+			}})
+			if resp.Allowed != tt.allowed {
+				t.Errorf("expect allowed %v, got %v: %s", tt.allowed, resp.Allowed, resp.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// WithRequestDeadline limits the context of the admission request to the timeout which the API server sends
+// in the timeout query parameter, such as ?timeout=10s. The handlers of controller-runtime only see the context
+// of the http.Request, which has no deadline otherwise.
+func WithRequestDeadline(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeoutParam := r.URL.Query().Get("timeout")
+		if timeoutParam == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		timeout, err := time.ParseDuration(timeoutParam)
+		if err != nil || timeout <= 0 {
+			klog.V(3).Infof("Ignore the invalid timeout %q of the admission request %s.", timeoutParam, r.URL.Path)
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,11 @@
+package utils
+
+// NamespaceFilter tells whether the objects in a namespace are handled by vmanager.
+type NamespaceFilter interface {
+	IsNamespaceManaged(namespace string) bool
+}
+
+// IsNamespaceSkipped returns true if the objects in the namespace are not handled, a nil filter handles all.
+func IsNamespaceSkipped(filter NamespaceFilter, namespace string) bool {
+	return filter != nil && !filter.IsNamespaceManaged(namespace)
+}
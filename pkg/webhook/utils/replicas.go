@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+// GetTargetingHPA returns the HorizontalPodAutoscaler targeting the workload, or nil if there is none.
+// The HorizontalPodAutoscalers are only listed if the checks of the strategy depend on the replicas.
+func GetTargetingHPA(ctx context.Context, kubeClient kubernetes.Interface, gvk schema.GroupVersionKind,
+	obj metav1.Object, specReplicas *int32) *autoscalingv2.HorizontalPodAutoscaler {
+
+	if kubeClient == nil || !isReplicasChecked(obj, specReplicas) {
+		return nil
+	}
+
+	hpaList, err := kubeClient.AutoscalingV2().HorizontalPodAutoscalers(obj.GetNamespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// Don't block the workload because of the HorizontalPodAutoscalers, treat it as not autoscaled.
+		klog.Errorf("Failed to list the HorizontalPodAutoscalers in namespace %s: %v", obj.GetNamespace(), err)
+		return nil
+	}
+
+	return FindTargetingHPA(hpaList.Items, gvk, obj.GetName())
+}
+
+// isReplicasChecked returns true if the strategy of the workload is checked against its replicas, which are limited
+// by the HorizontalPodAutoscaler targeting it: the custom on-demand count, and the single replica of all-in-spot.
+// The workloads of all-in-spot with more replicas are not warned even if their HorizontalPodAutoscaler may scale
+// them down to one, so that the HorizontalPodAutoscalers are not listed on every update of them.
+func isReplicasChecked(obj metav1.Object, specReplicas *int32) bool {
+	configuration := optimizescheduling.GetOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations())
+	if configuration[optimizescheduling.OptimizeSchedulingKey] != "true" {
+		return false
+	}
+
+	switch configuration[optimizescheduling.OptimizeSchedulingStrategyKey] {
+	case optimizescheduling.OptimizeSchedulingStrategyCustom:
+		return true
+	case optimizescheduling.OptimizeSchedulingStrategyAllInSpot:
+		return ptr.Deref(specReplicas, 1) <= 1
+	}
+	return false
+}
+
+// FindTargetingHPA returns the HorizontalPodAutoscaler targeting the workload among the hpas of its namespace,
+// or nil if there is none.
+func FindTargetingHPA(hpas []autoscalingv2.HorizontalPodAutoscaler, gvk schema.GroupVersionKind,
+	name string) *autoscalingv2.HorizontalPodAutoscaler {
+
+	for i := range hpas {
+		if isHPATargeting(&hpas[i], gvk, name) {
+			return &hpas[i]
+		}
+	}
+	return nil
+}
+
+// GetMinReplicas returns the lowest number of replicas the workload can run with, and where it comes from.
+// If the workload is targeted by the hpa, its minReplicas is used instead of the spec.replicas,
+// since the spec.replicas is managed by the HorizontalPodAutoscaler.
+func GetMinReplicas(specReplicas *int32, hpa *autoscalingv2.HorizontalPodAutoscaler) (int, string) {
+	if hpa != nil {
+		if hpa.Spec.MinReplicas == nil {
+			return 1, fmt.Sprintf("minReplicas of HorizontalPodAutoscaler %s", hpa.Name)
+		}
+		return int(*hpa.Spec.MinReplicas), fmt.Sprintf("minReplicas of HorizontalPodAutoscaler %s", hpa.Name)
+	}
+
+	if specReplicas == nil {
+		return 1, "spec.replicas"
+	}
+	return int(*specReplicas), "spec.replicas"
+}
+
+// GetMaxReplicas returns the highest number of replicas the workload can run with, and where it comes from.
+// If the workload is targeted by the hpa, its maxReplicas is used instead of the spec.replicas.
+func GetMaxReplicas(specReplicas *int32, hpa *autoscalingv2.HorizontalPodAutoscaler) (int, string) {
+	if hpa != nil {
+		return int(hpa.Spec.MaxReplicas), fmt.Sprintf("maxReplicas of HorizontalPodAutoscaler %s", hpa.Name)
+	}
+
+	if specReplicas == nil {
+		return 1, "spec.replicas"
+	}
+	return int(*specReplicas), "spec.replicas"
+}
+
+// CheckWorkloadReplicas validates the custom on-demand count of the workload against its replicas, and returns the
+// warnings about its replicas. The count is only validated when it's set or changed, the oldObj is nil on creation,
+// so that the workload scaled down below the count can still be updated. The count which exceeds the maxReplicas
+// of the HorizontalPodAutoscaler lowered afterwards is warned on every update instead. The hpa is the
+// HorizontalPodAutoscaler targeting the workload, which only needs to be found if isReplicasChecked.
+func CheckWorkloadReplicas(workloadType string, oldObj, obj metav1.Object, specReplicas *int32,
+	hpa *autoscalingv2.HorizontalPodAutoscaler, metadataPath *field.Path) (field.ErrorList, []string) {
+
+	maxReplicas, maxReplicasSource := GetMaxReplicas(specReplicas, hpa)
+	errList := optimizescheduling.ValidateOptimizeSchedulingReplicas(obj.GetLabels(), obj.GetAnnotations(),
+		metadataPath, maxReplicas, maxReplicasSource)
+	if len(errList) > 0 && (oldObj == nil || optimizescheduling.IsCustomOnDemandChanged(oldObj.GetLabels(),
+		oldObj.GetAnnotations(), obj.GetLabels(), obj.GetAnnotations())) {
+		return errList, nil
+	}
+
+	var warnings []string
+	var hpaName string
+	if hpa != nil {
+		hpaName = hpa.Name
+		// Without a HorizontalPodAutoscaler, the maxReplicas is the spec.replicas, which is warned as the minReplicas.
+		for _, err := range errList {
+			warnings = append(warnings, fmt.Sprintf("%s It's rejected once the count is changed.", err.Error()))
+		}
+	}
+	minReplicas, minReplicasSource := GetMinReplicas(specReplicas, hpa)
+	return nil, append(warnings, optimizescheduling.WarnOptimizeSchedulingReplicas(obj.GetLabels(), obj.GetAnnotations(),
+		workloadType, minReplicas, minReplicasSource, hpaName)...)
+}
+
+func isHPATargeting(hpa *autoscalingv2.HorizontalPodAutoscaler, gvk schema.GroupVersionKind, name string) bool {
+	targetRef := hpa.Spec.ScaleTargetRef
+	if targetRef.Kind != gvk.Kind || targetRef.Name != name {
+		return false
+	}
+
+	gv, err := schema.ParseGroupVersion(targetRef.APIVersion)
+	return err == nil && gv.Group == gvk.Group
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+func newCustomWorkload(customOnDemand string, extraAnnotations map[string]string) *metav1.ObjectMeta {
+	annotations := map[string]string{
+		optimizescheduling.OptimizeSchedulingStrategyKey:               optimizescheduling.OptimizeSchedulingStrategyCustom,
+		optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey: customOnDemand,
+	}
+	for key, value := range extraAnnotations {
+		annotations[key] = value
+	}
+	return &metav1.ObjectMeta{
+		Name:        "web",
+		Namespace:   "default",
+		Labels:      map[string]string{optimizescheduling.OptimizeSchedulingKey: "true"},
+		Annotations: annotations,
+	}
+}
+
+func newAllInSpotWorkload() *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{
+		Name:      "web",
+		Namespace: "default",
+		Labels: map[string]string{
+			optimizescheduling.OptimizeSchedulingKey:         "true",
+			optimizescheduling.OptimizeSchedulingStrategyKey: optimizescheduling.OptimizeSchedulingStrategyAllInSpot,
+		},
+	}
+}
+
+// TestAllInSpotSingleReplica checks the single replica of all-in-spot by the minReplicas of the HorizontalPodAutoscaler,
+// which manages the spec.replicas.
+func TestAllInSpotSingleReplica(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	newHPA := func(minReplicas *int32) *autoscalingv2.HorizontalPodAutoscaler {
+		return &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+				MinReplicas:    minReplicas,
+				MaxReplicas:    10,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		hpa          *autoscalingv2.HorizontalPodAutoscaler
+		expectWarned bool
+	}{
+		{
+			name:         "no hpa",
+			expectWarned: true,
+		},
+		{
+			name: "hpa with more minReplicas",
+			hpa:  newHPA(ptr.To[int32](2)),
+		},
+		{
+			name:         "hpa with the default minReplicas",
+			hpa:          newHPA(nil),
+			expectWarned: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			if tc.hpa != nil {
+				kubeClient = fake.NewSimpleClientset(tc.hpa)
+			}
+			obj := newAllInSpotWorkload()
+			specReplicas := ptr.To[int32](1)
+
+			hpa := GetTargetingHPA(context.TODO(), kubeClient, gvk, obj, specReplicas)
+			errList, warnings := CheckWorkloadReplicas("Deployment", nil, obj, specReplicas, hpa, field.NewPath("metadata"))
+			if len(errList) > 0 {
+				t.Errorf("expect not denied, got %v", errList)
+			}
+			if warned := len(warnings) > 0; warned != tc.expectWarned {
+				t.Errorf("expect warned %v, got %v", tc.expectWarned, warnings)
+			}
+		})
+	}
+}
+
+func TestCheckWorkloadReplicas(t *testing.T) {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			MinReplicas: ptr.To[int32](2),
+			MaxReplicas: 10,
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		oldObj       metav1.Object
+		obj          metav1.Object
+		specReplicas *int32
+		hpa          *autoscalingv2.HorizontalPodAutoscaler
+		expectDenied bool
+		expectWarned bool
+		// expectWarning is a part of one of the warnings, if it's not empty.
+		expectWarning string
+	}{
+		{
+			name:         "create within the replicas",
+			obj:          newCustomWorkload("2", nil),
+			specReplicas: ptr.To[int32](3),
+		},
+		{
+			name:         "create more than the replicas",
+			obj:          newCustomWorkload("4", nil),
+			specReplicas: ptr.To[int32](3),
+			expectDenied: true,
+		},
+		{
+			name:         "create more than the minReplicas but within the maxReplicas",
+			obj:          newCustomWorkload("4", nil),
+			specReplicas: ptr.To[int32](3),
+			hpa:          hpa,
+			expectWarned: true,
+		},
+		{
+			name:         "create more than the maxReplicas",
+			obj:          newCustomWorkload("11", nil),
+			specReplicas: ptr.To[int32](3),
+			hpa:          hpa,
+			expectDenied: true,
+		},
+		{
+			name:          "lower the maxReplicas of the hpa below the unchanged count",
+			oldObj:        newCustomWorkload("11", nil),
+			obj:           newCustomWorkload("11", map[string]string{"vacant.sh/other": "value"}),
+			specReplicas:  ptr.To[int32](3),
+			hpa:           hpa,
+			expectWarned:  true,
+			expectWarning: "less than or equal to the maxReplicas of HorizontalPodAutoscaler web (10)",
+		},
+		{
+			name:         "scale to 0 with the count unchanged",
+			oldObj:       newCustomWorkload("2", nil),
+			obj:          newCustomWorkload("2", nil),
+			specReplicas: ptr.To[int32](0),
+			expectWarned: true,
+		},
+		{
+			name:         "patch other annotations with the count unchanged",
+			oldObj:       newCustomWorkload("4", nil),
+			obj:          newCustomWorkload("4", map[string]string{"vacant.sh/other": "value"}),
+			specReplicas: ptr.To[int32](3),
+			expectWarned: true,
+		},
+		{
+			name:         "change the count more than the replicas",
+			oldObj:       newCustomWorkload("2", nil),
+			obj:          newCustomWorkload("4", nil),
+			specReplicas: ptr.To[int32](3),
+			expectDenied: true,
+		},
+		{
+			name: "switch to the custom strategy more than the replicas",
+			oldObj: &metav1.ObjectMeta{
+				Labels: map[string]string{optimizescheduling.OptimizeSchedulingKey: "true"},
+			},
+			obj:          newCustomWorkload("4", nil),
+			specReplicas: ptr.To[int32](3),
+			expectDenied: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errList, warnings := CheckWorkloadReplicas("Deployment", tc.oldObj, tc.obj, tc.specReplicas, tc.hpa,
+				field.NewPath("metadata"))
+			if denied := len(errList) > 0; denied != tc.expectDenied {
+				t.Errorf("expect denied %v, got %v", tc.expectDenied, errList)
+			}
+			if warned := len(warnings) > 0; warned != tc.expectWarned {
+				t.Errorf("expect warned %v, got %v", tc.expectWarned, warnings)
+			}
+			if tc.expectWarning != "" && !containsWarning(warnings, tc.expectWarning) {
+				t.Errorf("expect a warning containing %q, got %v", tc.expectWarning, warnings)
+			}
+		})
+	}
+}
+
+func containsWarning(warnings []string, substr string) bool {
+	for _, warning := range warnings {
+		if strings.Contains(warning, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGetTargetingHPA(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MaxReplicas:    10,
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		obj          metav1.Object
+		specReplicas *int32
+		expectList   bool
+	}{
+		{
+			name: "not managed",
+			obj:  &metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		},
+		{
+			name: "not the custom strategy",
+			obj: &metav1.ObjectMeta{Name: "web", Namespace: "default",
+				Labels: map[string]string{optimizescheduling.OptimizeSchedulingKey: "true"}},
+		},
+		{
+			name:       "the custom strategy",
+			obj:        newCustomWorkload("2", nil),
+			expectList: true,
+		},
+		{
+			name:         "all-in-spot with a single replica",
+			obj:          newAllInSpotWorkload(),
+			specReplicas: ptr.To[int32](1),
+			expectList:   true,
+		},
+		{
+			name:         "all-in-spot with more replicas",
+			obj:          newAllInSpotWorkload(),
+			specReplicas: ptr.To[int32](3),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(hpa)
+			found := GetTargetingHPA(context.TODO(), kubeClient, gvk, tc.obj, tc.specReplicas)
+
+			if listed := len(kubeClient.Actions()) > 0; listed != tc.expectList {
+				t.Errorf("expect listed %v, got %v", tc.expectList, kubeClient.Actions())
+			}
+			if (found != nil) != tc.expectList {
+				t.Errorf("expect found %v, got %v", tc.expectList, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+)
+
+// ValidateScalableWorkload validates the optimize scheduling configuration of a workload which runs its spec.replicas,
+// such as a Deployment, a StatefulSet or a standalone ReplicaSet. The custom on-demand count is checked against
+// the replicas, or against the HorizontalPodAutoscaler targeting the workload. The oldObj is nil on creation.
+func ValidateScalableWorkload(ctx context.Context, kubeClient kubernetes.Interface, gvk schema.GroupVersionKind,
+	oldObj, obj metav1.Object, specReplicas *int32) admission.Response {
+
+	metadataPath := field.NewPath("metadata")
+	errList := optimizescheduling.ValidateOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath)
+	if len(errList) > 0 {
+		return admission.Denied(errList.ToAggregate().Error())
+	}
+
+	hpa := GetTargetingHPA(ctx, kubeClient, gvk, obj, specReplicas)
+	errList, replicasWarnings := CheckWorkloadReplicas(gvk.Kind, oldObj, obj, specReplicas, hpa, metadataPath)
+	if len(errList) > 0 {
+		return admission.Denied(errList.ToAggregate().Error())
+	}
+
+	// Tell the users about the risky configurations without blocking them.
+	warnings := optimizescheduling.WarnOptimizeSchedulingConfiguration(obj.GetLabels(), obj.GetAnnotations(), metadataPath)
+	warnings = append(warnings, replicasWarnings...)
+
+	return admission.Allowed("").WithWarnings(warnings...)
+}
@@ -0,0 +1,400 @@
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"vacant.sh/vmanager/cmd/webhook-manager/app"
+	"vacant.sh/vmanager/cmd/webhook-manager/app/options"
+	"vacant.sh/vmanager/pkg/config"
+	optimizescheduling "vacant.sh/vmanager/pkg/definitions/optimize-scheduling"
+	podaffinity "vacant.sh/vmanager/pkg/definitions/pod-affinity"
+	"vacant.sh/vmanager/pkg/webhook/configuration"
+)
+
+const (
+	// testNamespace is the namespace of the objects created by the tests.
+	testNamespace = "vmanager-integration"
+	// requiredEnv opts in the integration tests, they fail instead of being skipped if the binaries are missing.
+	requiredEnv = "VMANAGER_INTEGRATION"
+)
+
+// testEnvironment is a local kube-apiserver and etcd, with the webhook-manager registered to it.
+type testEnvironment struct {
+	kubeClient kubernetes.Interface
+	config     *config.WebhookManagerConfiguration
+}
+
+// startTestEnvironment starts the kube-apiserver and etcd found in KUBEBUILDER_ASSETS, installs the webhook
+// configurations of the webhook-manager pointing to a local port, then runs the webhook-manager by app.Run
+// with the certificates generated by envtest. The test is skipped if KUBEBUILDER_ASSETS is not set, unless
+// VMANAGER_INTEGRATION is set, such as by make test-integration.
+func startTestEnvironment(t *testing.T) *testEnvironment {
+	t.Helper()
+
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		if os.Getenv(requiredEnv) != "" {
+			t.Fatalf("%s is set but KUBEBUILDER_ASSETS is not, the kube-apiserver and etcd are missing.", requiredEnv)
+		}
+		t.Skip("KUBEBUILDER_ASSETS is not set, run make test-integration to download the kube-apiserver and etcd.")
+	}
+
+	c := config.NewDefaultConfiguration()
+	mutatingConfigurations, validatingConfigurations := configuration.BuildWebhookConfigurations(c,
+		configuration.DefaultService, nil)
+	// envtest replaces the Service by the URL of the local webhook server, which joins the path with a slash.
+	for _, mutatingConfiguration := range mutatingConfigurations {
+		for i := range mutatingConfiguration.Webhooks {
+			trimPath(mutatingConfiguration.Webhooks[i].ClientConfig.Service)
+		}
+	}
+	for _, validatingConfiguration := range validatingConfigurations {
+		for i := range validatingConfiguration.Webhooks {
+			trimPath(validatingConfiguration.Webhooks[i].ClientConfig.Service)
+		}
+	}
+
+	testEnv := &envtest.Environment{
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			MutatingWebhooks:   mutatingConfigurations,
+			ValidatingWebhooks: validatingConfigurations,
+		},
+	}
+	restConfig, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("failed to start the test environment: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("failed to stop the test environment: %v", err)
+		}
+	})
+
+	// app.Run builds its clients from a kubeconfig file.
+	user, err := testEnv.AddUser(envtest.User{Name: "vmanager", Groups: []string{"system:masters"}}, restConfig)
+	if err != nil {
+		t.Fatalf("failed to add the user of the webhook-manager: %v", err)
+	}
+	kubeConfig, err := user.KubeConfig()
+	if err != nil {
+		t.Fatalf("failed to build the kubeconfig: %v", err)
+	}
+	kubeConfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeConfigPath, kubeConfig, 0600); err != nil {
+		t.Fatalf("failed to write the kubeconfig: %v", err)
+	}
+
+	webhookInstallOptions := &testEnv.WebhookInstallOptions
+	opts := options.NewOptions()
+	opts.KubeConfig = kubeConfigPath
+	opts.CertDir = webhookInstallOptions.LocalServingCertDir
+	opts.CertName = "tls.crt"
+	opts.KeyName = "tls.key"
+	opts.BindAddress = webhookInstallOptions.LocalServingHost
+	opts.SecurePort = webhookInstallOptions.LocalServingPort
+	opts.MetricsBindAddress = "0"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var runErr error
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		runErr = app.Run(ctx, opts, config.NewHolder(c))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		if runErr != nil && !errors.Is(runErr, context.Canceled) {
+			t.Errorf("the webhook-manager stopped with an error: %v", runErr)
+		}
+	})
+
+	waitForWebhookServer(t, webhookInstallOptions, stopped)
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		t.Fatalf("failed to build the client: %v", err)
+	}
+	_, err = kubeClient.CoreV1().Namespaces().Create(context.Background(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the namespace: %v", err)
+	}
+
+	return &testEnvironment{kubeClient: kubeClient, config: c}
+}
+
+// trimPath removes the leading slash of the path of the webhook.
+func trimPath(service *admissionregistrationv1.ServiceReference) {
+	service.Path = ptr.To(strings.TrimPrefix(*service.Path, "/"))
+}
+
+// waitForWebhookServer waits until the webhook server accepts the TLS connections with the generated certificate.
+func waitForWebhookServer(t *testing.T, webhookInstallOptions *envtest.WebhookInstallOptions, stopped <-chan struct{}) {
+	t.Helper()
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(webhookInstallOptions.LocalServingCAData) {
+		t.Fatal("failed to load the generated CA")
+	}
+	address := net.JoinHostPort(webhookInstallOptions.LocalServingHost, strconv.Itoa(webhookInstallOptions.LocalServingPort))
+
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, time.Minute, true,
+		func(ctx context.Context) (bool, error) {
+			select {
+			case <-stopped:
+				return false, errors.New("the webhook-manager stopped")
+			default:
+			}
+
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", address,
+				&tls.Config{RootCAs: rootCAs, ServerName: webhookInstallOptions.LocalServingHost})
+			if err != nil {
+				return false, nil
+			}
+			return true, conn.Close()
+		})
+	if err != nil {
+		t.Fatalf("the webhook server is not ready: %v", err)
+	}
+}
+
+// createDeployment creates the Deployment, and a ReplicaSet controlled by it, since there is no controller-manager.
+func (e *testEnvironment) createDeployment(t *testing.T, name string, replicas int32,
+	workloadLabels map[string]string) (*appsv1.Deployment, *appsv1.ReplicaSet) {
+	t.Helper()
+
+	ctx := context.Background()
+	deployment, err := e.kubeClient.AppsV1().Deployments(testNamespace).Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: workloadLabels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: newPodTemplate(name),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create Deployment %s: %v", name, err)
+	}
+
+	replicaSet, err := e.kubeClient.AppsV1().ReplicaSets(testNamespace).Create(ctx, &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name + "-v1",
+			OwnerReferences: []metav1.OwnerReference{newControllerReference("Deployment", deployment.Name, deployment.UID)},
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: newPodTemplate(name),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create ReplicaSet of %s: %v", name, err)
+	}
+	return deployment, replicaSet
+}
+
+// createStatefulSet creates the StatefulSet, it's validated by the webhook-manager.
+func (e *testEnvironment) createStatefulSet(name string, replicas int32, workloadLabels,
+	workloadAnnotations map[string]string) (*appsv1.StatefulSet, error) {
+
+	return e.kubeClient.AppsV1().StatefulSets(testNamespace).Create(context.Background(), &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: workloadLabels, Annotations: workloadAnnotations},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: newPodTemplate(name),
+		},
+	}, metav1.CreateOptions{})
+}
+
+// createPods creates the Pods of the owner in order, and returns them as mutated by the webhook-manager.
+func (e *testEnvironment) createPods(t *testing.T, ownerKind, ownerName string, ownerUID types.UID, num int) []*corev1.Pod {
+	t.Helper()
+
+	pods := make([]*corev1.Pod, 0, num)
+	for i := 0; i < num; i++ {
+		template := newPodTemplate(ownerName)
+		pod, err := e.kubeClient.CoreV1().Pods(testNamespace).Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            fmt.Sprintf("%s-%d", ownerName, i),
+				Labels:          template.Labels,
+				OwnerReferences: []metav1.OwnerReference{newControllerReference(ownerKind, ownerName, ownerUID)},
+			},
+			Spec: template.Spec,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("failed to create Pod %d of %s: %v", i, ownerName, err)
+		}
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// expectPlacement checks the label and the node affinity added to the Pod.
+func (e *testEnvironment) expectPlacement(t *testing.T, pod *corev1.Pod, affinity podaffinity.PodAffinitySettingName) {
+	t.Helper()
+
+	var required *corev1.NodeSelector
+	var preferred []corev1.PreferredSchedulingTerm
+	if pod.Spec.Affinity != nil && pod.Spec.Affinity.NodeAffinity != nil {
+		required = pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		preferred = pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	}
+
+	label, labeled := pod.Labels[podaffinity.PodAffinityLabelKey]
+	switch affinity {
+	case podaffinity.PodAffinityOnDemand:
+		if label != string(affinity) {
+			t.Errorf("expect Pod %s to be labeled %s, got %q", pod.Name, affinity, label)
+		}
+		if required == nil || len(required.NodeSelectorTerms) != 1 ||
+			!equality.Semantic.DeepEqual(required.NodeSelectorTerms[0], *e.config.Affinity.OnDemand) {
+			t.Errorf("expect Pod %s to require %v, got %v", pod.Name, *e.config.Affinity.OnDemand, required)
+		}
+	case podaffinity.PodAffinitySpot:
+		if label != string(affinity) {
+			t.Errorf("expect Pod %s to be labeled %s, got %q", pod.Name, affinity, label)
+		}
+		if len(preferred) != 1 || !equality.Semantic.DeepEqual(preferred[0], *e.config.Affinity.Spot) {
+			t.Errorf("expect Pod %s to prefer %v, got %v", pod.Name, *e.config.Affinity.Spot, preferred)
+		}
+	default:
+		if labeled || required != nil || len(preferred) != 0 {
+			t.Errorf("expect Pod %s not to be mutated, got label %q, required %v, preferred %v", pod.Name, label,
+				required, preferred)
+		}
+	}
+}
+
+func newPodTemplate(name string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+		},
+	}
+}
+
+func newControllerReference(kind, name string, uid types.UID) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion:         appsv1.SchemeGroupVersion.String(),
+		Kind:               kind,
+		Name:               name,
+		UID:                uid,
+		Controller:         ptr.To(true),
+		BlockOwnerDeletion: ptr.To(true),
+	}
+}
+
+// optimizeSchedulingLabels returns the labels which enable the optimize scheduling with the strategy.
+func optimizeSchedulingLabels(strategy string) map[string]string {
+	return map[string]string{
+		optimizescheduling.OptimizeSchedulingKey:         "true",
+		optimizescheduling.OptimizeSchedulingStrategyKey: strategy,
+	}
+}
+
+// expectDenied checks that the request was denied by the webhook with the message.
+func expectDenied(t *testing.T, err error, message string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expect the request to be denied with %q, got allowed", message)
+	}
+	if !apierrors.IsForbidden(err) || !strings.Contains(err.Error(), message) {
+		t.Fatalf("expect the request to be denied with %q, got %v", message, err)
+	}
+}
+
+// TestAdmission runs the webhook-manager against a real kube-apiserver, so that the registration, the TLS serving
+// and the HTTP handling of the webhooks are covered with the decisions.
+func TestAdmission(t *testing.T) {
+	e := startTestEnvironment(t)
+
+	// The Pods of one workload are all placed on one capacity type, so the result doesn't depend on how fast
+	// the Pods created before are observed by the cache.
+	t.Run("Deployment all-in-on-demand", func(t *testing.T) {
+		_, replicaSet := e.createDeployment(t, "on-demand", 3,
+			optimizeSchedulingLabels(optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand))
+		for _, pod := range e.createPods(t, "ReplicaSet", replicaSet.Name, replicaSet.UID, 3) {
+			e.expectPlacement(t, pod, podaffinity.PodAffinityOnDemand)
+		}
+	})
+
+	t.Run("StatefulSet all-in-spot", func(t *testing.T) {
+		statefulSet, err := e.createStatefulSet("spot", 2,
+			optimizeSchedulingLabels(optimizescheduling.OptimizeSchedulingStrategyAllInSpot), nil)
+		if err != nil {
+			t.Fatalf("failed to create StatefulSet: %v", err)
+		}
+		for _, pod := range e.createPods(t, "StatefulSet", statefulSet.Name, statefulSet.UID, 2) {
+			e.expectPlacement(t, pod, podaffinity.PodAffinitySpot)
+		}
+	})
+
+	t.Run("Deployment disabled", func(t *testing.T) {
+		_, replicaSet := e.createDeployment(t, "disabled", 2, nil)
+		for _, pod := range e.createPods(t, "ReplicaSet", replicaSet.Name, replicaSet.UID, 2) {
+			e.expectPlacement(t, pod, podaffinity.PodAffinityUnset)
+		}
+	})
+
+	t.Run("Deployment invalid strategy", func(t *testing.T) {
+		_, err := e.kubeClient.AppsV1().Deployments(testNamespace).Create(context.Background(), &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Labels: optimizeSchedulingLabels("everything-in-spot")},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To[int32](1),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "invalid"}},
+				Template: newPodTemplate("invalid"),
+			},
+		}, metav1.CreateOptions{})
+		expectDenied(t, err, optimizescheduling.OptimizeSchedulingStrategyKey)
+	})
+
+	t.Run("StatefulSet custom on-demand more than replicas", func(t *testing.T) {
+		_, err := e.createStatefulSet("custom", 2,
+			optimizeSchedulingLabels(optimizescheduling.OptimizeSchedulingStrategyCustom),
+			map[string]string{optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey: "3"})
+		expectDenied(t, err, optimizescheduling.OptimizeSchedulingStrategyCustomOnDemandKey)
+	})
+
+	t.Run("Pod affinity label updated", func(t *testing.T) {
+		_, replicaSet := e.createDeployment(t, "relabel", 1,
+			optimizeSchedulingLabels(optimizescheduling.OptimizeSchedulingStrategyAllInOnDemand))
+		pod := e.createPods(t, "ReplicaSet", replicaSet.Name, replicaSet.UID, 1)[0]
+
+		// The other labels can be updated.
+		pod.Labels["tier"] = "web"
+		pod, err := e.kubeClient.CoreV1().Pods(testNamespace).Update(context.Background(), pod, metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatalf("failed to update the labels of Pod: %v", err)
+		}
+
+		pod.Labels[podaffinity.PodAffinityLabelKey] = string(podaffinity.PodAffinitySpot)
+		_, err = e.kubeClient.CoreV1().Pods(testNamespace).Update(context.Background(), pod, metav1.UpdateOptions{})
+		expectDenied(t, err, podaffinity.PodAffinityLabelKey)
+	})
+}